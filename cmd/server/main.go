@@ -1,17 +1,23 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"time"
 
 	"booking-dinner/internal/api"
 	"booking-dinner/internal/api/handlers"
 	"booking-dinner/internal/config"
 	"booking-dinner/internal/domain/restaurant"
 	"booking-dinner/internal/storage/memory"
+	"booking-dinner/internal/storage/postgres"
+	"booking-dinner/internal/storage/sqlite"
+	"booking-dinner/pkg/clock"
 	"booking-dinner/pkg/logger"
 
 	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
 )
 
 func main() {
@@ -28,20 +34,51 @@ func main() {
 	}
 	defer logger.Sync()
 
-	// Initialize repository
-	repo := memory.NewRestaurantRepository()
-
-	// Initialize service
-	service := restaurant.NewService(repo, cfg.Restaurant.SeatsPerTable, cfg.Restaurant.MaxTables, cfg.Restaurant.Code.Charset, cfg.Restaurant.Code.Length)
+	// Open the database shared by every restaurant
+	systemClock := clock.System{}
+	var newRepository func(restaurantID string) restaurant.Repository
+	switch cfg.Database.Type {
+	case config.DatabaseSQLite:
+		db, err := sqlite.Open(cfg.Database.Path)
+		if err != nil {
+			logger.Fatal(fmt.Sprintf("Failed to open database: %v", err))
+		}
+		defer db.Close()
+		newRepository = func(restaurantID string) restaurant.Repository {
+			return sqlite.NewRestaurantRepository(db, restaurantID, systemClock)
+		}
+	case config.DatabasePostgres:
+		db, err := postgres.Open(cfg.Database.DSN)
+		if err != nil {
+			logger.Fatal(fmt.Sprintf("Failed to open database: %v", err))
+		}
+		defer db.Close()
+		newRepository = func(restaurantID string) restaurant.Repository {
+			return postgres.NewRestaurantRepository(db, restaurantID, systemClock)
+		}
+	default:
+		newRepository = func(string) restaurant.Repository {
+			return memory.NewRestaurantRepository(systemClock)
+		}
+	}
 
-	// Initialize handler
-	handler := handlers.NewRestaurantHandler(service)
+	// Initialize a service and handler for each restaurant
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	restaurants := make(map[string]handlers.Handler)
+	for _, restaurantCfg := range cfg.AllRestaurants() {
+		service, err := newService(ctx, restaurantCfg, newRepository(restaurantCfg.ID), systemClock, logger)
+		if err != nil {
+			logger.Fatal(fmt.Sprintf("Failed to initialize restaurant %s: %v", restaurantCfg.ID, err))
+		}
+		restaurants[restaurantCfg.ID] = handlers.NewRestaurantHandler(service, logger.With(zap.String("restaurant", restaurantCfg.ID)))
+	}
 
 	// Initialize Fiber app
 	app := fiber.New()
 
 	// Setup routes
-	api.SetupRoutes(app, handler)
+	api.SetupRoutes(app, restaurants[cfg.Restaurant.ID], handlers.NewTenantHandler(restaurants))
 
 	// Start server
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
@@ -50,3 +87,97 @@ func main() {
 		logger.Fatal(fmt.Sprintf("Failed to start server: %v", err))
 	}
 }
+
+// newService builds the service of one restaurant from its configuration and
+// starts releasing its lapsed holds and waitlist offers until ctx is done
+func newService(ctx context.Context, cfg config.RestaurantConfig, repo restaurant.Repository, clock clock.Clock, logger *logger.Logger) (restaurant.Service, error) {
+	// Initialize table allocation strategy
+	strategy, err := restaurant.NewAllocationStrategy(cfg.Allocation.Strategy, cfg.Allocation.LargePartySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize allocation strategy: %w", err)
+	}
+
+	// Initialize booking code generator
+	codes, err := restaurant.NewCodeGenerator(cfg.Code.Charset, cfg.Code.Length, cfg.Code.ExcludeAmbiguous, cfg.Code.CheckCharacter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize booking codes: %w", err)
+	}
+	if err := codes.CheckKeyspace(cfg.MaxTables); err != nil {
+		logger.Warn(fmt.Sprintf("Booking code keyspace of restaurant %s is small: %v", cfg.ID, err))
+	}
+
+	// Initialize opening hours
+	schedule, err := newSchedule(cfg.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize schedule: %w", err)
+	}
+
+	// Initialize turn times
+	rules := make([]restaurant.TurnTimeRule, len(cfg.TurnTimes))
+	for i, rule := range cfg.TurnTimes {
+		rules[i] = restaurant.TurnTimeRule{MinParty: rule.MinParty, MaxParty: rule.MaxParty, Period: rule.Period, Duration: rule.Duration}
+	}
+	turnTimes, err := restaurant.NewTurnTimes(rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize turn times: %w", err)
+	}
+
+	// Initialize service, with the waitlist, holds and idempotency keys off
+	// unless enabled
+	options := restaurant.Options{
+		Strategy:            strategy,
+		Codes:               codes,
+		SeatsPerTable:       cfg.SeatsPerTable,
+		MaxTables:           cfg.MaxTables,
+		ReservationDuration: cfg.ReservationDuration,
+		TurnTimes:           turnTimes,
+		SlotInterval:        cfg.SlotInterval,
+		Schedule:            schedule,
+	}
+	if cfg.Waitlist.Enabled {
+		options.OfferHold = cfg.Waitlist.OfferHold
+	}
+	if cfg.Holds.Enabled {
+		options.HoldTTL = cfg.Holds.TTL
+	}
+	if cfg.Idempotency.Enabled {
+		options.IdempotencyTTL = cfg.Idempotency.TTL
+	}
+	service := restaurant.NewService(repo, options, clock)
+
+	// Release lapsed holds and waitlist offers in the background
+	if options.OfferHold > 0 || options.HoldTTL > 0 {
+		ticker := time.NewTicker(cfg.Holds.ReapInterval)
+		go func() {
+			<-ctx.Done()
+			ticker.Stop()
+		}()
+		go restaurant.RunHoldReaper(ctx, service, ticker.C, func(err error) {
+			logger.Error(fmt.Sprintf("Failed to release expired holds of restaurant %s: %v", cfg.ID, err))
+		})
+	}
+
+	return service, nil
+}
+
+// newSchedule builds the opening hours from the configuration, or returns nil
+// to take bookings at any time if the schedule is disabled
+func newSchedule(cfg config.ScheduleConfig) (*restaurant.Schedule, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	periods := make([]restaurant.ServicePeriod, len(cfg.Periods))
+	for i, period := range cfg.Periods {
+		periods[i] = restaurant.ServicePeriod{Name: period.Name, Open: period.Open, Close: period.Close}
+	}
+	closures := make([]restaurant.Closure, len(cfg.Closures))
+	for i, closure := range cfg.Closures {
+		closures[i] = restaurant.Closure{Date: closure.Date, Reason: closure.Reason}
+	}
+	events := make([]restaurant.SpecialEvent, len(cfg.Events))
+	for i, event := range cfg.Events {
+		events[i] = restaurant.SpecialEvent{Name: event.Name, Date: event.Date, Open: event.Open, Close: event.Close, TableIDs: event.Tables}
+	}
+	return restaurant.NewSchedule(cfg.Timezone, periods, cfg.Weekly, closures, events)
+}
@@ -9,6 +9,25 @@ type Handler interface {
 	InitializeTables(c *fiber.Ctx) error
 	ReserveTables(c *fiber.Ctx) error
 	CancelReservation(c *fiber.Ctx) error
+	SeatBooking(c *fiber.Ctx) error
+	CompleteBooking(c *fiber.Ctx) error
+	MarkNoShow(c *fiber.Ctx) error
+	ModifyReservation(c *fiber.Ctx) error
+	SetTurnTime(c *fiber.Ctx) error
+	GetBooking(c *fiber.Ctx) error
+	ListBookings(c *fiber.Ctx) error
+	GetAvailability(c *fiber.Ctx) error
+	SeatWalkIn(c *fiber.Ctx) error
+	ClearTable(c *fiber.Ctx) error
+	GetFloorStatus(c *fiber.Ctx) error
+	AddTable(c *fiber.Ctx) error
+	RetireTable(c *fiber.Ctx) error
+	RemoveTable(c *fiber.Ctx) error
+	JoinWaitlist(c *fiber.Ctx) error
+	GetWaitlistEntry(c *fiber.Ctx) error
+	ConfirmWaitlistOffer(c *fiber.Ctx) error
+	HoldTables(c *fiber.Ctx) error
+	ConfirmHold(c *fiber.Ctx) error
 }
 
 // Response is a generic response structure
@@ -17,6 +36,7 @@ type Response struct {
 	Message string      `json:"message,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+	Details interface{} `json:"details,omitempty"`
 }
 
 // NewSuccessResponse creates a new success response
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"booking-dinner/internal/errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Headers of idempotent requests and their replayed responses
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// maxIdempotencyKeyLength bounds the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// idempotent runs handle at most once for each Idempotency-Key a client
+// sends. The response to the first request with a key is stored and replayed
+// for repeats of that request, so retrying after a lost response does not
+// book twice. A key reused for a different request, meaning another
+// operation, booking or body, is rejected with 422, and a repeat sent while
+// the first request is still running with 409. Server errors and panics are
+// not stored, so the request can be retried with the same key. Requests
+// without the header run as usual.
+func (h *RestaurantHandler) idempotent(c *fiber.Ctx, operation string, handle fiber.Handler) error {
+	key := c.Get(IdempotencyKeyHeader)
+	if key == "" {
+		return handle(c)
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid idempotency key", "Idempotency-Key must be at most 255 characters"))
+	}
+	// Header values point into fiber's request buffer, which is reused
+	key = utils.CopyString(key)
+
+	fingerprint := sha256.New()
+	for _, part := range [][]byte{[]byte(operation), []byte(c.Params("bookingID")), canonicalJSON(c.Body())} {
+		fingerprint.Write(part)
+		fingerprint.Write([]byte{0})
+	}
+
+	record, err := h.service.ClaimIdempotencyKey(key, hex.EncodeToString(fingerprint.Sum(nil)))
+	if err != nil {
+		if err == errors.ErrIdempotencyKeyReused {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(NewErrorResponse("Idempotent request failed", err.Error()))
+		}
+		if err == errors.ErrIdempotencyInProgress {
+			return c.Status(fiber.StatusConflict).JSON(NewErrorResponse("Idempotent request failed", err.Error()))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse("Idempotent request failed", err.Error()))
+	}
+	if record != nil {
+		c.Set(IdempotentReplayedHeader, "true")
+		c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		return c.Status(record.StatusCode).Send(record.Body)
+	}
+
+	// A handler that panics never reaches the release below, so free the
+	// key on the way out before the recover middleware turns it into a 500
+	defer func() {
+		if r := recover(); r != nil {
+			h.releaseIdempotencyKey(key)
+			panic(r)
+		}
+	}()
+	if err := handle(c); err != nil {
+		h.releaseIdempotencyKey(key)
+		return err
+	}
+	status := c.Response().StatusCode()
+	if status >= fiber.StatusInternalServerError {
+		h.releaseIdempotencyKey(key)
+		return nil
+	}
+	// If the response cannot be stored the key stays claimed until it
+	// expires, since running the request again could book twice
+	if err := h.service.SaveIdempotentResponse(key, status, utils.CopyBytes(c.Response().Body())); err != nil {
+		h.logger.Error(fmt.Sprintf("Failed to store the response for idempotency key %q, retries get 409 until it expires: %v", key, err))
+	}
+	return nil
+}
+
+// releaseIdempotencyKey frees key for a retry, logging if that fails since
+// retries then get 409 until the key expires
+func (h *RestaurantHandler) releaseIdempotencyKey(key string) {
+	if err := h.service.ReleaseIdempotencyKey(key); err != nil {
+		h.logger.Error(fmt.Sprintf("Failed to release idempotency key %q, retries get 409 until it expires: %v", key, err))
+	}
+}
+
+// canonicalJSON re-encodes a JSON body with sorted keys and no whitespace, so
+// the same request sent with its fields in another order or laid out
+// differently has the same fingerprint. Bodies that are not JSON are kept as
+// they are.
+func canonicalJSON(body []byte) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// Numbers keep their digits instead of passing through float64
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return body
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return canonical
+}
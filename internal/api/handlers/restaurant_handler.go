@@ -1,38 +1,101 @@
 package handlers
 
 import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"booking-dinner/internal/domain/models"
 	"booking-dinner/internal/domain/restaurant"
 	"booking-dinner/internal/errors"
+	"booking-dinner/pkg/logger"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type RestaurantHandler struct {
 	service restaurant.Service
+	logger  *logger.Logger
 }
 
-func NewRestaurantHandler(service restaurant.Service) *RestaurantHandler {
+func NewRestaurantHandler(service restaurant.Service, logger *logger.Logger) *RestaurantHandler {
 	return &RestaurantHandler{
 		service: service,
+		logger:  logger,
 	}
 }
 
+// tableRequest describes one table in an initialization request. Capacity
+// is nil when omitted, which gives the default number of seats.
+type tableRequest struct {
+	ID       string   `json:"id"`
+	Capacity *int     `json:"capacity"`
+	Group    string   `json:"group"`
+	Adjacent []string `json:"adjacent"`
+	Section  string   `json:"section"`
+}
+
+// table returns the requested table, or an error if a capacity was given but
+// is not positive. An omitted capacity is left zero for the service to fill
+// in.
+func (r tableRequest) table() (models.Table, error) {
+	capacity := 0
+	if r.Capacity != nil {
+		capacity = *r.Capacity
+		if capacity <= 0 {
+			return models.Table{}, errors.NewValidationError(fmt.Sprintf("Table %s must have between 1 and %d seats", r.ID, restaurant.MaxTableCapacity))
+		}
+	}
+
+	table := *models.NewTable(r.ID, capacity)
+	table.JoinGroup = r.Group
+	table.Adjacent = r.Adjacent
+	table.Section = r.Section
+	return table, nil
+}
+
+// InitializeTables accepts either a table count, e.g. {"tables": 10}, which
+// creates tables with the default number of seats, or a list of tables with
+// individual capacities, e.g. {"tables": [{"id": "A1", "capacity": 2}]}.
+// Listed tables may also give the floor plan: a joinable group of tables
+// that can all be pushed together, e.g. "group": "patio", or the tables they
+// can be pushed against, e.g. "adjacent": ["A2"], and the section they stand
+// in, e.g. "section": "terrace".
 func (h *RestaurantHandler) InitializeTables(c *fiber.Ctx) error {
 	var request struct {
-		Tables int `json:"tables"`
+		Tables json.RawMessage `json:"tables"`
 	}
 
 	if err := c.BodyParser(&request); err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid request body", err.Error()))
 	}
 
-	if request.Tables <= 0 {
-		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid number of tables", "Number of tables must be positive"))
+	var numTables int
+	var tableList []tableRequest
+	var err error
+	if json.Unmarshal(request.Tables, &numTables) == nil {
+		if numTables <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid number of tables", "Number of tables must be positive"))
+		}
+		err = h.service.InitializeTableCount(numTables)
+	} else if json.Unmarshal(request.Tables, &tableList) == nil {
+		if len(tableList) == 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid number of tables", "Number of tables must be positive"))
+		}
+		tables := make([]models.Table, len(tableList))
+		for i, request := range tableList {
+			if tables[i], err = request.table(); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Initialization error", err.Error()))
+			}
+		}
+		err = h.service.InitializeTables(tables)
+	} else {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid request body", "tables must be a number or a list of tables"))
 	}
 
-	err := h.service.InitializeTables(request.Tables)
 	if err != nil {
-		if err == errors.ErrTableInitialized {
+		if err == errors.ErrTableInitialized || errors.IsInitializationError(err) {
 			return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Initialization error", err.Error()))
 		}
 		return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse("Initialization failed", err.Error()))
@@ -41,9 +104,22 @@ func (h *RestaurantHandler) InitializeTables(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Tables initialized successfully", nil))
 }
 
+// ReserveTables books tables once per Idempotency-Key, see idempotent
 func (h *RestaurantHandler) ReserveTables(c *fiber.Ctx) error {
+	return h.idempotent(c, "reserve", h.reserveTables)
+}
+
+func (h *RestaurantHandler) reserveTables(c *fiber.Ctx) error {
 	var request struct {
-		Customers int `json:"customers"`
+		Customers       int       `json:"customers"`
+		BookingTime     time.Time `json:"bookingTime"`
+		Name            string    `json:"name"`
+		Phone           string    `json:"phone"`
+		Email           string    `json:"email"`
+		SpecialRequests string    `json:"specialRequests"`
+		DietaryNotes    string    `json:"dietaryNotes"`
+		Section         string    `json:"section"`
+		SectionStrict   bool      `json:"sectionStrict"`
 	}
 
 	if err := c.BodyParser(&request); err != nil {
@@ -54,22 +130,42 @@ func (h *RestaurantHandler) ReserveTables(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid number of customers", "Number of customers must be positive"))
 	}
 
-	bookingID, tablesBooked, remainingTables, err := h.service.ReserveTables(request.Customers)
+	customer := models.CustomerDetails{
+		CustomerName:    request.Name,
+		Phone:           request.Phone,
+		Email:           request.Email,
+		SpecialRequests: request.SpecialRequests,
+		DietaryNotes:    request.DietaryNotes,
+	}
+
+	section := models.SectionPreference{Section: request.Section, Strict: request.SectionStrict}
+	booking, remainingTables, err := h.service.ReserveTables(request.Customers, request.BookingTime, customer, section)
 	if err != nil {
-		if err == errors.ErrInsufficientTables {
-			return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Reservation failed", err.Error()))
+		if errors.IsNoTablesError(err) || errors.IsValidationError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(validationResponse("Reservation failed", err))
 		}
 		return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse("Reservation failed", err.Error()))
 	}
 
 	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Reservation successful", fiber.Map{
-		"bookingID":       bookingID,
-		"tablesBooked":    tablesBooked,
+		"bookingID":       booking.ID,
+		"bookingTime":     booking.BookingTime,
+		"tablesBooked":    booking.TablesBooked(),
+		"tableIDs":        booking.TableIDs,
+		"seatsAssigned":   booking.SeatsAssigned,
+		"seatUtilization": booking.SeatUtilization(),
+		"section":         booking.Section,
 		"remainingTables": remainingTables,
 	}))
 }
 
+// CancelReservation cancels a booking once per Idempotency-Key, see
+// idempotent
 func (h *RestaurantHandler) CancelReservation(c *fiber.Ctx) error {
+	return h.idempotent(c, "cancel", h.cancelReservation)
+}
+
+func (h *RestaurantHandler) cancelReservation(c *fiber.Ctx) error {
 	var request struct {
 		BookingID string `json:"bookingID"`
 	}
@@ -83,6 +179,12 @@ func (h *RestaurantHandler) CancelReservation(c *fiber.Ctx) error {
 		if err == errors.ErrInvalidBookingID {
 			return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse("Cancellation failed", err.Error()))
 		}
+		if err == errors.ErrInvalidCheckCharacter {
+			return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Cancellation failed", err.Error()))
+		}
+		if errors.IsTransitionError(err) {
+			return c.Status(fiber.StatusConflict).JSON(NewErrorResponse("Cancellation failed", err.Error()))
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse("Cancellation failed", err.Error()))
 	}
 
@@ -91,3 +193,627 @@ func (h *RestaurantHandler) CancelReservation(c *fiber.Ctx) error {
 		"remainingTables": remainingTables,
 	}))
 }
+
+// SeatBooking marks a booking as seated
+func (h *RestaurantHandler) SeatBooking(c *fiber.Ctx) error {
+	booking, err := h.service.SeatBooking(c.Params("bookingID"))
+	if err != nil {
+		return transitionError(c, "Seating failed", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Booking seated", bookingResponse(booking)))
+}
+
+// CompleteBooking marks a seated booking as completed, freeing its tables
+func (h *RestaurantHandler) CompleteBooking(c *fiber.Ctx) error {
+	booking, remainingTables, err := h.service.CompleteBooking(c.Params("bookingID"))
+	if err != nil {
+		return transitionError(c, "Completion failed", err)
+	}
+
+	response := bookingResponse(booking)
+	response["remainingTables"] = remainingTables
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Booking completed", response))
+}
+
+// MarkNoShow marks a booking whose party never arrived, freeing its tables
+func (h *RestaurantHandler) MarkNoShow(c *fiber.Ctx) error {
+	booking, remainingTables, err := h.service.MarkNoShow(c.Params("bookingID"))
+	if err != nil {
+		return transitionError(c, "Marking no-show failed", err)
+	}
+
+	response := bookingResponse(booking)
+	response["remainingTables"] = remainingTables
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Booking marked as no-show", response))
+}
+
+// transitionError responds to an error from a booking status change
+func transitionError(c *fiber.Ctx, message string, err error) error {
+	if err == errors.ErrInvalidBookingID {
+		return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse(message, err.Error()))
+	}
+	if err == errors.ErrInvalidCheckCharacter {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(message, err.Error()))
+	}
+	if errors.IsTransitionError(err) || err == errors.ErrTableOccupied {
+		return c.Status(fiber.StatusConflict).JSON(NewErrorResponse(message, err.Error()))
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse(message, err.Error()))
+}
+
+// ModifyReservation changes the party size and/or time of a booking, e.g.
+// {"customers": 6} or {"bookingTime": "2024-10-18T20:00:00+07:00"}, once per
+// Idempotency-Key, see idempotent
+func (h *RestaurantHandler) ModifyReservation(c *fiber.Ctx) error {
+	return h.idempotent(c, "modify", h.modifyReservation)
+}
+
+func (h *RestaurantHandler) modifyReservation(c *fiber.Ctx) error {
+	var request struct {
+		Customers   int       `json:"customers"`
+		BookingTime time.Time `json:"bookingTime"`
+	}
+
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid request body", err.Error()))
+	}
+
+	if request.Customers < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid number of customers", "Number of customers must be positive"))
+	}
+
+	booking, remainingTables, err := h.service.ModifyReservation(c.Params("bookingID"), request.Customers, request.BookingTime)
+	if err != nil {
+		if err == errors.ErrInvalidBookingID {
+			return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse("Modification failed", err.Error()))
+		}
+		if errors.IsTransitionError(err) {
+			return c.Status(fiber.StatusConflict).JSON(NewErrorResponse("Modification failed", err.Error()))
+		}
+		if errors.IsNoTablesError(err) || err == errors.ErrInvalidCheckCharacter || errors.IsValidationError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(validationResponse("Modification failed", err))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse("Modification failed", err.Error()))
+	}
+
+	response := bookingResponse(booking)
+	response["seatUtilization"] = booking.SeatUtilization()
+	response["remainingTables"] = remainingTables
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Reservation modified successfully", response))
+}
+
+// SetTurnTime lets a manager set how long a booking keeps its tables, e.g.
+// {"minutes": 150}. Zero minutes goes back to the configured turn time rules.
+func (h *RestaurantHandler) SetTurnTime(c *fiber.Ctx) error {
+	var request struct {
+		Minutes int `json:"minutes"`
+	}
+
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid request body", err.Error()))
+	}
+
+	booking, remainingTables, err := h.service.SetTurnTime(c.Params("bookingID"), time.Duration(request.Minutes)*time.Minute)
+	if err != nil {
+		if err == errors.ErrInvalidBookingID {
+			return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse("Changing the turn time failed", err.Error()))
+		}
+		if errors.IsTransitionError(err) {
+			return c.Status(fiber.StatusConflict).JSON(NewErrorResponse("Changing the turn time failed", err.Error()))
+		}
+		if errors.IsNoTablesError(err) || err == errors.ErrInvalidCheckCharacter || errors.IsValidationError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Changing the turn time failed", err.Error()))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse("Changing the turn time failed", err.Error()))
+	}
+
+	response := bookingResponse(booking)
+	response["remainingTables"] = remainingTables
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Turn time changed", response))
+}
+
+func (h *RestaurantHandler) GetBooking(c *fiber.Ctx) error {
+	booking, err := h.service.GetBooking(c.Params("bookingID"))
+	if err != nil {
+		if err == errors.ErrInvalidBookingID {
+			return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse("Booking not found", err.Error()))
+		}
+		if err == errors.ErrInvalidCheckCharacter {
+			return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Booking not found", err.Error()))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse("Booking lookup failed", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Booking found", bookingResponse(booking)))
+}
+
+// ListBookings returns a page of bookings in booking time order. The query
+// parameters status, from, to (RFC 3339), minCustomers, maxCustomers,
+// customer, phone and email filter the bookings; cursor and limit page
+// through them.
+func (h *RestaurantHandler) ListBookings(c *fiber.Ctx) error {
+	filter := models.BookingFilter{
+		Status:       c.Query("status"),
+		CustomerName: c.Query("customer"),
+		Phone:        c.Query("phone"),
+		Email:        c.Query("email"),
+	}
+
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid query", err.Error()))
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid query", err.Error()))
+	}
+	if filter.MinCustomers, err = parseIntQuery(c, "minCustomers"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid query", err.Error()))
+	}
+	if filter.MaxCustomers, err = parseIntQuery(c, "maxCustomers"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid query", err.Error()))
+	}
+	limit, err := parseIntQuery(c, "limit")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid query", err.Error()))
+	}
+
+	bookings, nextCursor, err := h.service.ListBookings(filter, c.Query("cursor"), limit)
+	if err != nil {
+		if errors.IsValidationError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid query", err.Error()))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse("Listing bookings failed", err.Error()))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Bookings found", fiber.Map{
+		"bookings":   bookingResponses(bookings),
+		"nextCursor": nextCursor,
+	}))
+}
+
+// GetAvailability lists the times a party can still be booked on a date,
+// e.g. ?date=2024-10-18&party=4, optionally only in one section with
+// &section=terrace
+func (h *RestaurantHandler) GetAvailability(c *fiber.Ctx) error {
+	date := c.Query("date")
+	if date == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid query", "date is required"))
+	}
+	party, err := parseIntQuery(c, "party")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid query", err.Error()))
+	}
+
+	slots, err := h.service.GetAvailability(date, party, c.Query("section"))
+	if err != nil {
+		if errors.IsValidationError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid query", err.Error()))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse("Availability search failed", err.Error()))
+	}
+
+	results := make([]fiber.Map, len(slots))
+	for i, slot := range slots {
+		results[i] = fiber.Map{
+			"time":            slot.Time,
+			"availableTables": slot.AvailableTables,
+			"availableSeats":  slot.AvailableSeats,
+		}
+	}
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Availability found", fiber.Map{
+		"date":  date,
+		"party": party,
+		"slots": results,
+	}))
+}
+
+// SeatWalkIn seats a party without a reservation at a table, e.g.
+// {"customers": 2}
+func (h *RestaurantHandler) SeatWalkIn(c *fiber.Ctx) error {
+	var request struct {
+		Customers int `json:"customers"`
+	}
+
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid request body", err.Error()))
+	}
+
+	table, err := h.service.SeatWalkIn(c.Params("tableID"), request.Customers)
+	if err != nil {
+		return tableError(c, "Seating failed", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Walk-in seated", tableResponse(models.TableStatus{Table: table})))
+}
+
+// ClearTable marks a table as free once its party has left
+func (h *RestaurantHandler) ClearTable(c *fiber.Ctx) error {
+	table, err := h.service.ClearTable(c.Params("tableID"))
+	if err != nil {
+		return tableError(c, "Clearing failed", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Table cleared", tableResponse(models.TableStatus{Table: table})))
+}
+
+// AddTable adds a table to the running restaurant, e.g. {"id": "A9",
+// "capacity": 6, "section": "terrace"}, with the same fields a table listed
+// on initialization takes. Without an ID the next free T number is used.
+func (h *RestaurantHandler) AddTable(c *fiber.Ctx) error {
+	var request tableRequest
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid request body", err.Error()))
+	}
+
+	table, err := request.table()
+	if err != nil {
+		return tableError(c, "Adding the table failed", err)
+	}
+	table, err = h.service.AddTable(table)
+	if err != nil {
+		return tableError(c, "Adding the table failed", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Table added", tableResponse(models.TableStatus{Table: table})))
+}
+
+// RetireTable takes a table out of service, moving its upcoming bookings to
+// other tables. If some cannot be moved the request fails with the bookings
+// listed unless ?force=true is given, which leaves them on the retired table.
+func (h *RestaurantHandler) RetireTable(c *fiber.Ctx) error {
+	change, err := h.service.RetireTable(c.Params("tableID"), c.QueryBool("force"))
+	return tableChangeResult(c, "Table retired", "Retiring the table failed", change, err)
+}
+
+// RemoveTable deletes a table like RetireTable retires it. A table that
+// bookings still refer to is kept as retired, which the response reports as
+// deleted being false.
+func (h *RestaurantHandler) RemoveTable(c *fiber.Ctx) error {
+	change, err := h.service.RemoveTable(c.Params("tableID"), c.QueryBool("force"))
+	return tableChangeResult(c, "Table removed", "Removing the table failed", change, err)
+}
+
+// tableChangeResult responds to retiring or removing a table. A refusal
+// lists the bookings that would have been stranded in its details.
+func tableChangeResult(c *fiber.Ctx, message string, failure string, change models.TableChange, err error) error {
+	if err == errors.ErrTableHasBookings {
+		response := NewErrorResponse(failure, err.Error())
+		response.Details = fiber.Map{"strandedBookings": bookingResponses(change.Stranded)}
+		return c.Status(fiber.StatusConflict).JSON(response)
+	}
+	if err != nil {
+		return tableError(c, failure, err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse(message, fiber.Map{
+		"tableID":          change.TableID,
+		"deleted":          change.Deleted,
+		"movedBookings":    bookingResponses(change.Moved),
+		"strandedBookings": bookingResponses(change.Stranded),
+	}))
+}
+
+// GetFloorStatus lists every table with its current state
+func (h *RestaurantHandler) GetFloorStatus(c *fiber.Ctx) error {
+	statuses, err := h.service.GetFloorStatus()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse("Floor status failed", err.Error()))
+	}
+
+	tables := make([]fiber.Map, len(statuses))
+	for i, status := range statuses {
+		tables[i] = tableResponse(status)
+	}
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Floor status", fiber.Map{
+		"tables": tables,
+	}))
+}
+
+// HoldTables keeps tables for a party while the guest fills in their
+// details, e.g. {"customers": 4, "bookingTime": "2024-10-18T19:00:00+07:00"}
+func (h *RestaurantHandler) HoldTables(c *fiber.Ctx) error {
+	var request struct {
+		Customers     int       `json:"customers"`
+		BookingTime   time.Time `json:"bookingTime"`
+		Section       string    `json:"section"`
+		SectionStrict bool      `json:"sectionStrict"`
+	}
+
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid request body", err.Error()))
+	}
+
+	section := models.SectionPreference{Section: request.Section, Strict: request.SectionStrict}
+	booking, remainingTables, err := h.service.HoldTables(request.Customers, request.BookingTime, section)
+	if err != nil {
+		if err == errors.ErrHoldsDisabled {
+			return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse("Hold failed", err.Error()))
+		}
+		if errors.IsNoTablesError(err) || err == errors.ErrTableNotInitialized || errors.IsValidationError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(validationResponse("Hold failed", err))
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse("Hold failed", err.Error()))
+	}
+
+	response := bookingResponse(booking)
+	response["remainingTables"] = remainingTables
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Tables held", response))
+}
+
+// ConfirmHold turns a hold into a confirmed booking with the guest's
+// details, e.g. {"name": "Anna", "phone": "0812345678"}
+func (h *RestaurantHandler) ConfirmHold(c *fiber.Ctx) error {
+	var request struct {
+		Name            string `json:"name"`
+		Phone           string `json:"phone"`
+		Email           string `json:"email"`
+		SpecialRequests string `json:"specialRequests"`
+		DietaryNotes    string `json:"dietaryNotes"`
+	}
+
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid request body", err.Error()))
+	}
+
+	customer := models.CustomerDetails{
+		CustomerName:    request.Name,
+		Phone:           request.Phone,
+		Email:           request.Email,
+		SpecialRequests: request.SpecialRequests,
+		DietaryNotes:    request.DietaryNotes,
+	}
+
+	booking, err := h.service.ConfirmHold(c.Params("bookingID"), customer)
+	if err != nil {
+		if err == errors.ErrHoldsDisabled {
+			return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse("Confirmation failed", err.Error()))
+		}
+		if err == errors.ErrHoldExpired {
+			return c.Status(fiber.StatusConflict).JSON(NewErrorResponse("Confirmation failed", err.Error()))
+		}
+		if errors.IsValidationError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Confirmation failed", err.Error()))
+		}
+		return transitionError(c, "Confirmation failed", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Reservation confirmed", bookingResponse(booking)))
+}
+
+// JoinWaitlist queues a party for the next tables that fit them, e.g.
+// {"customers": 4, "name": "Anna", "phone": "0812345678"}. Without a
+// bookingTime the party wants the first tables that free up.
+func (h *RestaurantHandler) JoinWaitlist(c *fiber.Ctx) error {
+	var request struct {
+		Customers       int       `json:"customers"`
+		BookingTime     time.Time `json:"bookingTime"`
+		Name            string    `json:"name"`
+		Phone           string    `json:"phone"`
+		Email           string    `json:"email"`
+		SpecialRequests string    `json:"specialRequests"`
+		DietaryNotes    string    `json:"dietaryNotes"`
+	}
+
+	if err := c.BodyParser(&request); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse("Invalid request body", err.Error()))
+	}
+
+	customer := models.CustomerDetails{
+		CustomerName:    request.Name,
+		Phone:           request.Phone,
+		Email:           request.Email,
+		SpecialRequests: request.SpecialRequests,
+		DietaryNotes:    request.DietaryNotes,
+	}
+
+	entry, err := h.service.JoinWaitlist(request.Customers, request.BookingTime, customer)
+	if err != nil {
+		if err == errors.ErrTableNotInitialized || errors.IsValidationError(err) {
+			return c.Status(fiber.StatusBadRequest).JSON(validationResponse("Joining the waitlist failed", err))
+		}
+		return waitlistError(c, "Joining the waitlist failed", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Added to the waitlist", waitlistResponse(entry)))
+}
+
+// GetWaitlistEntry shows whether a waitlisted party has been offered tables
+func (h *RestaurantHandler) GetWaitlistEntry(c *fiber.Ctx) error {
+	entry, err := h.service.GetWaitlistEntry(c.Params("entryID"))
+	if err != nil {
+		return waitlistError(c, "Waitlist entry not found", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Waitlist entry found", waitlistResponse(entry)))
+}
+
+// ConfirmWaitlistOffer accepts the tables offered to a waitlisted party
+func (h *RestaurantHandler) ConfirmWaitlistOffer(c *fiber.Ctx) error {
+	booking, err := h.service.ConfirmWaitlistOffer(c.Params("entryID"))
+	if err != nil {
+		return waitlistError(c, "Confirmation failed", err)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(NewSuccessResponse("Reservation confirmed", bookingResponse(booking)))
+}
+
+// validationResponse describes a rejected request. A booking time outside
+// the schedule also gets details saying why and when bookings may start.
+func validationResponse(message string, err error) Response {
+	response := NewErrorResponse(message, err.Error())
+
+	if scheduleErr, ok := errors.AsScheduleError(err); ok {
+		periods := make([]fiber.Map, len(scheduleErr.Periods))
+		for i, period := range scheduleErr.Periods {
+			periods[i] = fiber.Map{"name": period.Name, "open": period.Open, "close": period.Close}
+		}
+		response.Details = fiber.Map{
+			"reason":  scheduleErr.Reason,
+			"date":    scheduleErr.Date,
+			"periods": periods,
+		}
+	}
+	return response
+}
+
+// waitlistError responds to an error from a waitlist operation
+func waitlistError(c *fiber.Ctx, message string, err error) error {
+	if err == errors.ErrWaitlistDisabled || err == errors.ErrWaitlistEntryNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse(message, err.Error()))
+	}
+	if err == errors.ErrInvalidCheckCharacter {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(message, err.Error()))
+	}
+	if err == errors.ErrOfferExpired || errors.IsTransitionError(err) {
+		return c.Status(fiber.StatusConflict).JSON(NewErrorResponse(message, err.Error()))
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse(message, err.Error()))
+}
+
+// tableError responds to an error from an operation on a table
+func tableError(c *fiber.Ctx, message string, err error) error {
+	if err == errors.ErrTableNotFound {
+		return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse(message, err.Error()))
+	}
+	if err == errors.ErrTableOccupied || err == errors.ErrTableNotOccupied || err == errors.ErrTableReserved || err == errors.ErrTableRetired || err == errors.ErrDuplicateTableID {
+		return c.Status(fiber.StatusConflict).JSON(NewErrorResponse(message, err.Error()))
+	}
+	if errors.IsValidationError(err) || err == errors.ErrTableNotInitialized || err == errors.ErrMaxTablesExceeded {
+		return c.Status(fiber.StatusBadRequest).JSON(NewErrorResponse(message, err.Error()))
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(NewErrorResponse(message, err.Error()))
+}
+
+// tableResponse describes a table on the floor in API responses. The state
+// is occupied if someone is sitting there, reserved if a booking holds it
+// now, retired if it is out of service and free otherwise.
+func tableResponse(status models.TableStatus) fiber.Map {
+	state := "free"
+	if status.IsOccupied {
+		state = "occupied"
+	} else if status.CurrentBooking != nil {
+		state = "reserved"
+	} else if status.Retired {
+		state = "retired"
+	}
+
+	response := fiber.Map{
+		"tableID":  status.ID,
+		"capacity": status.Capacity,
+		"state":    state,
+	}
+	if status.Section != "" {
+		response["section"] = status.Section
+	}
+	if status.JoinGroup != "" {
+		response["group"] = status.JoinGroup
+	}
+	if len(status.Adjacent) > 0 {
+		response["adjacent"] = status.Adjacent
+	}
+	if status.IsOccupied {
+		response["partySize"] = status.PartySize
+		response["occupiedSince"] = status.OccupiedSince
+	}
+	if status.CurrentBooking != nil {
+		response["currentBooking"] = bookingResponse(*status.CurrentBooking)
+	}
+	if status.NextBooking != nil {
+		response["nextBooking"] = bookingResponse(*status.NextBooking)
+	}
+	return response
+}
+
+// bookingResponse describes a booking in API responses
+func bookingResponse(booking models.Booking) fiber.Map {
+	response := fiber.Map{
+		"bookingID":       booking.ID,
+		"customers":       booking.NumCustomers,
+		"name":            booking.CustomerName,
+		"phone":           booking.Phone,
+		"email":           booking.Email,
+		"specialRequests": booking.SpecialRequests,
+		"dietaryNotes":    booking.DietaryNotes,
+		"bookingTime":     booking.BookingTime,
+		"endTime":         booking.EndTime(),
+		"turnTimeMinutes": int(booking.Duration / time.Minute),
+		"tablesBooked":    booking.TablesBooked(),
+		"tableIDs":        booking.TableIDs,
+		"seatsAssigned":   booking.SeatsAssigned,
+		"status":          booking.Status,
+	}
+	if !booking.HoldExpiresAt.IsZero() {
+		response["holdExpiresAt"] = booking.HoldExpiresAt
+	}
+	if booking.TurnTimeOverride > 0 {
+		response["turnTimeOverridden"] = true
+	}
+	if booking.Section != "" {
+		response["section"] = booking.Section
+	}
+	if booking.SectionPreference.Section != "" {
+		response["requestedSection"] = booking.SectionPreference.Section
+	}
+	return response
+}
+
+// bookingResponses describes a list of bookings in API responses
+func bookingResponses(bookings []models.Booking) []fiber.Map {
+	responses := make([]fiber.Map, len(bookings))
+	for i, booking := range bookings {
+		responses[i] = bookingResponse(booking)
+	}
+	return responses
+}
+
+// waitlistResponse describes a waitlist entry in API responses
+func waitlistResponse(entry models.WaitlistEntry) fiber.Map {
+	response := fiber.Map{
+		"entryID":         entry.ID,
+		"customers":       entry.NumCustomers,
+		"name":            entry.CustomerName,
+		"phone":           entry.Phone,
+		"email":           entry.Email,
+		"specialRequests": entry.SpecialRequests,
+		"dietaryNotes":    entry.DietaryNotes,
+		"status":          entry.Status,
+		"joinedAt":        entry.CreatedAt,
+	}
+	if !entry.BookingTime.IsZero() {
+		response["bookingTime"] = entry.BookingTime
+	}
+	if entry.BookingID != "" {
+		response["bookingID"] = entry.BookingID
+	}
+	if !entry.OfferExpiresAt.IsZero() {
+		response["offerExpiresAt"] = entry.OfferExpiresAt
+	}
+	return response
+}
+
+// parseTimeQuery parses an optional RFC 3339 query parameter
+func parseTimeQuery(c *fiber.Ctx, key string) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.NewValidationError(key + " must be an RFC 3339 time")
+	}
+	return t, nil
+}
+
+// parseIntQuery parses an optional integer query parameter
+func parseIntQuery(c *fiber.Ctx, key string) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.NewValidationError(key + " must be an integer")
+	}
+	return n, nil
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// TenantHandler serves several restaurants, passing each request to the
+// handler of the restaurant named by the restaurantID route parameter
+type TenantHandler struct {
+	restaurants map[string]Handler
+}
+
+func NewTenantHandler(restaurants map[string]Handler) *TenantHandler {
+	return &TenantHandler{
+		restaurants: restaurants,
+	}
+}
+
+// dispatch passes the request to the restaurant's handler method
+func (h *TenantHandler) dispatch(c *fiber.Ctx, method func(Handler, *fiber.Ctx) error) error {
+	handler, ok := h.restaurants[c.Params("restaurantID")]
+	if !ok {
+		return c.Status(fiber.StatusNotFound).JSON(NewErrorResponse("Restaurant not found", "No restaurant has this ID"))
+	}
+	return method(handler, c)
+}
+
+func (h *TenantHandler) InitializeTables(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.InitializeTables)
+}
+
+func (h *TenantHandler) ReserveTables(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.ReserveTables)
+}
+
+func (h *TenantHandler) CancelReservation(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.CancelReservation)
+}
+
+func (h *TenantHandler) SeatBooking(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.SeatBooking)
+}
+
+func (h *TenantHandler) CompleteBooking(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.CompleteBooking)
+}
+
+func (h *TenantHandler) MarkNoShow(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.MarkNoShow)
+}
+
+func (h *TenantHandler) ModifyReservation(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.ModifyReservation)
+}
+
+func (h *TenantHandler) SetTurnTime(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.SetTurnTime)
+}
+
+func (h *TenantHandler) GetBooking(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.GetBooking)
+}
+
+func (h *TenantHandler) ListBookings(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.ListBookings)
+}
+
+func (h *TenantHandler) GetAvailability(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.GetAvailability)
+}
+
+func (h *TenantHandler) SeatWalkIn(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.SeatWalkIn)
+}
+
+func (h *TenantHandler) ClearTable(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.ClearTable)
+}
+
+func (h *TenantHandler) GetFloorStatus(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.GetFloorStatus)
+}
+
+func (h *TenantHandler) AddTable(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.AddTable)
+}
+
+func (h *TenantHandler) RetireTable(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.RetireTable)
+}
+
+func (h *TenantHandler) RemoveTable(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.RemoveTable)
+}
+
+func (h *TenantHandler) JoinWaitlist(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.JoinWaitlist)
+}
+
+func (h *TenantHandler) GetWaitlistEntry(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.GetWaitlistEntry)
+}
+
+func (h *TenantHandler) ConfirmWaitlistOffer(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.ConfirmWaitlistOffer)
+}
+
+func (h *TenantHandler) HoldTables(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.HoldTables)
+}
+
+func (h *TenantHandler) ConfirmHold(c *fiber.Ctx) error {
+	return h.dispatch(c, Handler.ConfirmHold)
+}
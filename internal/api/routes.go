@@ -7,8 +7,10 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-// SetupRoutes configures the routes for the API
-func SetupRoutes(app *fiber.App, handler handlers.Handler) {
+// SetupRoutes configures the routes for the API. Every restaurant in
+// restaurants is served under /restaurants/:restaurantID, and handler, the
+// default restaurant, is also served without the prefix.
+func SetupRoutes(app *fiber.App, handler handlers.Handler, restaurants handlers.Handler) {
 	// API group
 	api := app.Group("/api")
 	api = api.Group("/v1")
@@ -19,14 +21,39 @@ func SetupRoutes(app *fiber.App, handler handlers.Handler) {
 	api.Use(middleware.Recover())
 
 	// Routes
-	api.Post("/initialize", handler.InitializeTables)
-	api.Post("/reserve", handler.ReserveTables)
-	api.Post("/cancel", handler.CancelReservation)
+	registerRoutes(api, handler)
+	registerRoutes(api.Group("/restaurants/:restaurantID"), restaurants)
 
 	// Health check
 	api.Get("/health", HealthCheck)
 }
 
+// registerRoutes adds the restaurant routes to router
+func registerRoutes(router fiber.Router, handler handlers.Handler) {
+	router.Post("/initialize", handler.InitializeTables)
+	router.Post("/reserve", handler.ReserveTables)
+	router.Post("/cancel", handler.CancelReservation)
+	router.Get("/availability", handler.GetAvailability)
+	router.Get("/bookings", handler.ListBookings)
+	router.Get("/bookings/:bookingID", handler.GetBooking)
+	router.Patch("/bookings/:bookingID", handler.ModifyReservation)
+	router.Put("/bookings/:bookingID/turn-time", handler.SetTurnTime)
+	router.Post("/bookings/:bookingID/seat", handler.SeatBooking)
+	router.Post("/bookings/:bookingID/complete", handler.CompleteBooking)
+	router.Post("/bookings/:bookingID/no-show", handler.MarkNoShow)
+	router.Get("/tables", handler.GetFloorStatus)
+	router.Post("/tables", handler.AddTable)
+	router.Delete("/tables/:tableID", handler.RemoveTable)
+	router.Post("/tables/:tableID/retire", handler.RetireTable)
+	router.Post("/tables/:tableID/seat", handler.SeatWalkIn)
+	router.Post("/tables/:tableID/clear", handler.ClearTable)
+	router.Post("/holds", handler.HoldTables)
+	router.Post("/holds/:bookingID/confirm", handler.ConfirmHold)
+	router.Post("/waitlist", handler.JoinWaitlist)
+	router.Get("/waitlist/:entryID", handler.GetWaitlistEntry)
+	router.Post("/waitlist/:entryID/confirm", handler.ConfirmWaitlistOffer)
+}
+
 // HealthCheck handler for the health check endpoint
 func HealthCheck(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusOK).JSON(fiber.Map{
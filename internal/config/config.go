@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"regexp"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -10,7 +12,10 @@ type Config struct {
 	Server     ServerConfig
 	Logger     LoggerConfig
 	Restaurant RestaurantConfig
-	Database   DatabaseConfig
+	// Restaurants are further restaurants served alongside Restaurant, each
+	// taking the settings it leaves out from Restaurant
+	Restaurants []RestaurantConfig `mapstructure:"-"`
+	Database    DatabaseConfig
 }
 
 type ServerConfig struct {
@@ -23,21 +28,125 @@ type LoggerConfig struct {
 }
 
 type RestaurantConfig struct {
-	Name          string
-	MaxTables     int
-	SeatsPerTable int
-	Code          CodeConfig
+	// ID identifies the restaurant in URLs and keeps its data apart from
+	// the other restaurants'
+	ID                  string
+	Name                string
+	MaxTables           int
+	SeatsPerTable       int
+	ReservationDuration time.Duration
+	// TurnTimes are checked in order and the first matching rule sets how
+	// long a booking keeps its tables; bookings no rule matches keep them
+	// for ReservationDuration
+	TurnTimes []TurnTimeConfig
+	// SlotInterval is the spacing of the times availability searches offer
+	SlotInterval time.Duration
+	Allocation   AllocationConfig
+	Code         CodeConfig
+	Waitlist     WaitlistConfig
+	Holds        HoldsConfig
+	Idempotency  IdempotencyConfig
+	Schedule     ScheduleConfig
+}
+
+type TurnTimeConfig struct {
+	// MinParty and MaxParty bound the party sizes the rule applies to; a zero
+	// MaxParty has no upper bound
+	MinParty int
+	MaxParty int
+	// Period, if set, limits the rule to bookings in that service period
+	Period   string
+	Duration time.Duration
+}
+
+type AllocationConfig struct {
+	Strategy       string
+	LargePartySize int
 }
 
 type CodeConfig struct {
-	Length  int
-	Charset string
+	Length           int
+	Charset          string
+	ExcludeAmbiguous bool
+	CheckCharacter   bool
+}
+
+type WaitlistConfig struct {
+	Enabled bool
+	// OfferHold is how long tables offered to a waitlisted party are held
+	// for them to confirm
+	OfferHold time.Duration
+}
+
+type HoldsConfig struct {
+	Enabled bool
+	// TTL is how long a hold keeps its tables before it must be confirmed
+	TTL time.Duration
+	// ReapInterval is how often lapsed holds and waitlist offers are released
+	ReapInterval time.Duration
+}
+
+type IdempotencyConfig struct {
+	Enabled bool
+	// TTL is how long the response to a request with an Idempotency-Key is
+	// kept and replayed for repeats of the request
+	TTL time.Duration
+}
+
+type ScheduleConfig struct {
+	Enabled bool
+	// Timezone is the IANA time zone the hours and dates are given in
+	Timezone string
+	// Periods are the service periods, such as lunch and dinner
+	Periods []ServicePeriodConfig
+	// Weekly lists the periods served on each day, keyed by lowercase day
+	// name; days left out are closed
+	Weekly   map[string][]string
+	Closures []ClosureConfig
+	Events   []SpecialEventConfig
+}
+
+type ServicePeriodConfig struct {
+	Name string
+	Open string
+	// Close is the last time a booking may start
+	Close string
+}
+
+type ClosureConfig struct {
+	Date   string
+	Reason string
+}
+
+type SpecialEventConfig struct {
+	Name string
+	Date string
+	// Open and Close, if set, replace the day's usual service periods
+	Open  string
+	Close string
+	// Tables, if set, are the only tables bookable online that day
+	Tables []string
 }
 
 type DatabaseConfig struct {
 	Type string
+	Path string
+	DSN  string
 }
 
+// DefaultRestaurantID is the ID of Restaurant when it does not give one
+const DefaultRestaurantID = "default"
+
+// restaurantIDPattern keeps restaurant IDs safe to use in URLs
+var restaurantIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Supported database types
+const (
+	DatabaseInMemory = "in-memory"
+	DatabaseSQLite   = "sqlite"
+	DatabasePostgres = "postgres"
+)
+
 // LoadConfig reads configuration from file or environment variables
 func LoadConfig() (*Config, error) {
 	viper.SetConfigName("config")
@@ -55,6 +164,14 @@ func LoadConfig() (*Config, error) {
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
+	if config.Restaurant.ID == "" {
+		config.Restaurant.ID = DefaultRestaurantID
+	}
+	restaurants, err := loadRestaurants()
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	config.Restaurants = restaurants
 
 	// Validate config host and port
 	if err := validateConfig(&config); err != nil {
@@ -64,6 +181,47 @@ func LoadConfig() (*Config, error) {
 	return &config, nil
 }
 
+// AllRestaurants returns Restaurant followed by the further Restaurants
+func (c *Config) AllRestaurants() []RestaurantConfig {
+	return append([]RestaurantConfig{c.Restaurant}, c.Restaurants...)
+}
+
+// loadRestaurants reads the restaurants list, merging each entry over the
+// restaurant settings so an entry only needs to give what differs. Nested
+// sections such as code are merged key by key.
+func loadRestaurants() ([]RestaurantConfig, error) {
+	entries, ok := viper.Get("restaurants").([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	restaurants := make([]RestaurantConfig, len(entries))
+	for i, entry := range entries {
+		settings, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("restaurants entry %d is not a map", i)
+		}
+		// Merging changes nested maps in place, so every entry starts from
+		// a fresh copy of the restaurant settings
+		base := map[string]interface{}{}
+		if restaurant := viper.Sub("restaurant"); restaurant != nil {
+			base = restaurant.AllSettings()
+		}
+		delete(base, "id")
+		merged := viper.New()
+		if err := merged.MergeConfigMap(base); err != nil {
+			return nil, err
+		}
+		if err := merged.MergeConfigMap(settings); err != nil {
+			return nil, err
+		}
+		if err := merged.Unmarshal(&restaurants[i]); err != nil {
+			return nil, err
+		}
+	}
+	return restaurants, nil
+}
+
 // validateConfig checks if the config is valid
 func validateConfig(config *Config) error {
 	if config.Server.Port == 0 {
@@ -72,5 +230,57 @@ func validateConfig(config *Config) error {
 	if config.Server.Host == "" {
 		return fmt.Errorf("database host is required")
 	}
+	switch config.Database.Type {
+	case DatabaseInMemory:
+	case DatabaseSQLite:
+		if config.Database.Path == "" {
+			return fmt.Errorf("database path is required for sqlite")
+		}
+	case DatabasePostgres:
+		if config.Database.DSN == "" {
+			return fmt.Errorf("database dsn is required for postgres")
+		}
+	default:
+		return fmt.Errorf("unsupported database type %q", config.Database.Type)
+	}
+	seen := make(map[string]bool)
+	for _, restaurant := range config.AllRestaurants() {
+		if !restaurantIDPattern.MatchString(restaurant.ID) {
+			return fmt.Errorf("restaurant id %q must be letters, digits, '-' or '_'", restaurant.ID)
+		}
+		if seen[restaurant.ID] {
+			return fmt.Errorf("restaurant id %q is used more than once", restaurant.ID)
+		}
+		seen[restaurant.ID] = true
+		if err := validateRestaurant(restaurant); err != nil {
+			return fmt.Errorf("restaurant %s: %w", restaurant.ID, err)
+		}
+	}
+	return nil
+}
+
+// validateRestaurant checks if the settings of one restaurant are valid
+func validateRestaurant(restaurant RestaurantConfig) error {
+	if restaurant.ReservationDuration <= 0 {
+		return fmt.Errorf("restaurant reservationDuration must be positive")
+	}
+	if restaurant.SlotInterval < 0 {
+		return fmt.Errorf("restaurant slotInterval must not be negative")
+	}
+	if restaurant.Waitlist.Enabled && restaurant.Waitlist.OfferHold <= 0 {
+		return fmt.Errorf("restaurant waitlist offerHold must be positive when the waitlist is enabled")
+	}
+	if restaurant.Holds.Enabled && restaurant.Holds.TTL <= 0 {
+		return fmt.Errorf("restaurant holds ttl must be positive when holds are enabled")
+	}
+	if restaurant.Idempotency.Enabled && restaurant.Idempotency.TTL <= 0 {
+		return fmt.Errorf("restaurant idempotency ttl must be positive when idempotency is enabled")
+	}
+	if (restaurant.Holds.Enabled || restaurant.Waitlist.Enabled) && restaurant.Holds.ReapInterval <= 0 {
+		return fmt.Errorf("restaurant holds reapInterval must be positive when holds or the waitlist are enabled")
+	}
+	if restaurant.Schedule.Enabled && restaurant.Schedule.Timezone == "" {
+		return fmt.Errorf("restaurant schedule timezone is required when the schedule is enabled")
+	}
 	return nil
 }
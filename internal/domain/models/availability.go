@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// ReservedWindow is a table held by a booking from Start until End
+type ReservedWindow struct {
+	TableID string
+	Start   time.Time
+	End     time.Time
+}
+
+// Slot is a time a party can be booked at, with the tables and seats still
+// free for the whole reservation starting then
+type Slot struct {
+	Time            time.Time
+	AvailableTables int
+	AvailableSeats  int
+}
@@ -4,20 +4,101 @@ import (
 	"time"
 )
 
+// Booking statuses
+const (
+	BookingStatusPending   = "pending"
+	BookingStatusConfirmed = "confirmed"
+	BookingStatusSeated    = "seated"
+	BookingStatusCompleted = "completed"
+	BookingStatusNoShow    = "no_show"
+	BookingStatusCancelled = "cancelled"
+)
+
+// CustomerDetails describes the guest who made a booking
+type CustomerDetails struct {
+	CustomerName    string
+	Phone           string
+	Email           string
+	SpecialRequests string
+	DietaryNotes    string
+}
+
+// SectionPreference asks for tables in a named section. A strict preference
+// fails the booking if the section cannot seat the party; otherwise tables in
+// other sections are used.
+type SectionPreference struct {
+	Section string
+	Strict  bool
+}
+
 type Booking struct {
-	ID           string
-	CustomerName string
+	ID string
+	CustomerDetails
 	NumCustomers int
-	TablesBooked int
-	BookingTime  time.Time
+	TableIDs     []string
+	// SeatsAssigned is the combined capacity of the booked tables
+	SeatsAssigned int
+	BookingTime   time.Time
+	// Duration is the turn time: how long the booking keeps its tables
+	Duration time.Duration
+	// TurnTimeOverride, if set, is a turn time a manager chose for this
+	// booking instead of the one from the turn time rules
+	TurnTimeOverride time.Duration
+	Status           string
+	// HoldExpiresAt is when a pending booking stops holding its tables
+	// unless it is confirmed
+	HoldExpiresAt time.Time
+	// SectionPreference is the section the guest asked for, if any, and
+	// Section the one their tables are in, empty if they span sections
+	SectionPreference SectionPreference
+	Section           string
 }
 
-func NewBooking(id string, customerName string, numCustomers int, tablesBooked int) *Booking {
+func NewBooking(id string, customerName string, numCustomers int, tableIDs []string, bookingTime time.Time, duration time.Duration) *Booking {
 	return &Booking{
-		ID:           id,
-		CustomerName: customerName,
-		NumCustomers: numCustomers,
-		TablesBooked: tablesBooked,
-		BookingTime:  time.Now(),
+		ID:              id,
+		CustomerDetails: CustomerDetails{CustomerName: customerName},
+		NumCustomers:    numCustomers,
+		TableIDs:        tableIDs,
+		BookingTime:     bookingTime,
+		Duration:        duration,
+		Status:          BookingStatusConfirmed,
+	}
+}
+
+// TablesBooked returns the number of tables assigned to the booking
+func (b Booking) TablesBooked() int {
+	return len(b.TableIDs)
+}
+
+// SeatUtilization returns the share of the assigned seats the party fills
+func (b Booking) SeatUtilization() float64 {
+	if b.SeatsAssigned == 0 {
+		return 0
 	}
+	return float64(b.NumCustomers) / float64(b.SeatsAssigned)
+}
+
+// EndTime returns the time at which the booked tables are released
+func (b Booking) EndTime() time.Time {
+	return b.BookingTime.Add(b.Duration)
+}
+
+// HoldsTables reports whether the booking's tables are still taken at now.
+// Completed, no-show and cancelled bookings keep their table IDs as history
+// but no longer hold the tables, and neither does a hold that lapsed before
+// it was released.
+func (b Booking) HoldsTables(now time.Time) bool {
+	switch b.Status {
+	case BookingStatusPending:
+		return b.HoldExpiresAt.IsZero() || now.Before(b.HoldExpiresAt)
+	case BookingStatusConfirmed, BookingStatusSeated:
+		return true
+	}
+	return false
+}
+
+// Overlaps reports whether the booking occupies its tables at any point in [start, end)
+func (b Booking) Overlaps(start, end time.Time) bool {
+	return b.BookingTime.Before(end) && start.Before(b.EndTime())
 }
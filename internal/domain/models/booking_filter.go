@@ -0,0 +1,58 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// BookingFilter selects bookings. Zero-valued fields match every booking.
+type BookingFilter struct {
+	Status string
+	// From and To bound the booking time to [From, To)
+	From         time.Time
+	To           time.Time
+	MinCustomers int
+	MaxCustomers int
+	// CustomerName matches any part of the name, ignoring case
+	CustomerName string
+	// Phone and Email match the whole stored value
+	Phone string
+	Email string
+}
+
+// Matches reports whether the booking passes every condition in the filter
+func (f BookingFilter) Matches(b Booking) bool {
+	if f.Status != "" && b.Status != f.Status {
+		return false
+	}
+	if !f.From.IsZero() && b.BookingTime.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !b.BookingTime.Before(f.To) {
+		return false
+	}
+	if f.MinCustomers > 0 && b.NumCustomers < f.MinCustomers {
+		return false
+	}
+	if f.MaxCustomers > 0 && b.NumCustomers > f.MaxCustomers {
+		return false
+	}
+	if f.CustomerName != "" && !strings.Contains(strings.ToLower(b.CustomerName), strings.ToLower(f.CustomerName)) {
+		return false
+	}
+	if f.Phone != "" && b.Phone != f.Phone {
+		return false
+	}
+	if f.Email != "" && b.Email != f.Email {
+		return false
+	}
+	return true
+}
+
+// BookingCursor marks a position in the bookings ordered by booking time and
+// then ID. A page that starts after the cursor begins with the next booking
+// in that order.
+type BookingCursor struct {
+	BookingTime time.Time
+	ID          string
+}
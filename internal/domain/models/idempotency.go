@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// IdempotencyRecord is the response stored under an idempotency key, so a
+// retried request is answered with it instead of being run again
+type IdempotencyRecord struct {
+	Key string
+	// Fingerprint identifies the request the key was first used for
+	Fingerprint string
+	// StatusCode and Body are the stored response. A zero StatusCode means
+	// the first request is still being processed.
+	StatusCode int
+	Body       []byte
+	ExpiresAt  time.Time
+}
+
+// Completed reports whether the first request's response has been stored
+func (r IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
@@ -1,9 +1,28 @@
 package models
 
+import "time"
+
 type Table struct {
-	ID         string
-	Capacity   int
-	IsOccupied bool
+	ID       string
+	Capacity int
+	// JoinGroup names a set of tables that can all be pushed together and
+	// Adjacent lists the tables this one can be pushed against. Tables with
+	// neither are loose and can be pushed against any other loose table.
+	JoinGroup string
+	Adjacent  []string
+	// Section is the part of the restaurant the table stands in, such as
+	// "indoor" or "terrace"
+	Section string
+	// Retired tables are out of service: they are never allocated but stay in
+	// the inventory so past bookings still refer to them
+	Retired bool
+	// IsOccupied, OccupiedSince and PartySize describe who is sitting at the
+	// table right now, which hosts track separately from reservations.
+	// OccupiedBy is the booking seated there, empty for a walk-in.
+	IsOccupied    bool
+	OccupiedSince time.Time
+	PartySize     int
+	OccupiedBy    string
 }
 
 func NewTable(id string, capacity int) *Table {
@@ -13,3 +32,28 @@ func NewTable(id string, capacity int) *Table {
 		IsOccupied: false,
 	}
 }
+
+// OccupiedDuring reports whether a party seated at the table rules it out
+// for the window from start to end. Nobody knows when a seated party will
+// leave, so only windows covering now are affected.
+func (t Table) OccupiedDuring(start, end, now time.Time) bool {
+	return t.IsOccupied && !start.After(now) && end.After(now)
+}
+
+// TableStatus is a table's place on the floor: its occupancy, the booking
+// holding it now if any, and the next booking that will
+type TableStatus struct {
+	Table
+	CurrentBooking *Booking
+	NextBooking    *Booking
+}
+
+// TableChange reports what retiring or removing a table did to the bookings
+// holding it: Moved were reseated at other tables and Stranded still hold the
+// retired table. Deleted says whether the table left the inventory altogether.
+type TableChange struct {
+	TableID  string
+	Deleted  bool
+	Moved    []Booking
+	Stranded []Booking
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Waitlist entry statuses
+const (
+	WaitlistStatusWaiting = "waiting"
+	// WaitlistStatusOffered entries have tables held for them by a pending
+	// booking until OfferExpiresAt
+	WaitlistStatusOffered = "offered"
+	WaitlistStatusBooked  = "booked"
+	WaitlistStatusExpired = "expired"
+)
+
+// WaitlistEntry is a party waiting for tables to free up
+type WaitlistEntry struct {
+	ID string
+	CustomerDetails
+	NumCustomers int
+	// BookingTime is when the party wants to dine; zero means as soon as
+	// tables are free
+	BookingTime    time.Time
+	Status         string
+	BookingID      string
+	OfferExpiresAt time.Time
+	CreatedAt      time.Time
+}
+
+func NewWaitlistEntry(id string, customer CustomerDetails, numCustomers int, bookingTime time.Time, createdAt time.Time) *WaitlistEntry {
+	return &WaitlistEntry{
+		ID:              id,
+		CustomerDetails: customer,
+		NumCustomers:    numCustomers,
+		BookingTime:     bookingTime,
+		Status:          WaitlistStatusWaiting,
+		CreatedAt:       createdAt,
+	}
+}
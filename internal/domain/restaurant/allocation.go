@@ -0,0 +1,215 @@
+package restaurant
+
+import (
+	"fmt"
+	"math"
+
+	"booking-dinner/internal/domain/models"
+)
+
+// Names of the built-in allocation strategies
+const (
+	StrategyFirstFit           = "first-fit"
+	StrategyBestFit            = "best-fit"
+	StrategyFewestTables       = "fewest-tables"
+	StrategyLargePartyPriority = "large-party-priority"
+)
+
+// AllocationStrategy decides which of the free tables a party is seated at
+type AllocationStrategy interface {
+	// Name returns the name the strategy is selected by in the configuration
+	Name() string
+	// Allocate returns the tables assigned to a party of numCustomers, or nil
+	// if the free tables cannot seat the party
+	Allocate(free []models.Table, numCustomers int) []models.Table
+}
+
+// NewAllocationStrategy returns the built-in strategy with the given name. An
+// empty name selects first-fit. largePartySize is only used by
+// large-party-priority.
+func NewAllocationStrategy(name string, largePartySize int) (AllocationStrategy, error) {
+	switch name {
+	case "", StrategyFirstFit:
+		return FirstFit{}, nil
+	case StrategyBestFit:
+		return BestFit{}, nil
+	case StrategyFewestTables:
+		return FewestTables{}, nil
+	case StrategyLargePartyPriority:
+		if largePartySize <= 0 {
+			return nil, fmt.Errorf("large party size must be positive for %s", name)
+		}
+		return LargePartyPriority{LargePartySize: largePartySize}, nil
+	default:
+		return nil, fmt.Errorf("unknown allocation strategy %q", name)
+	}
+}
+
+// FirstFit takes free tables in inventory order until the party is seated
+type FirstFit struct{}
+
+func (FirstFit) Name() string {
+	return StrategyFirstFit
+}
+
+func (FirstFit) Allocate(free []models.Table, numCustomers int) []models.Table {
+	var assigned []models.Table
+	seats := 0
+	for _, table := range free {
+		if seats >= numCustomers {
+			break
+		}
+		assigned = append(assigned, table)
+		seats += table.Capacity
+	}
+
+	if seats < numCustomers {
+		return nil
+	}
+	return assigned
+}
+
+// BestFit picks the combination of tables that leaves the fewest seats empty,
+// using fewer tables to break ties
+type BestFit struct{}
+
+func (BestFit) Name() string {
+	return StrategyBestFit
+}
+
+func (BestFit) Allocate(free []models.Table, numCustomers int) []models.Table {
+	return optimalTables(free, numCustomers, func(seats, count, bestSeats, bestCount int) bool {
+		return seats < bestSeats || (seats == bestSeats && count < bestCount)
+	})
+}
+
+// FewestTables seats the party at as few tables as possible, leaving the
+// fewest seats empty to break ties
+type FewestTables struct{}
+
+func (FewestTables) Name() string {
+	return StrategyFewestTables
+}
+
+func (FewestTables) Allocate(free []models.Table, numCustomers int) []models.Table {
+	return optimalTables(free, numCustomers, func(seats, count, bestSeats, bestCount int) bool {
+		return count < bestCount || (count == bestCount && seats < bestSeats)
+	})
+}
+
+// LargePartyPriority keeps tables of LargePartySize seats or more for large
+// parties. Smaller parties are seated best-fit at the smaller tables and only
+// get a large table when nothing else fits.
+type LargePartyPriority struct {
+	LargePartySize int
+}
+
+func (LargePartyPriority) Name() string {
+	return StrategyLargePartyPriority
+}
+
+func (p LargePartyPriority) Allocate(free []models.Table, numCustomers int) []models.Table {
+	if numCustomers < p.LargePartySize {
+		small := make([]models.Table, 0, len(free))
+		for _, table := range free {
+			if table.Capacity < p.LargePartySize {
+				small = append(small, table)
+			}
+		}
+		if assigned := (BestFit{}).Allocate(small, numCustomers); assigned != nil {
+			return assigned
+		}
+	}
+	return BestFit{}.Allocate(free, numCustomers)
+}
+
+// optimalTables searches every combination of free tables that seats
+// numCustomers and returns the one preferred by better, which compares the
+// seat total and table count of a candidate against the best so far.
+func optimalTables(free []models.Table, numCustomers int, better func(seats, count, bestSeats, bestCount int) bool) []models.Table {
+	if len(free) == 0 || numCustomers <= 0 {
+		return nil
+	}
+
+	totalSeats := 0
+	for _, table := range free {
+		totalSeats += table.Capacity
+	}
+	if numCustomers > totalSeats {
+		return nil
+	}
+
+	// An optimal combination never has a table to spare, so a table that
+	// seats the whole party is only ever booked alone. Such tables are
+	// compared as they are and the search covers the smaller ones, keeping
+	// its size bounded by the party rather than the largest table.
+	var fitting []models.Table
+	var best []models.Table
+	bestSeats, bestCount := -1, math.MaxInt
+	for _, table := range free {
+		if table.Capacity <= numCustomers {
+			fitting = append(fitting, table)
+			continue
+		}
+		if bestSeats < 0 || better(table.Capacity, 1, bestSeats, bestCount) {
+			best = []models.Table{table}
+			bestSeats, bestCount = table.Capacity, 1
+		}
+	}
+
+	// The seat total of an optimal combination of fitting tables stays below
+	// numCustomers plus the largest of them
+	maxCapacity := 0
+	for _, table := range fitting {
+		maxCapacity = max(maxCapacity, table.Capacity)
+	}
+	limit := numCustomers + maxCapacity
+
+	// fewest[i][c] is the fewest of the first i fitting tables that seat
+	// exactly c
+	fewest := make([][]int, len(fitting)+1)
+	for i := range fewest {
+		fewest[i] = make([]int, limit)
+		for c := range fewest[i] {
+			fewest[i][c] = math.MaxInt
+		}
+		fewest[i][0] = 0
+	}
+	for i, table := range fitting {
+		for c := 1; c < limit; c++ {
+			fewest[i+1][c] = fewest[i][c]
+			if c >= table.Capacity && fewest[i][c-table.Capacity] != math.MaxInt && fewest[i][c-table.Capacity]+1 < fewest[i+1][c] {
+				fewest[i+1][c] = fewest[i][c-table.Capacity] + 1
+			}
+		}
+	}
+
+	fittingSeats := -1
+	for c := numCustomers; c < limit; c++ {
+		count := fewest[len(fitting)][c]
+		if count == math.MaxInt {
+			continue
+		}
+		if bestSeats < 0 || better(c, count, bestSeats, bestCount) {
+			bestSeats, bestCount, fittingSeats = c, count, c
+		}
+	}
+	if fittingSeats < 0 {
+		return best
+	}
+
+	assigned := make([]models.Table, 0, bestCount)
+	for i, c := len(fitting), fittingSeats; i > 0 && c > 0; i-- {
+		if fewest[i][c] == fewest[i-1][c] {
+			continue
+		}
+		assigned = append(assigned, fitting[i-1])
+		c -= fitting[i-1].Capacity
+	}
+
+	// Report tables in inventory order
+	for i, j := 0, len(assigned)-1; i < j; i, j = i+1, j-1 {
+		assigned[i], assigned[j] = assigned[j], assigned[i]
+	}
+	return assigned
+}
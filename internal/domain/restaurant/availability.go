@@ -0,0 +1,91 @@
+package restaurant
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/errors"
+)
+
+// defaultSlotInterval spaces availability slots when no interval is configured
+const defaultSlotInterval = 30 * time.Minute
+
+// GetAvailability returns the slots on date, given as 2006-01-02 in the
+// schedule's time zone, at which a party of partySize can still be booked.
+// Slots come every slot interval through each service period; those already
+// past or without tables that seat the party for its whole turn time are left
+// out. Retired tables are never counted, nor occupied ones for a slot starting
+// now, and a non-empty section only counts the tables in that section.
+func (s *service) GetAvailability(date string, partySize int, section string) ([]models.Slot, error) {
+	if partySize <= 0 {
+		return nil, errors.NewValidationError("Party size must be positive")
+	}
+
+	now := s.clock.Now()
+	location := s.schedule.Location()
+	if location == nil {
+		location = now.Location()
+	}
+	day, err := time.ParseInLocation(dateLayout, date, location)
+	if err != nil {
+		return nil, errors.NewValidationError(fmt.Sprintf("Date must look like %s", dateLayout))
+	}
+
+	interval := s.slotInterval
+	if interval <= 0 {
+		interval = defaultSlotInterval
+	}
+
+	var times []time.Time
+	for _, slot := range s.schedule.Slots(day, interval) {
+		if !slot.Before(now) {
+			times = append(times, slot)
+		}
+	}
+	if len(times) == 0 {
+		return []models.Slot{}, nil
+	}
+
+	// One read covers every reservation starting in the day's slots
+	ends := make([]time.Time, len(times))
+	last := times[0]
+	for i, start := range times {
+		ends[i] = start.Add(s.turnTime(partySize, start))
+		if ends[i].After(last) {
+			last = ends[i]
+		}
+	}
+	tables, windows, err := s.repo.GetReservedWindows(times[0], last)
+	if err != nil {
+		return nil, err
+	}
+
+	slots := []models.Slot{}
+	for i, start := range times {
+		end := ends[i]
+		busy := make(map[string]bool)
+		for _, window := range windows {
+			if window.Start.Before(end) && window.End.After(start) {
+				busy[window.TableID] = true
+			}
+		}
+
+		bookable := s.schedule.BookableTables(start)
+		var free []models.Table
+		seats := 0
+		for _, table := range tables {
+			if table.Retired || busy[table.ID] || table.OccupiedDuring(start, end, now) || (bookable != nil && !slices.Contains(bookable, table.ID)) || (section != "" && table.Section != section) {
+				continue
+			}
+			free = append(free, table)
+			seats += table.Capacity
+		}
+
+		if _, err := allocateOnFloor(s.strategy, free, partySize); err == nil {
+			slots = append(slots, models.Slot{Time: start, AvailableTables: len(free), AvailableSeats: seats})
+		}
+	}
+	return slots, nil
+}
@@ -0,0 +1,144 @@
+package restaurant
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"booking-dinner/internal/errors"
+)
+
+// ambiguousCharacters are easily confused when a code is read aloud or typed
+const ambiguousCharacters = "0O1I"
+
+// minCodesPerTable is the keyspace per table below which booking codes are
+// likely to collide often enough to matter
+const minCodesPerTable = 10000
+
+// CodeGenerator creates booking codes from a cryptographically secure source.
+// With a check character, codes carry one extra character computed with the
+// Luhn mod N algorithm over the charset, which catches any single mistyped
+// character and most swaps of adjacent characters.
+type CodeGenerator struct {
+	charset        string
+	length         int
+	checkCharacter bool
+}
+
+// NewCodeGenerator creates a generator for codes of length random characters
+// drawn from charset, optionally leaving out characters that are easily
+// confused and appending a check character
+func NewCodeGenerator(charset string, length int, excludeAmbiguous bool, checkCharacter bool) (*CodeGenerator, error) {
+	if length <= 0 {
+		return nil, fmt.Errorf("code length must be positive")
+	}
+
+	var builder strings.Builder
+	for _, char := range charset {
+		if char > 127 {
+			return nil, fmt.Errorf("code charset must be ASCII")
+		}
+		if excludeAmbiguous && strings.ContainsRune(ambiguousCharacters, char) {
+			continue
+		}
+		if strings.ContainsRune(builder.String(), char) {
+			continue
+		}
+		builder.WriteRune(char)
+	}
+
+	if builder.Len() < 2 {
+		return nil, fmt.Errorf("code charset must contain at least 2 usable characters")
+	}
+
+	return &CodeGenerator{
+		charset:        builder.String(),
+		length:         length,
+		checkCharacter: checkCharacter,
+	}, nil
+}
+
+// Generate returns a new random code
+func (g *CodeGenerator) Generate() (string, error) {
+	max := big.NewInt(int64(len(g.charset)))
+	result := make([]byte, g.length)
+	for i := range result {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate booking code: %w", err)
+		}
+		result[i] = g.charset[n.Int64()]
+	}
+
+	if g.checkCharacter {
+		result = append(result, g.charset[g.luhnCheck(string(result))])
+	}
+	return string(result), nil
+}
+
+// Validate checks a code entered by a guest or host. It returns
+// ErrInvalidBookingID if the code has the wrong length or characters and
+// ErrInvalidCheckCharacter if it looks right but its check character does not
+// match, which usually means a typo.
+func (g *CodeGenerator) Validate(code string) error {
+	length := g.length
+	if g.checkCharacter {
+		length++
+	}
+	if len(code) != length {
+		return errors.ErrInvalidBookingID
+	}
+	for i := 0; i < len(code); i++ {
+		if strings.IndexByte(g.charset, code[i]) < 0 {
+			return errors.ErrInvalidBookingID
+		}
+	}
+
+	if g.checkCharacter {
+		payload, check := code[:g.length], code[g.length]
+		if g.charset[g.luhnCheck(payload)] != check {
+			return errors.ErrInvalidCheckCharacter
+		}
+	}
+	return nil
+}
+
+// Valid reports whether code passes Validate
+func (g *CodeGenerator) Valid(code string) bool {
+	return g.Validate(code) == nil
+}
+
+// luhnCheck returns the charset index of the Luhn mod N check character for
+// payload, whose characters must all be in the charset
+func (g *CodeGenerator) luhnCheck(payload string) int {
+	n := len(g.charset)
+	factor, sum := 2, 0
+	for i := len(payload) - 1; i >= 0; i-- {
+		addend := factor * strings.IndexByte(g.charset, payload[i])
+		sum += addend/n + addend%n
+		if factor == 2 {
+			factor = 1
+		} else {
+			factor = 2
+		}
+	}
+	return (n - sum%n) % n
+}
+
+// Keyspace returns the number of distinct codes the generator can produce. A
+// check character does not add to it.
+func (g *CodeGenerator) Keyspace() float64 {
+	return math.Pow(float64(len(g.charset)), float64(g.length))
+}
+
+// CheckKeyspace returns an error describing the problem if there are too few
+// codes for a restaurant with maxTables tables to avoid frequent collisions
+func (g *CodeGenerator) CheckKeyspace(maxTables int) error {
+	if keyspace := g.Keyspace(); keyspace < float64(maxTables)*minCodesPerTable {
+		return fmt.Errorf("%d characters of length %d give only %.0f booking codes for %d tables; increase code.length or code.charset",
+			len(g.charset), g.length, keyspace, maxTables)
+	}
+	return nil
+}
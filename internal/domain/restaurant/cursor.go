@@ -0,0 +1,36 @@
+package restaurant
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/errors"
+)
+
+// encodeCursor returns an opaque cursor for the page that follows booking
+func encodeCursor(booking models.Booking) string {
+	raw := strconv.FormatInt(booking.BookingTime.UnixNano(), 10) + ":" + booking.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a cursor made by encodeCursor
+func decodeCursor(cursor string) (*models.BookingCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.NewValidationError("Invalid cursor")
+	}
+
+	nanos, id, found := strings.Cut(string(raw), ":")
+	if !found {
+		return nil, errors.NewValidationError("Invalid cursor")
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, errors.NewValidationError("Invalid cursor")
+	}
+
+	return &models.BookingCursor{BookingTime: time.Unix(0, n), ID: id}, nil
+}
@@ -0,0 +1,76 @@
+package restaurant
+
+import (
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/errors"
+)
+
+// Limits on the free-text customer details, in characters
+const (
+	maxCustomerNameLength = 100
+	maxNotesLength        = 500
+)
+
+// phonePattern matches a phone number once separators are removed: an
+// optional + followed by 6 to 15 digits
+var phonePattern = regexp.MustCompile(`^\+?[0-9]{6,15}$`)
+
+// phoneSeparators are dropped from phone numbers so the same number typed
+// differently is stored, and found, the same way
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+
+// normalizeCustomer validates customer details and returns them trimmed,
+// with the phone number stripped of separators and the email in lower case.
+// Every field is optional.
+func normalizeCustomer(customer models.CustomerDetails) (models.CustomerDetails, error) {
+	customer.CustomerName = strings.TrimSpace(customer.CustomerName)
+	customer.SpecialRequests = strings.TrimSpace(customer.SpecialRequests)
+	customer.DietaryNotes = strings.TrimSpace(customer.DietaryNotes)
+
+	if utf8.RuneCountInString(customer.CustomerName) > maxCustomerNameLength {
+		return customer, errors.NewValidationError(fmt.Sprintf("Name must be at most %d characters", maxCustomerNameLength))
+	}
+	if utf8.RuneCountInString(customer.SpecialRequests) > maxNotesLength {
+		return customer, errors.NewValidationError(fmt.Sprintf("Special requests must be at most %d characters", maxNotesLength))
+	}
+	if utf8.RuneCountInString(customer.DietaryNotes) > maxNotesLength {
+		return customer, errors.NewValidationError(fmt.Sprintf("Dietary notes must be at most %d characters", maxNotesLength))
+	}
+
+	var err error
+	if customer.Phone, err = normalizePhone(customer.Phone); err != nil {
+		return customer, err
+	}
+	if customer.Email, err = normalizeEmail(customer.Email); err != nil {
+		return customer, err
+	}
+	return customer, nil
+}
+
+// normalizePhone strips separators from a phone number and checks what is left
+func normalizePhone(phone string) (string, error) {
+	phone = phoneSeparators.Replace(strings.TrimSpace(phone))
+	if phone != "" && !phonePattern.MatchString(phone) {
+		return "", errors.NewValidationError("Phone must be 6 to 15 digits, optionally starting with +")
+	}
+	return phone, nil
+}
+
+// normalizeEmail checks that email is a bare address and lower-cases it
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", nil
+	}
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return "", errors.NewValidationError("Email must be a valid address such as name@example.com")
+	}
+	return email, nil
+}
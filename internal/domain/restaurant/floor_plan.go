@@ -0,0 +1,199 @@
+package restaurant
+
+import (
+	"fmt"
+	"slices"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/errors"
+)
+
+// maxCombinations bounds the table combinations tried for one party when the
+// strategy's choice cannot be pushed together
+const maxCombinations = 10000
+
+// joinable reports whether tables a and b can be pushed together: they share
+// a joinable group, either lists the other as adjacent, or neither carries
+// any floor plan and both are loose tables.
+func joinable(a, b models.Table) bool {
+	if a.JoinGroup != "" && a.JoinGroup == b.JoinGroup {
+		return true
+	}
+	if slices.Contains(a.Adjacent, b.ID) || slices.Contains(b.Adjacent, a.ID) {
+		return true
+	}
+	return isLoose(a) && isLoose(b)
+}
+
+// isLoose reports whether a table has no floor plan and so can be moved next
+// to any other loose table
+func isLoose(table models.Table) bool {
+	return table.JoinGroup == "" && len(table.Adjacent) == 0
+}
+
+// canCombine reports whether tables can seat one party together, which needs
+// every table to be joinable to the others directly or through the rest
+func canCombine(tables []models.Table) bool {
+	if len(tables) <= 1 {
+		return true
+	}
+	return len(connected(tables, 0)) == len(tables)
+}
+
+// connected returns the indexes in tables of those reachable from tables[from]
+// through joinable links, in inventory order
+func connected(tables []models.Table, from int) []int {
+	reached := make([]bool, len(tables))
+	reached[from] = true
+	queue := []int{from}
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		for j := range tables {
+			if !reached[j] && joinable(tables[i], tables[j]) {
+				reached[j] = true
+				queue = append(queue, j)
+			}
+		}
+	}
+
+	var indexes []int
+	for i, ok := range reached {
+		if ok {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// components splits tables into the sets that can be pushed together, in
+// inventory order of their first table
+func components(tables []models.Table) [][]models.Table {
+	placed := make([]bool, len(tables))
+	var sets [][]models.Table
+	for i := range tables {
+		if placed[i] {
+			continue
+		}
+		var set []models.Table
+		for _, j := range connected(tables, i) {
+			if !placed[j] {
+				placed[j] = true
+				set = append(set, tables[j])
+			}
+		}
+		sets = append(sets, set)
+	}
+	return sets
+}
+
+// allocateOnFloor returns the tables strategy assigns a party of numCustomers
+// from free, as long as they can be pushed together. Otherwise the party gets
+// the combination within one set of joinable tables that uses the fewest
+// tables, then the fewest seats. It returns ErrInsufficientTables if the free
+// tables do not have enough seats and ErrNoTableCombination if they do but
+// none of them can be combined to seat the party.
+func allocateOnFloor(strategy AllocationStrategy, free []models.Table, numCustomers int) ([]models.Table, error) {
+	// A party larger than every free seat together is turned away before
+	// any strategy runs, since their search grows with the party size
+	if numCustomers > seatCount(free) {
+		return nil, errors.ErrInsufficientTables
+	}
+
+	assigned := strategy.Allocate(free, numCustomers)
+	if assigned == nil {
+		return nil, errors.ErrInsufficientTables
+	}
+	if canCombine(assigned) {
+		return assigned, nil
+	}
+
+	var best []models.Table
+	for _, component := range components(free) {
+		candidate := strategy.Allocate(component, numCustomers)
+		if candidate != nil && !canCombine(candidate) {
+			candidate = smallestCombination(component, numCustomers)
+		}
+		if candidate != nil && (best == nil || fewerTables(candidate, best)) {
+			best = candidate
+		}
+	}
+	if best == nil {
+		return nil, errors.ErrNoTableCombination
+	}
+	return best, nil
+}
+
+// fewerTables reports whether a seats a party at fewer tables than b, or at
+// as many tables with fewer seats
+func fewerTables(a, b []models.Table) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+	return seatCount(a) < seatCount(b)
+}
+
+func seatCount(tables []models.Table) int {
+	seats := 0
+	for _, table := range tables {
+		seats += table.Capacity
+	}
+	return seats
+}
+
+// smallestCombination grows sets of joinable tables in component one table
+// at a time and returns the first, in inventory order, of the smallest sets
+// seating numCustomers with the fewest seats. It gives up and returns nil
+// after maxCombinations sets.
+func smallestCombination(component []models.Table, numCustomers int) []models.Table {
+	type combination struct {
+		members []int
+		seats   int
+	}
+
+	level := make([]combination, len(component))
+	for i, table := range component {
+		level[i] = combination{members: []int{i}, seats: table.Capacity}
+	}
+
+	seen := make(map[string]bool)
+	for len(level) > 0 {
+		var best *combination
+		for i := range level {
+			if level[i].seats >= numCustomers && (best == nil || level[i].seats < best.seats) {
+				best = &level[i]
+			}
+		}
+		if best != nil {
+			tables := make([]models.Table, len(best.members))
+			for i, member := range best.members {
+				tables[i] = component[member]
+			}
+			return tables
+		}
+
+		var next []combination
+		for _, set := range level {
+			for _, member := range set.members {
+				for j := range component {
+					if slices.Contains(set.members, j) || !joinable(component[member], component[j]) {
+						continue
+					}
+					members := append(slices.Clone(set.members), j)
+					slices.Sort(members)
+					key := fmt.Sprint(members)
+					if seen[key] {
+						continue
+					}
+					if len(seen) >= maxCombinations {
+						return nil
+					}
+					seen[key] = true
+					next = append(next, combination{members: members, seats: set.seats + component[j].Capacity})
+				}
+			}
+		}
+		level = next
+	}
+	return nil
+}
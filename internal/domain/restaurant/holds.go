@@ -0,0 +1,101 @@
+package restaurant
+
+import (
+	"context"
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/errors"
+)
+
+// HoldTables keeps tables for a party for the hold TTL while the guest fills
+// in their details. The hold is a pending booking that ConfirmHold turns into
+// a confirmed one; unconfirmed holds are released by ReleaseExpiredHolds.
+func (s *service) HoldTables(numCustomers int, bookingTime time.Time, section models.SectionPreference) (models.Booking, int, error) {
+	if s.holdTTL <= 0 {
+		return models.Booking{}, 0, errors.ErrHoldsDisabled
+	}
+	return s.reserve(numCustomers, bookingTime, models.CustomerDetails{}, section, s.holdTTL)
+}
+
+// ConfirmHold turns a hold into a confirmed booking for the guest, keeping
+// its tables. It returns ErrHoldExpired once the hold has lapsed.
+func (s *service) ConfirmHold(bookingID string, customer models.CustomerDetails) (models.Booking, error) {
+	if s.holdTTL <= 0 {
+		return models.Booking{}, errors.ErrHoldsDisabled
+	}
+	if err := s.codes.Validate(bookingID); err != nil {
+		return models.Booking{}, err
+	}
+
+	customer, err := normalizeCustomer(customer)
+	if err != nil {
+		return models.Booking{}, err
+	}
+
+	now := s.clock.Now()
+	update := func(booking models.Booking) (models.Booking, error) {
+		if booking.Status == models.BookingStatusCancelled && !booking.HoldExpiresAt.IsZero() {
+			return booking, errors.ErrHoldExpired
+		}
+		if booking.Status != models.BookingStatusPending || booking.HoldExpiresAt.IsZero() {
+			return booking, errors.NewTransitionError("Only held bookings can be confirmed")
+		}
+		if !now.Before(booking.HoldExpiresAt) {
+			return booking, errors.ErrHoldExpired
+		}
+
+		booking.CustomerDetails = customer
+		booking.Status = models.BookingStatusConfirmed
+		booking.HoldExpiresAt = time.Time{}
+		return booking, nil
+	}
+
+	// The hold's own tables are free to it, so keeping them cannot fail
+	keep := func(booking models.Booking, free []models.Table) (models.Booking, error) {
+		return booking, nil
+	}
+
+	booking, _, err := s.repo.ModifyReservation(bookingID, update, keep)
+	if err != nil {
+		if err == errors.ErrInvalidBookingID || err == errors.ErrHoldExpired || errors.IsTransitionError(err) {
+			return models.Booking{}, err
+		}
+		return models.Booking{}, errors.NewReservationError(err.Error())
+	}
+	return booking, nil
+}
+
+// ReleaseExpiredHolds cancels the holds, including waitlist offers, that
+// have lapsed and offers the released tables to the waitlist. It returns the
+// number of holds released.
+func (s *service) ReleaseExpiredHolds() (int, error) {
+	now := s.clock.Now()
+	released, err := s.repo.ReleaseExpiredHolds(now)
+	if err != nil {
+		return 0, err
+	}
+
+	s.expireOffers(now)
+	if len(released) > 0 {
+		s.promoteWaitlist()
+	}
+	return len(released), nil
+}
+
+// RunHoldReaper releases lapsed holds every time ticks fires, until ctx is
+// done. The server passes a time.Ticker's channel; tests send ticks
+// themselves after moving the service's clock. Errors are passed to onError
+// if it is not nil.
+func RunHoldReaper(ctx context.Context, service Service, ticks <-chan time.Time, onError func(error)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticks:
+			if _, err := service.ReleaseExpiredHolds(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
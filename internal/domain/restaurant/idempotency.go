@@ -0,0 +1,56 @@
+package restaurant
+
+import (
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/errors"
+)
+
+// ClaimIdempotencyKey marks key as used by the request with the given
+// fingerprint for the idempotency TTL. It returns nil if the request should
+// run, or the record of the first request with the key so its response can
+// be replayed. A key used for a different request gives
+// ErrIdempotencyKeyReused, and one whose first request has not finished gives
+// ErrIdempotencyInProgress. Keys are ignored while idempotency is disabled.
+func (s *service) ClaimIdempotencyKey(key string, fingerprint string) (*models.IdempotencyRecord, error) {
+	if s.idempotencyTTL <= 0 {
+		return nil, nil
+	}
+
+	now := s.clock.Now()
+	record, claimed, err := s.repo.ClaimIdempotencyKey(models.IdempotencyRecord{
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(s.idempotencyTTL),
+	}, now)
+	if err != nil {
+		return nil, err
+	}
+	if claimed {
+		return nil, nil
+	}
+	if record.Fingerprint != fingerprint {
+		return nil, errors.ErrIdempotencyKeyReused
+	}
+	if !record.Completed() {
+		return nil, errors.ErrIdempotencyInProgress
+	}
+	return &record, nil
+}
+
+// SaveIdempotentResponse stores the response to the request that claimed key
+// for replay
+func (s *service) SaveIdempotentResponse(key string, statusCode int, body []byte) error {
+	if s.idempotencyTTL <= 0 {
+		return nil
+	}
+	return s.repo.SaveIdempotentResponse(key, statusCode, body)
+}
+
+// ReleaseIdempotencyKey frees key after its request failed without a response
+// worth replaying, so the request can be retried with it
+func (s *service) ReleaseIdempotencyKey(key string) error {
+	if s.idempotencyTTL <= 0 {
+		return nil
+	}
+	return s.repo.ReleaseIdempotencyKey(key)
+}
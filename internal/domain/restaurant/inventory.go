@@ -0,0 +1,109 @@
+package restaurant
+
+import (
+	"fmt"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/errors"
+)
+
+// AddTable adds a table to the initialized inventory while keeping every
+// booking. A table without an ID gets the first unused T number and one
+// without a capacity gets seatsPerTable seats. Retired tables do not count
+// towards maxTables.
+func (s *service) AddTable(table models.Table) (models.Table, error) {
+	initialized, err := s.repo.IsInitialized()
+	if err != nil {
+		return models.Table{}, err
+	}
+	if !initialized {
+		return models.Table{}, errors.ErrTableNotInitialized
+	}
+
+	if table.Capacity == 0 {
+		table.Capacity = s.seatsPerTable
+	}
+	if table.Capacity < 1 || table.Capacity > MaxTableCapacity {
+		return models.Table{}, errors.NewValidationError(fmt.Sprintf("Table capacity must be between 1 and %d seats", MaxTableCapacity))
+	}
+
+	tables, err := s.repo.GetTables()
+	if err != nil {
+		return models.Table{}, err
+	}
+	taken := make(map[string]bool, len(tables))
+	for _, existing := range tables {
+		taken[existing.ID] = true
+	}
+
+	if table.ID == "" {
+		for n := 1; table.ID == ""; n++ {
+			if id := fmt.Sprintf("T%d", n); !taken[id] {
+				table.ID = id
+			}
+		}
+	}
+	if taken[table.ID] {
+		return models.Table{}, errors.ErrDuplicateTableID
+	}
+	for _, adjacent := range table.Adjacent {
+		if adjacent == table.ID || !taken[adjacent] {
+			return models.Table{}, errors.NewValidationError(fmt.Sprintf("Table %s lists %q as adjacent, which is not another table", table.ID, adjacent))
+		}
+	}
+
+	added := *models.NewTable(table.ID, table.Capacity)
+	added.JoinGroup = table.JoinGroup
+	added.Adjacent = table.Adjacent
+	added.Section = table.Section
+	// The repository counts the tables in service under the same lock as the
+	// insert, so concurrent additions cannot pass maxTables together
+	if err := s.repo.AddTable(added, s.maxTables); err != nil {
+		return models.Table{}, err
+	}
+
+	// The new table may seat parties that are waiting
+	s.promoteWaitlist()
+	return added, nil
+}
+
+// RetireTable takes a table out of service while keeping it in the inventory
+// for the bookings that used it. Upcoming bookings holding it are moved to
+// other free tables. If some cannot be moved the table is only retired with
+// force, which leaves them stranded on it; otherwise ErrTableHasBookings is
+// returned together with a change listing them.
+func (s *service) RetireTable(tableID string, force bool) (models.TableChange, error) {
+	return s.retire(tableID, force, false)
+}
+
+// RemoveTable takes a table out of service like RetireTable and then deletes
+// it, unless a booking, past or stranded, still refers to it, in which case
+// it stays behind as retired
+func (s *service) RemoveTable(tableID string, force bool) (models.TableChange, error) {
+	return s.retire(tableID, force, true)
+}
+
+func (s *service) retire(tableID string, force bool, remove bool) (models.TableChange, error) {
+	move := func(booking models.Booking, free []models.Table) (models.Booking, error) {
+		// A party already at the table cannot be moved on paper
+		if booking.Status == models.BookingStatusSeated {
+			return booking, errors.ErrTableOccupied
+		}
+		return s.allocate(booking, free)
+	}
+
+	var refused models.TableChange
+	check := func(moved, stranded []models.Booking) error {
+		if len(stranded) > 0 && !force {
+			refused = models.TableChange{TableID: tableID, Moved: moved, Stranded: stranded}
+			return errors.ErrTableHasBookings
+		}
+		return nil
+	}
+
+	change, err := s.repo.RetireTable(tableID, s.clock.Now(), remove, move, check)
+	if err == errors.ErrTableHasBookings {
+		return refused, err
+	}
+	return change, err
+}
@@ -0,0 +1,27 @@
+package restaurant
+
+import (
+	"fmt"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/errors"
+)
+
+// transitions lists the statuses a booking may move to from each status.
+// Completed, no-show and cancelled bookings are final.
+var transitions = map[string][]string{
+	models.BookingStatusPending:   {models.BookingStatusConfirmed, models.BookingStatusCancelled},
+	models.BookingStatusConfirmed: {models.BookingStatusSeated, models.BookingStatusNoShow, models.BookingStatusCancelled},
+	models.BookingStatusSeated:    {models.BookingStatusCompleted},
+}
+
+// checkTransition returns a transition error unless a booking in status from
+// may move to status to
+func checkTransition(from, to string) error {
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return errors.NewTransitionError(fmt.Sprintf("A %s booking cannot be marked %s", from, to))
+}
@@ -1,22 +1,121 @@
 package restaurant
 
 import (
+	"time"
+
 	"booking-dinner/internal/domain/models"
 )
 
 // Service defines the interface for restaurant operations
 type Service interface {
-	InitializeTables(numTables int) error
-	ReserveTables(numCustomers int) (string, int, int, error)
+	InitializeTables(tables []models.Table) error
+	InitializeTableCount(numTables int) error
+	ReserveTables(numCustomers int, bookingTime time.Time, customer models.CustomerDetails, section models.SectionPreference) (models.Booking, int, error)
 	CancelReservation(bookingID string) (int, int, error)
-	GetAvailableTables() int
+	SeatBooking(bookingID string) (models.Booking, error)
+	CompleteBooking(bookingID string) (models.Booking, int, error)
+	MarkNoShow(bookingID string) (models.Booking, int, error)
+	ModifyReservation(bookingID string, numCustomers int, bookingTime time.Time) (models.Booking, int, error)
+	SetTurnTime(bookingID string, turnTime time.Duration) (models.Booking, int, error)
+	GetBooking(bookingID string) (models.Booking, error)
+	ListBookings(filter models.BookingFilter, cursor string, limit int) ([]models.Booking, string, error)
+	GetAvailableTables(start, end time.Time) (int, error)
+	GetAvailability(date string, partySize int, section string) ([]models.Slot, error)
+	SeatWalkIn(tableID string, partySize int) (models.Table, error)
+	ClearTable(tableID string) (models.Table, error)
+	GetFloorStatus() ([]models.TableStatus, error)
+	JoinWaitlist(numCustomers int, bookingTime time.Time, customer models.CustomerDetails) (models.WaitlistEntry, error)
+	GetWaitlistEntry(entryID string) (models.WaitlistEntry, error)
+	ConfirmWaitlistOffer(entryID string) (models.Booking, error)
+	HoldTables(numCustomers int, bookingTime time.Time, section models.SectionPreference) (models.Booking, int, error)
+	ConfirmHold(bookingID string, customer models.CustomerDetails) (models.Booking, error)
+	ReleaseExpiredHolds() (int, error)
+	AddTable(table models.Table) (models.Table, error)
+	RetireTable(tableID string, force bool) (models.TableChange, error)
+	RemoveTable(tableID string, force bool) (models.TableChange, error)
+	ClaimIdempotencyKey(key string, fingerprint string) (*models.IdempotencyRecord, error)
+	SaveIdempotentResponse(key string, statusCode int, body []byte) error
+	ReleaseIdempotencyKey(key string) error
 }
 
 // Repository defines the interface for data storage operations
 type Repository interface {
-	InitializeTables(numTables int) error
-	ReserveTables(booking models.Booking) error
-	CancelReservation(bookingID string) (int, error)
-	GetAvailableTables() int
-	IsInitialized() bool
+	InitializeTables(tables []models.Table) error
+	// ReserveTables atomically stores a booking. While holding its lock the
+	// repository passes the tables free during the booking's window to
+	// allocate, which returns the booking with its tables assigned or an
+	// error to abort. It returns the stored booking and the number of tables
+	// still free in the window afterwards.
+	ReserveTables(booking models.Booking, allocate func(booking models.Booking, free []models.Table) (models.Booking, error)) (models.Booking, int, error)
+	// UpdateStatus atomically passes the stored booking to update and saves
+	// the status, duration and hold expiry it returns; an error aborts the
+	// change. Tables stay recorded on the booking but are free again once its
+	// status no longer holds them. It returns the stored booking and the
+	// number of tables free in its original window afterwards.
+	UpdateStatus(bookingID string, update func(booking models.Booking) (models.Booking, error)) (models.Booking, int, error)
+	// ModifyReservation atomically changes a booking. While holding its lock
+	// the repository passes the stored booking to update, then passes the
+	// updated booking and the tables free during its new window, including
+	// the booking's current tables, to allocate. Either callback can return
+	// an error to abort and leave the booking unchanged. It returns the
+	// stored booking and the number of tables still free in the new window.
+	ModifyReservation(bookingID string, update func(booking models.Booking) (models.Booking, error), allocate func(booking models.Booking, free []models.Table) (models.Booking, error)) (models.Booking, int, error)
+	// GetBooking returns the booking with the given ID or
+	// ErrInvalidBookingID if there is none
+	GetBooking(bookingID string) (models.Booking, error)
+	// ListBookings returns up to limit bookings matching filter, ordered by
+	// booking time and then ID, starting after the cursor if it is not nil
+	ListBookings(filter models.BookingFilter, after *models.BookingCursor, limit int) ([]models.Booking, error)
+	GetAvailableTables(start, end time.Time) ([]models.Table, error)
+	// GetReservedWindows returns every table, in inventory order, and the
+	// windows in which bookings hold tables at any time in [start, end), so
+	// availability across a day is worked out from a single read
+	GetReservedWindows(start, end time.Time) ([]models.Table, []models.ReservedWindow, error)
+	// GetTables returns every table with its occupancy, in inventory order
+	GetTables() ([]models.Table, error)
+	// UpdateOccupancy atomically passes a table to update, together with
+	// whether a booking holds it at time at, and saves the occupancy fields
+	// update returns; an error aborts the change. It returns
+	// ErrTableNotFound for an unknown table.
+	UpdateOccupancy(tableID string, at time.Time, update func(table models.Table, reserved bool) (models.Table, error)) (models.Table, error)
+	IsInitialized() (bool, error)
+	// AddTable appends a table to the inventory or returns
+	// ErrDuplicateTableID if its ID is taken, even by a retired table, and
+	// ErrMaxTablesExceeded if maxTables tables are already in service. Both
+	// are checked under the same lock as the insert.
+	AddTable(table models.Table, maxTables int) error
+	// RetireTable atomically takes a table out of service. While holding its
+	// lock the repository marks the table retired and passes each booking
+	// holding it at or after now, with the tables free during its window, to
+	// move, which returns the booking at other tables or an error to leave it
+	// where it is. The moved and stranded bookings are then passed to check,
+	// which can return an error to abort and leave everything unchanged. With
+	// remove set the table is deleted once no booking refers to it. It
+	// returns ErrTableNotFound for an unknown table.
+	RetireTable(tableID string, now time.Time, remove bool, move func(booking models.Booking, free []models.Table) (models.Booking, error), check func(moved, stranded []models.Booking) error) (models.TableChange, error)
+	// ReleaseExpiredHolds atomically cancels the pending bookings whose hold
+	// expired by now, freeing their tables, and returns them
+	ReleaseExpiredHolds(now time.Time) ([]models.Booking, error)
+	// AddToWaitlist stores a new waitlist entry or returns
+	// ErrDuplicateWaitlistID if its ID is taken
+	AddToWaitlist(entry models.WaitlistEntry) error
+	// GetWaitlistEntry returns the entry with the given ID or
+	// ErrWaitlistEntryNotFound if there is none
+	GetWaitlistEntry(entryID string) (models.WaitlistEntry, error)
+	// ListWaitlist returns the entries with the given status in the order
+	// they joined
+	ListWaitlist(status string) ([]models.WaitlistEntry, error)
+	// UpdateWaitlistEntry atomically passes the stored entry to update and
+	// saves the status, booking ID and offer expiry it returns; an error
+	// aborts the change
+	UpdateWaitlistEntry(entryID string, update func(entry models.WaitlistEntry) (models.WaitlistEntry, error)) (models.WaitlistEntry, error)
+	// ClaimIdempotencyKey atomically stores record unless a record with its
+	// key that has not expired by now exists, in which case it returns that
+	// record and false. Expired records are deleted.
+	ClaimIdempotencyKey(record models.IdempotencyRecord, now time.Time) (models.IdempotencyRecord, bool, error)
+	// SaveIdempotentResponse stores the response of the request that claimed
+	// key
+	SaveIdempotentResponse(key string, statusCode int, body []byte) error
+	// ReleaseIdempotencyKey deletes the record stored under key
+	ReleaseIdempotencyKey(key string) error
 }
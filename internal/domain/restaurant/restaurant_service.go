@@ -2,106 +2,622 @@ package restaurant
 
 import (
 	"fmt"
-	"math"
-	"regexp"
+	"slices"
 	"time"
 
 	"booking-dinner/internal/domain/models"
 	"booking-dinner/internal/errors"
+	"booking-dinner/pkg/clock"
+)
+
+// maxCodeAttempts bounds how many booking codes are tried when generated
+// codes collide with existing bookings
+const maxCodeAttempts = 10
 
-	"golang.org/x/exp/rand"
+// MaxTableCapacity is the most seats a table may have. Allocation works
+// through party sizes seat by seat, so capacities are kept to what a dining
+// room holds.
+const MaxTableCapacity = 100
+
+// Page sizes for listing bookings
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
 )
 
 type service struct {
-	repo          Repository
-	seatsPerTable int
-	maxTables     int
-	charsetCode   string
-	lengthCode    int
+	repo                Repository
+	strategy            AllocationStrategy
+	codes               *CodeGenerator
+	seatsPerTable       int
+	maxTables           int
+	reservationDuration time.Duration
+	turnTimes           TurnTimes
+	// slotInterval is the spacing of the times offered by availability
+	// searches; zero means every half hour
+	slotInterval time.Duration
+	// offerHold is how long tables offered to a waitlisted party are held;
+	// zero disables the waitlist
+	offerHold time.Duration
+	// holdTTL is how long a hold keeps its tables before it must be
+	// confirmed; zero disables holds
+	holdTTL time.Duration
+	// idempotencyTTL is how long the response to a request with an
+	// idempotency key is kept for replay; zero ignores idempotency keys
+	idempotencyTTL time.Duration
+	// schedule limits when bookings may start; nil accepts any time
+	schedule *Schedule
+	clock    clock.Clock
+}
+
+// Options configures a restaurant service. Strategy and Codes are required;
+// the other fields may be left zero where noted.
+type Options struct {
+	Strategy      AllocationStrategy
+	Codes         *CodeGenerator
+	SeatsPerTable int
+	MaxTables     int
+	// ReservationDuration is how long bookings no turn time rule matches
+	// keep their tables
+	ReservationDuration time.Duration
+	TurnTimes           TurnTimes
+	// SlotInterval spaces the times offered by availability searches; zero
+	// means every half hour
+	SlotInterval time.Duration
+	// OfferHold is how long tables offered to a waitlisted party are held;
+	// zero disables the waitlist
+	OfferHold time.Duration
+	// HoldTTL is how long a hold keeps its tables; zero disables holds
+	HoldTTL time.Duration
+	// IdempotencyTTL is how long responses are kept for replay; zero ignores
+	// idempotency keys
+	IdempotencyTTL time.Duration
+	// Schedule limits when bookings may start; nil accepts any time
+	Schedule *Schedule
 }
 
-// NewService creates a new instance of restaurant service
-func NewService(repo Repository, seatsPerTable int, maxTables int, charsetCode string, lengthCode int) Service {
+// NewService creates a new instance of restaurant service configured by
+// options. Every time-dependent decision reads the current time from clock.
+func NewService(repo Repository, options Options, clock clock.Clock) Service {
 	return &service{
-		repo:          repo,
-		seatsPerTable: seatsPerTable,
-		maxTables:     maxTables,
-		charsetCode:   charsetCode,
-		lengthCode:    lengthCode,
+		repo:                repo,
+		strategy:            options.Strategy,
+		codes:               options.Codes,
+		seatsPerTable:       options.SeatsPerTable,
+		maxTables:           options.MaxTables,
+		reservationDuration: options.ReservationDuration,
+		turnTimes:           options.TurnTimes,
+		slotInterval:        options.SlotInterval,
+		offerHold:           options.OfferHold,
+		holdTTL:             options.HoldTTL,
+		idempotencyTTL:      options.IdempotencyTTL,
+		schedule:            options.Schedule,
+		clock:               clock,
 	}
 }
 
-func (s *service) InitializeTables(numTables int) error {
+// InitializeTableCount sets up numTables identical tables of seatsPerTable seats
+func (s *service) InitializeTableCount(numTables int) error {
 	if numTables <= 0 || numTables > s.maxTables {
 		return errors.NewInitializationError(fmt.Sprintf("Number of tables must be between 1 and %d", s.maxTables))
 	}
 
-	if s.repo.IsInitialized() {
+	return s.InitializeTables(make([]models.Table, numTables))
+}
+
+// InitializeTables sets up the table inventory. Tables without an ID are
+// numbered T1, T2, ... and tables without a capacity get seatsPerTable seats.
+func (s *service) InitializeTables(tables []models.Table) error {
+	if len(tables) == 0 || len(tables) > s.maxTables {
+		return errors.NewInitializationError(fmt.Sprintf("Number of tables must be between 1 and %d", s.maxTables))
+	}
+
+	initialized, err := s.repo.IsInitialized()
+	if err != nil {
+		return err
+	}
+	if initialized {
 		return errors.ErrTableInitialized
 	}
 
-	return s.repo.InitializeTables(numTables)
+	inventory := make([]models.Table, len(tables))
+	seen := make(map[string]bool, len(tables))
+	for i, table := range tables {
+		if table.ID == "" {
+			table.ID = fmt.Sprintf("T%d", i+1)
+		}
+		if table.Capacity == 0 {
+			table.Capacity = s.seatsPerTable
+		}
+		if table.Capacity < 1 || table.Capacity > MaxTableCapacity {
+			return errors.NewInitializationError(fmt.Sprintf("Table %s must have between 1 and %d seats", table.ID, MaxTableCapacity))
+		}
+		if seen[table.ID] {
+			return errors.NewInitializationError(fmt.Sprintf("Table ID %s is used more than once", table.ID))
+		}
+		seen[table.ID] = true
+		inventory[i] = *models.NewTable(table.ID, table.Capacity)
+		inventory[i].JoinGroup = table.JoinGroup
+		inventory[i].Adjacent = table.Adjacent
+		inventory[i].Section = table.Section
+	}
+
+	// Adjacency can only be checked once every table ID is known
+	for _, table := range inventory {
+		for _, adjacent := range table.Adjacent {
+			if adjacent == table.ID || !seen[adjacent] {
+				return errors.NewInitializationError(fmt.Sprintf("Table %s lists %q as adjacent, which is not another table", table.ID, adjacent))
+			}
+		}
+	}
+
+	return s.repo.InitializeTables(inventory)
+}
+
+// ReserveTables books tables for numCustomers starting at bookingTime. A zero
+// bookingTime means the party is seated now. An empty section preference
+// seats the party anywhere.
+func (s *service) ReserveTables(numCustomers int, bookingTime time.Time, customer models.CustomerDetails, section models.SectionPreference) (models.Booking, int, error) {
+	return s.reserve(numCustomers, bookingTime, customer, section, 0)
 }
 
-func (s *service) ReserveTables(numCustomers int) (string, int, int, error) {
-	if !s.repo.IsInitialized() {
-		return "", 0, 0, errors.ErrTableNotInitialized
+// reserve books tables for a party. With a positive hold the booking is left
+// pending and keeps its tables for that long unless it is confirmed.
+func (s *service) reserve(numCustomers int, bookingTime time.Time, customer models.CustomerDetails, section models.SectionPreference, hold time.Duration) (models.Booking, int, error) {
+	initialized, err := s.repo.IsInitialized()
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	if !initialized {
+		return models.Booking{}, 0, errors.ErrTableNotInitialized
 	}
 
 	if numCustomers <= 0 {
-		return "", 0, 0, errors.NewValidationError("Number of customers must be positive")
+		return models.Booking{}, 0, errors.NewValidationError("Number of customers must be positive")
+	}
+
+	now := s.clock.Now()
+	if bookingTime.IsZero() {
+		bookingTime = now
+	} else if bookingTime.Before(now) {
+		return models.Booking{}, 0, errors.NewValidationError("Booking time must not be in the past")
+	}
+	if err := s.schedule.Check(bookingTime); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	customer, err = normalizeCustomer(customer)
+	if err != nil {
+		return models.Booking{}, 0, err
 	}
 
-	tablesNeeded := int(math.Ceil(float64(numCustomers) / float64(s.seatsPerTable)))
-	availableTables := s.repo.GetAvailableTables()
+	// A fresh code is tried whenever the repository already holds the
+	// generated one
+	for attempt := 0; attempt < maxCodeAttempts; attempt++ {
+		bookingID, err := s.codes.Generate()
+		if err != nil {
+			return models.Booking{}, 0, errors.NewReservationError(err.Error())
+		}
+		booking := models.NewBooking(bookingID, customer.CustomerName, numCustomers, nil, bookingTime, s.turnTime(numCustomers, bookingTime))
+		booking.CustomerDetails = customer
+		booking.SectionPreference = section
+		if hold > 0 {
+			booking.Status = models.BookingStatusPending
+			booking.HoldExpiresAt = now.Add(hold)
+		}
 
-	if tablesNeeded > availableTables {
-		return "", 0, 0, errors.ErrInsufficientTables
+		reserved, remainingTables, err := s.repo.ReserveTables(*booking, s.allocate)
+		if err == errors.ErrDuplicateBookingID {
+			continue
+		}
+		if err != nil {
+			if errors.IsNoTablesError(err) {
+				return models.Booking{}, 0, err
+			}
+			return models.Booking{}, 0, errors.NewReservationError(err.Error())
+		}
+
+		return reserved, remainingTables, nil
 	}
 
-	bookingID := s.generateBookingID()
-	booking := models.NewBooking(bookingID, "", numCustomers, tablesNeeded)
+	return models.Booking{}, 0, errors.NewReservationError(fmt.Sprintf("no unused booking code found after %d attempts", maxCodeAttempts))
+}
 
-	err := s.repo.ReserveTables(*booking)
+// allocate assigns tables that can be pushed together to booking from the
+// free tables using the configured strategy, in the section the guest asked
+// for if possible, leaving out tables the schedule keeps from online bookings
+// that day. The repository calls it while holding
+// its lock.
+func (s *service) allocate(booking models.Booking, free []models.Table) (models.Booking, error) {
+	if bookable := s.schedule.BookableTables(booking.BookingTime); bookable != nil {
+		var online []models.Table
+		for _, table := range free {
+			if slices.Contains(bookable, table.ID) {
+				online = append(online, table)
+			}
+		}
+		free = online
+	}
+
+	assigned, err := allocatePreferring(s.strategy, free, booking.NumCustomers, booking.SectionPreference)
 	if err != nil {
-		return "", 0, 0, errors.NewReservationError(err.Error())
+		return booking, err
 	}
+	booking.Section = sectionOf(assigned)
 
-	return bookingID, tablesNeeded, availableTables - tablesNeeded, nil
+	booking.TableIDs = make([]string, len(assigned))
+	booking.SeatsAssigned = 0
+	for i, table := range assigned {
+		booking.TableIDs[i] = table.ID
+		booking.SeatsAssigned += table.Capacity
+	}
+	return booking, nil
 }
 
 func (s *service) CancelReservation(bookingID string) (int, int, error) {
-	if !s.repo.IsInitialized() {
+	initialized, err := s.repo.IsInitialized()
+	if err != nil {
+		return 0, 0, err
+	}
+	if !initialized {
 		return 0, 0, errors.ErrTableNotInitialized
 	}
 
-	pattern := fmt.Sprintf("^[%s]{%d}$", regexp.QuoteMeta(s.charsetCode), s.lengthCode)
-	regexBookingID := regexp.MustCompile(pattern)
-	if !regexBookingID.MatchString(bookingID) {
-		return 0, 0, errors.ErrInvalidBookingID
+	booking, remainingTables, err := s.transition(bookingID, models.BookingStatusCancelled)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	s.promoteWaitlist()
+	return booking.TablesBooked(), remainingTables, nil
+}
+
+// SeatBooking records that the party has arrived and sat down, marking its
+// tables occupied as a walk-in would. It fails with ErrTableOccupied while
+// someone else is still sitting at one of them.
+func (s *service) SeatBooking(bookingID string) (models.Booking, error) {
+	booking, err := s.GetBooking(bookingID)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	if err := checkTransition(booking.Status, models.BookingStatusSeated); err != nil {
+		return models.Booking{}, err
+	}
+
+	if err := s.occupy(booking); err != nil {
+		return models.Booking{}, err
+	}
+	seated, _, err := s.transition(bookingID, models.BookingStatusSeated)
+	if err != nil {
+		s.vacate(booking)
+		return models.Booking{}, err
 	}
+	return seated, nil
+}
 
-	tablesFreed, err := s.repo.CancelReservation(bookingID)
+// CompleteBooking records that the party has left and clears their tables.
+// The tables are freed from now on instead of at the end of the reserved
+// window.
+func (s *service) CompleteBooking(bookingID string) (models.Booking, int, error) {
+	booking, remainingTables, err := s.transition(bookingID, models.BookingStatusCompleted)
 	if err != nil {
-		return 0, 0, errors.ErrInvalidBookingID
+		return models.Booking{}, 0, err
+	}
+	if err := s.vacate(booking); err != nil {
+		return models.Booking{}, 0, errors.NewReservationError(err.Error())
 	}
 
-	availableTables := s.repo.GetAvailableTables()
-	return tablesFreed, availableTables, nil
+	s.promoteWaitlist()
+	return booking, remainingTables, nil
+}
+
+// occupy marks the booking's tables occupied by its party, filling them in
+// order. If one of them is taken the tables marked so far are cleared again.
+func (s *service) occupy(booking models.Booking) error {
+	now := s.clock.Now()
+	remaining := booking.NumCustomers
+	for _, tableID := range booking.TableIDs {
+		seated, err := s.repo.UpdateOccupancy(tableID, now, func(table models.Table, reserved bool) (models.Table, error) {
+			if table.IsOccupied {
+				return table, errors.ErrTableOccupied
+			}
+
+			table.IsOccupied = true
+			table.OccupiedSince = now
+			table.PartySize = min(remaining, table.Capacity)
+			table.OccupiedBy = booking.ID
+			return table, nil
+		})
+		if err != nil {
+			s.vacate(booking)
+			return err
+		}
+		remaining -= seated.PartySize
+	}
+	return nil
 }
 
-func (s *service) GetAvailableTables() int {
-	return s.repo.GetAvailableTables()
+// vacate clears the booking's tables that its party still occupies,
+// returning the first error after trying them all. A table cleared by the
+// host and given to someone else is left alone, as are tables removed from
+// the inventory meanwhile.
+func (s *service) vacate(booking models.Booking) error {
+	var first error
+	for _, tableID := range booking.TableIDs {
+		_, err := s.repo.UpdateOccupancy(tableID, s.clock.Now(), func(table models.Table, reserved bool) (models.Table, error) {
+			if !table.IsOccupied || table.OccupiedBy != booking.ID {
+				return table, nil
+			}
+
+			table.IsOccupied = false
+			table.OccupiedSince = time.Time{}
+			table.PartySize = 0
+			table.OccupiedBy = ""
+			return table, nil
+		})
+		if err != nil && err != errors.ErrTableNotFound && first == nil {
+			first = err
+		}
+	}
+	return first
 }
 
-func (s *service) generateBookingID() string {
-	// Generate seeds for random generator
-	rand.Seed(uint64(time.Now().UnixNano()))
+// MarkNoShow records that the party never arrived, freeing their tables. It
+// is only allowed once the booking time has passed.
+func (s *service) MarkNoShow(bookingID string) (models.Booking, int, error) {
+	booking, remainingTables, err := s.transition(bookingID, models.BookingStatusNoShow)
+	if err == nil {
+		s.promoteWaitlist()
+	}
+	return booking, remainingTables, err
+}
 
-	result := make([]byte, s.lengthCode)
-	for i := range result {
-		result[i] = s.charsetCode[rand.Intn(len(s.charsetCode))]
+// transition moves a booking to status if the transition table allows it
+func (s *service) transition(bookingID string, status string) (models.Booking, int, error) {
+	if err := s.codes.Validate(bookingID); err != nil {
+		return models.Booking{}, 0, err
 	}
 
-	return string(result)
+	update := func(booking models.Booking) (models.Booking, error) {
+		if err := checkTransition(booking.Status, status); err != nil {
+			return booking, err
+		}
+
+		now := s.clock.Now()
+		switch status {
+		case models.BookingStatusNoShow:
+			if now.Before(booking.BookingTime) {
+				return booking, errors.NewTransitionError("A booking cannot be marked no_show before its booking time")
+			}
+		case models.BookingStatusCompleted:
+			// The tables are free from the moment the party leaves
+			if now.Before(booking.EndTime()) {
+				booking.Duration = max(now.Sub(booking.BookingTime), 0)
+			}
+		}
+
+		booking.Status = status
+		return booking, nil
+	}
+
+	booking, remainingTables, err := s.repo.UpdateStatus(bookingID, update)
+	if err != nil {
+		if err == errors.ErrInvalidBookingID || errors.IsTransitionError(err) {
+			return models.Booking{}, 0, err
+		}
+		return models.Booking{}, 0, errors.NewReservationError(err.Error())
+	}
+	return booking, remainingTables, nil
+}
+
+// ModifyReservation changes the party size and time of a booking, keeping
+// its code. A zero numCustomers or bookingTime leaves that part unchanged.
+// The tables are reallocated for the new party and window; if they cannot be
+// found the booking keeps its current tables and an error for which
+// errors.IsNoTablesError holds is returned.
+func (s *service) ModifyReservation(bookingID string, numCustomers int, bookingTime time.Time) (models.Booking, int, error) {
+	if err := s.codes.Validate(bookingID); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	if numCustomers < 0 {
+		return models.Booking{}, 0, errors.NewValidationError("Number of customers must be positive")
+	}
+	if !bookingTime.IsZero() {
+		if bookingTime.Before(s.clock.Now()) {
+			return models.Booking{}, 0, errors.NewValidationError("Booking time must not be in the past")
+		}
+		if err := s.schedule.Check(bookingTime); err != nil {
+			return models.Booking{}, 0, err
+		}
+	}
+
+	update := func(booking models.Booking) (models.Booking, error) {
+		if booking.Status != models.BookingStatusPending && booking.Status != models.BookingStatusConfirmed {
+			return booking, errors.NewTransitionError(fmt.Sprintf("A %s booking cannot be modified", booking.Status))
+		}
+		if numCustomers > 0 {
+			booking.NumCustomers = numCustomers
+		}
+		if !bookingTime.IsZero() {
+			booking.BookingTime = bookingTime
+		}
+		if booking.TurnTimeOverride == 0 {
+			booking.Duration = s.turnTime(booking.NumCustomers, booking.BookingTime)
+		}
+		return booking, nil
+	}
+
+	booking, remainingTables, err := s.repo.ModifyReservation(bookingID, update, s.allocate)
+	if err != nil {
+		if errors.IsNoTablesError(err) || err == errors.ErrInvalidBookingID || errors.IsTransitionError(err) {
+			return models.Booking{}, 0, err
+		}
+		return models.Booking{}, 0, errors.NewReservationError(err.Error())
+	}
+
+	// Moving or shrinking a booking can free tables for waiting parties
+	s.promoteWaitlist()
+	return booking, remainingTables, nil
+}
+
+// GetBooking looks up a booking by its code
+func (s *service) GetBooking(bookingID string) (models.Booking, error) {
+	if err := s.codes.Validate(bookingID); err != nil {
+		return models.Booking{}, err
+	}
+	return s.repo.GetBooking(bookingID)
+}
+
+// ListBookings returns a page of bookings matching filter in booking time
+// order, and the cursor for the next page or "" if this is the last one. An
+// empty cursor starts at the first booking and a zero limit means the default
+// page size.
+func (s *service) ListBookings(filter models.BookingFilter, cursor string, limit int) ([]models.Booking, string, error) {
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+	if limit < 0 || limit > maxPageSize {
+		return nil, "", errors.NewValidationError(fmt.Sprintf("Limit must be between 1 and %d", maxPageSize))
+	}
+	if filter.MinCustomers < 0 || filter.MaxCustomers < 0 {
+		return nil, "", errors.NewValidationError("Party size filters must not be negative")
+	}
+	if filter.MaxCustomers > 0 && filter.MinCustomers > filter.MaxCustomers {
+		return nil, "", errors.NewValidationError("Minimum party size must not exceed the maximum")
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return nil, "", errors.NewValidationError("Start of the date range must be before its end")
+	}
+
+	// Phone numbers and emails are searched the way they are stored
+	var err error
+	if filter.Phone, err = normalizePhone(filter.Phone); err != nil {
+		return nil, "", err
+	}
+	if filter.Email, err = normalizeEmail(filter.Email); err != nil {
+		return nil, "", err
+	}
+
+	var after *models.BookingCursor
+	if cursor != "" {
+		if after, err = decodeCursor(cursor); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// One extra booking tells whether there is a next page
+	bookings, err := s.repo.ListBookings(filter, after, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(bookings) <= limit {
+		return bookings, "", nil
+	}
+	bookings = bookings[:limit]
+	return bookings, encodeCursor(bookings[limit-1]), nil
+}
+
+func (s *service) GetAvailableTables(start, end time.Time) (int, error) {
+	availableTables, err := s.repo.GetAvailableTables(start, end)
+	if err != nil {
+		return 0, err
+	}
+	return len(availableTables), nil
+}
+
+// SeatWalkIn seats a party without a reservation at a table that is neither
+// occupied nor held by a booking right now
+func (s *service) SeatWalkIn(tableID string, partySize int) (models.Table, error) {
+	if partySize <= 0 {
+		return models.Table{}, errors.NewValidationError("Party size must be positive")
+	}
+
+	now := s.clock.Now()
+	return s.repo.UpdateOccupancy(tableID, now, func(table models.Table, reserved bool) (models.Table, error) {
+		if table.Retired {
+			return table, errors.ErrTableRetired
+		}
+		if table.IsOccupied {
+			return table, errors.ErrTableOccupied
+		}
+		if reserved {
+			return table, errors.ErrTableReserved
+		}
+		if partySize > table.Capacity {
+			return table, errors.NewValidationError(fmt.Sprintf("Table %s seats at most %d", table.ID, table.Capacity))
+		}
+
+		table.IsOccupied = true
+		table.OccupiedSince = now
+		table.PartySize = partySize
+		return table, nil
+	})
+}
+
+// ClearTable marks an occupied table as free once its party has left
+func (s *service) ClearTable(tableID string) (models.Table, error) {
+	return s.repo.UpdateOccupancy(tableID, s.clock.Now(), func(table models.Table, reserved bool) (models.Table, error) {
+		if !table.IsOccupied {
+			return table, errors.ErrTableNotOccupied
+		}
+
+		table.IsOccupied = false
+		table.OccupiedSince = time.Time{}
+		table.PartySize = 0
+		table.OccupiedBy = ""
+		return table, nil
+	})
+}
+
+// GetFloorStatus returns every table with its occupancy, the booking holding
+// it now and its next booking within a day
+func (s *service) GetFloorStatus() ([]models.TableStatus, error) {
+	tables, err := s.repo.GetTables()
+	if err != nil {
+		return nil, err
+	}
+
+	// Every booking that can overlap now starts at most the longest turn
+	// time ago
+	now := s.clock.Now()
+	filter := models.BookingFilter{From: now.Add(-s.longestTurnTime()), To: now.Add(24 * time.Hour)}
+	statuses := make([]models.TableStatus, len(tables))
+	index := make(map[string]int, len(tables))
+	for i, table := range tables {
+		statuses[i].Table = table
+		index[table.ID] = i
+	}
+
+	var after *models.BookingCursor
+	for {
+		bookings, err := s.repo.ListBookings(filter, after, maxPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, booking := range bookings {
+			if !booking.HoldsTables(now) {
+				continue
+			}
+			for _, tableID := range booking.TableIDs {
+				i, exists := index[tableID]
+				if !exists {
+					continue
+				}
+				booking := booking
+				if booking.Overlaps(now, now.Add(time.Nanosecond)) {
+					statuses[i].CurrentBooking = &booking
+				} else if booking.BookingTime.After(now) && statuses[i].NextBooking == nil {
+					statuses[i].NextBooking = &booking
+				}
+			}
+		}
+
+		if len(bookings) < maxPageSize {
+			return statuses, nil
+		}
+		last := bookings[len(bookings)-1]
+		after = &models.BookingCursor{BookingTime: last.BookingTime, ID: last.ID}
+	}
 }
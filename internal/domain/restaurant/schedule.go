@@ -0,0 +1,299 @@
+package restaurant
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"booking-dinner/internal/errors"
+)
+
+// Layouts of the dates and clock times in a schedule
+const (
+	dateLayout  = "2006-01-02"
+	clockLayout = "15:04"
+)
+
+// ServicePeriod is a window of the day, such as lunch or dinner, in which
+// bookings may start. Open and Close are clock times such as "17:30"; Close
+// is the last seating.
+type ServicePeriod struct {
+	Name  string
+	Open  string
+	Close string
+}
+
+// Closure is a date on which the restaurant takes no bookings, such as a
+// holiday
+type Closure struct {
+	Date   string
+	Reason string
+}
+
+// SpecialEvent changes the schedule for one date. If Open and Close are set
+// the event replaces the day's usual service periods, and if TableIDs is set
+// only those tables are bookable online that day.
+type SpecialEvent struct {
+	Name     string
+	Date     string
+	Open     string
+	Close    string
+	TableIDs []string
+}
+
+// period is a parsed service period, with its times as offsets from midnight
+type period struct {
+	name  string
+	open  time.Duration
+	close time.Duration
+}
+
+// Schedule decides when bookings may start and which tables are bookable
+// online. A nil Schedule accepts bookings at any time for every table.
+type Schedule struct {
+	location *time.Location
+	weekly   map[time.Weekday][]period
+	closures map[string]Closure
+	events   map[string]SpecialEvent
+	// eventPeriods holds the parsed hours of the events that set them
+	eventPeriods map[string][]period
+}
+
+// NewSchedule builds a schedule whose dates and clock times are in the IANA
+// time zone timezone. weekly maps lowercase day names to the names of the
+// periods served that day; days left out are closed.
+func NewSchedule(timezone string, periods []ServicePeriod, weekly map[string][]string, closures []Closure, events []SpecialEvent) (*Schedule, error) {
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", timezone)
+	}
+
+	byName := make(map[string]period, len(periods))
+	for _, p := range periods {
+		if p.Name == "" {
+			return nil, fmt.Errorf("service periods must have a name")
+		}
+		if _, exists := byName[p.Name]; exists {
+			return nil, fmt.Errorf("service period %q is defined more than once", p.Name)
+		}
+		parsed, err := parsePeriod(p.Name, p.Open, p.Close)
+		if err != nil {
+			return nil, err
+		}
+		byName[p.Name] = parsed
+	}
+
+	schedule := &Schedule{
+		location:     location,
+		weekly:       make(map[time.Weekday][]period, len(weekly)),
+		closures:     make(map[string]Closure, len(closures)),
+		events:       make(map[string]SpecialEvent, len(events)),
+		eventPeriods: make(map[string][]period),
+	}
+
+	for day, names := range weekly {
+		weekday, err := parseWeekday(day)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			p, exists := byName[name]
+			if !exists {
+				return nil, fmt.Errorf("%s serves unknown service period %q", day, name)
+			}
+			schedule.weekly[weekday] = append(schedule.weekly[weekday], p)
+		}
+		if err := sortPeriods(day, schedule.weekly[weekday]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, closure := range closures {
+		if _, err := time.Parse(dateLayout, closure.Date); err != nil {
+			return nil, fmt.Errorf("closure date %q must look like %s", closure.Date, dateLayout)
+		}
+		if _, exists := schedule.closures[closure.Date]; exists {
+			return nil, fmt.Errorf("%s is closed more than once", closure.Date)
+		}
+		schedule.closures[closure.Date] = closure
+	}
+
+	for _, event := range events {
+		if _, err := time.Parse(dateLayout, event.Date); err != nil {
+			return nil, fmt.Errorf("event date %q must look like %s", event.Date, dateLayout)
+		}
+		if _, exists := schedule.closures[event.Date]; exists {
+			return nil, fmt.Errorf("event %q falls on %s, which is closed", event.Name, event.Date)
+		}
+		if _, exists := schedule.events[event.Date]; exists {
+			return nil, fmt.Errorf("%s has more than one event", event.Date)
+		}
+		if event.Open != "" || event.Close != "" {
+			parsed, err := parsePeriod(event.Name, event.Open, event.Close)
+			if err != nil {
+				return nil, err
+			}
+			schedule.eventPeriods[event.Date] = []period{parsed}
+		}
+		schedule.events[event.Date] = event
+	}
+
+	return schedule, nil
+}
+
+// Check returns a ScheduleError explaining why a booking may not start at t,
+// or nil if it may
+func (s *Schedule) Check(t time.Time) error {
+	if s == nil {
+		return nil
+	}
+
+	local := t.In(s.location)
+	date := local.Format(dateLayout)
+	if closure, closed := s.closures[date]; closed {
+		msg := fmt.Sprintf("The restaurant is closed on %s", date)
+		if closure.Reason != "" {
+			msg += fmt.Sprintf(" (%s)", closure.Reason)
+		}
+		return errors.NewScheduleError(errors.ScheduleReasonClosure, date, nil, msg)
+	}
+
+	periods := s.periodsOn(local)
+	if len(periods) == 0 {
+		return errors.NewScheduleError(errors.ScheduleReasonClosedDay, date, nil,
+			fmt.Sprintf("The restaurant is closed on %ss", local.Weekday()))
+	}
+
+	offset := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second
+	open := make([]errors.ServicePeriod, len(periods))
+	descriptions := make([]string, len(periods))
+	for i, p := range periods {
+		if offset >= p.open && offset < p.close {
+			return nil
+		}
+		open[i] = errors.ServicePeriod{Name: p.name, Open: formatClock(p.open), Close: formatClock(p.close)}
+		descriptions[i] = fmt.Sprintf("%s %s-%s", open[i].Name, open[i].Open, open[i].Close)
+	}
+	return errors.NewScheduleError(errors.ScheduleReasonOutsideHours, date, open,
+		fmt.Sprintf("Bookings on %s must start during %s", date, strings.Join(descriptions, " or ")))
+}
+
+// PeriodAt returns the name of the service period a booking starting at t
+// falls in, or "" if none does. A special event's own hours are named after
+// the event.
+func (s *Schedule) PeriodAt(t time.Time) string {
+	if s == nil {
+		return ""
+	}
+	local := t.In(s.location)
+	offset := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second
+	for _, p := range s.periodsOn(local) {
+		if offset >= p.open && offset < p.close {
+			return p.name
+		}
+	}
+	return ""
+}
+
+// BookableTables returns the IDs of the tables bookable online at t, or nil
+// if every table is
+func (s *Schedule) BookableTables(t time.Time) []string {
+	if s == nil {
+		return nil
+	}
+	return s.events[t.In(s.location).Format(dateLayout)].TableIDs
+}
+
+// Location returns the time zone the schedule's hours are in, or nil for a
+// nil Schedule
+func (s *Schedule) Location() *time.Location {
+	if s == nil {
+		return nil
+	}
+	return s.location
+}
+
+// Slots returns the times on the day starting at midnight day, in the
+// schedule's time zone, at which bookings may start: every interval from the
+// opening of each service period until its close. A nil Schedule has slots
+// all day.
+func (s *Schedule) Slots(day time.Time, interval time.Duration) []time.Time {
+	periods := []period{{open: 0, close: 24 * time.Hour}}
+	if s != nil {
+		date := day.Format(dateLayout)
+		if _, closed := s.closures[date]; closed {
+			return nil
+		}
+		periods = s.periodsOn(day)
+	}
+
+	var slots []time.Time
+	for _, p := range periods {
+		for offset := p.open; offset < p.close; offset += interval {
+			hour, minute := int(offset/time.Hour), int(offset%time.Hour/time.Minute)
+			slots = append(slots, time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location()))
+		}
+	}
+	return slots
+}
+
+// periodsOn returns the service periods on the date of local, with a special
+// event's hours taking the place of the usual ones
+func (s *Schedule) periodsOn(local time.Time) []period {
+	if periods, exists := s.eventPeriods[local.Format(dateLayout)]; exists {
+		return periods
+	}
+	return s.weekly[local.Weekday()]
+}
+
+// parsePeriod parses a service period that opens and has its last seating at
+// the given clock times
+func parsePeriod(name, open, close string) (period, error) {
+	openAt, err := parseClock(open)
+	if err != nil {
+		return period{}, fmt.Errorf("%s opens at %q, which must look like %s", name, open, clockLayout)
+	}
+	closeAt, err := parseClock(close)
+	if err != nil {
+		return period{}, fmt.Errorf("%s closes at %q, which must look like %s", name, close, clockLayout)
+	}
+	if closeAt <= openAt {
+		return period{}, fmt.Errorf("%s must close after it opens", name)
+	}
+	return period{name: name, open: openAt, close: closeAt}, nil
+}
+
+// sortPeriods orders a day's periods by opening time and rejects overlaps
+func sortPeriods(day string, periods []period) error {
+	sort.Slice(periods, func(i, j int) bool {
+		return periods[i].open < periods[j].open
+	})
+	for i := 1; i < len(periods); i++ {
+		if periods[i].open < periods[i-1].close {
+			return fmt.Errorf("%s serves %s and %s at overlapping times", day, periods[i-1].name, periods[i].name)
+		}
+	}
+	return nil
+}
+
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse(clockLayout, value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func formatClock(offset time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(offset.Hours()), int(offset.Minutes())%60)
+}
+
+func parseWeekday(day string) (time.Weekday, error) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(day, weekday.String()) {
+			return weekday, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day %q", day)
+}
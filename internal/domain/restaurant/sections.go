@@ -0,0 +1,50 @@
+package restaurant
+
+import (
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/errors"
+)
+
+// allocatePreferring seats a party of numCustomers in the section preference
+// asks for if the free tables there can. Otherwise a strict preference fails
+// with ErrSectionUnavailable and a best-effort one is seated from every free
+// table.
+func allocatePreferring(strategy AllocationStrategy, free []models.Table, numCustomers int, preference models.SectionPreference) ([]models.Table, error) {
+	if preference.Section == "" {
+		return allocateOnFloor(strategy, free, numCustomers)
+	}
+
+	assigned, err := allocateOnFloor(strategy, inSection(free, preference.Section), numCustomers)
+	if err == nil {
+		return assigned, nil
+	}
+	if preference.Strict {
+		return nil, errors.ErrSectionUnavailable
+	}
+	return allocateOnFloor(strategy, free, numCustomers)
+}
+
+// inSection returns the tables in section, keeping their order
+func inSection(tables []models.Table, section string) []models.Table {
+	var matching []models.Table
+	for _, table := range tables {
+		if table.Section == section {
+			matching = append(matching, table)
+		}
+	}
+	return matching
+}
+
+// sectionOf returns the section all of tables are in, or "" if they span
+// more than one
+func sectionOf(tables []models.Table) string {
+	if len(tables) == 0 {
+		return ""
+	}
+	for _, table := range tables[1:] {
+		if table.Section != tables[0].Section {
+			return ""
+		}
+	}
+	return tables[0].Section
+}
@@ -0,0 +1,137 @@
+package restaurant
+
+import (
+	"fmt"
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/errors"
+)
+
+// maxTurnTime bounds turn times, both from the rules and set by managers on
+// single bookings
+const maxTurnTime = 8 * time.Hour
+
+// TurnTimeRule sets how long a booking keeps its tables for parties of
+// MinParty to MaxParty guests starting in the service period named Period. A
+// zero MaxParty has no upper bound and an empty Period matches any time.
+type TurnTimeRule struct {
+	MinParty int
+	MaxParty int
+	Period   string
+	Duration time.Duration
+}
+
+// TurnTimes are rules checked in order; the first that matches a booking sets
+// its turn time. No rules means every booking gets the reservation duration.
+type TurnTimes []TurnTimeRule
+
+// NewTurnTimes checks rules and returns them as TurnTimes
+func NewTurnTimes(rules []TurnTimeRule) (TurnTimes, error) {
+	for i, rule := range rules {
+		if rule.Duration <= 0 || rule.Duration > maxTurnTime {
+			return nil, fmt.Errorf("turn time rule %d must be positive and at most %s", i+1, maxTurnTime)
+		}
+		if rule.MinParty < 0 || rule.MaxParty < 0 {
+			return nil, fmt.Errorf("turn time rule %d has a negative party size", i+1)
+		}
+		if rule.MaxParty > 0 && rule.MaxParty < rule.MinParty {
+			return nil, fmt.Errorf("turn time rule %d has maxParty below minParty", i+1)
+		}
+	}
+	return TurnTimes(rules), nil
+}
+
+// For returns the turn time of the first rule matching a party of partySize
+// in the service period named period, and whether any rule matched
+func (t TurnTimes) For(partySize int, period string) (time.Duration, bool) {
+	for _, rule := range t {
+		if partySize < rule.MinParty || (rule.MaxParty > 0 && partySize > rule.MaxParty) {
+			continue
+		}
+		if rule.Period != "" && rule.Period != period {
+			continue
+		}
+		return rule.Duration, true
+	}
+	return 0, false
+}
+
+// turnTime returns how long a booking for numCustomers starting at
+// bookingTime keeps its tables
+func (s *service) turnTime(numCustomers int, bookingTime time.Time) time.Duration {
+	if duration, ok := s.turnTimes.For(numCustomers, s.schedule.PeriodAt(bookingTime)); ok {
+		return duration
+	}
+	return s.reservationDuration
+}
+
+// longestTurnTime bounds how long any booking keeps its tables, so bookings
+// holding tables at a time started at most this long before it
+func (s *service) longestTurnTime() time.Duration {
+	return max(s.reservationDuration, maxTurnTime)
+}
+
+// SetTurnTime lets a manager change how long a booking keeps its tables. A
+// zero turnTime drops the override and goes back to the turn time rules. The
+// booking keeps its tables if they are free for the new window; otherwise a
+// booking that is not seated yet is given other tables.
+func (s *service) SetTurnTime(bookingID string, turnTime time.Duration) (models.Booking, int, error) {
+	if err := s.codes.Validate(bookingID); err != nil {
+		return models.Booking{}, 0, err
+	}
+	if turnTime < 0 || turnTime > maxTurnTime {
+		return models.Booking{}, 0, errors.NewValidationError(fmt.Sprintf("Turn time must be between 0 and %s", maxTurnTime))
+	}
+
+	update := func(booking models.Booking) (models.Booking, error) {
+		switch booking.Status {
+		case models.BookingStatusPending, models.BookingStatusConfirmed, models.BookingStatusSeated:
+		default:
+			return booking, errors.NewTransitionError(fmt.Sprintf("The turn time of a %s booking cannot be changed", booking.Status))
+		}
+		booking.TurnTimeOverride = turnTime
+		booking.Duration = turnTime
+		if turnTime == 0 {
+			booking.Duration = s.turnTime(booking.NumCustomers, booking.BookingTime)
+		}
+		return booking, nil
+	}
+
+	allocate := func(booking models.Booking, free []models.Table) (models.Booking, error) {
+		if tablesFree(booking.TableIDs, free) {
+			return booking, nil
+		}
+		if booking.Status == models.BookingStatusSeated {
+			// The party is already sitting at its tables
+			return booking, errors.ErrInsufficientTables
+		}
+		return s.allocate(booking, free)
+	}
+
+	booking, remainingTables, err := s.repo.ModifyReservation(bookingID, update, allocate)
+	if err != nil {
+		if errors.IsNoTablesError(err) || err == errors.ErrInvalidBookingID || errors.IsTransitionError(err) {
+			return models.Booking{}, 0, err
+		}
+		return models.Booking{}, 0, errors.NewReservationError(err.Error())
+	}
+
+	// A shorter turn time can free tables for waiting parties
+	s.promoteWaitlist()
+	return booking, remainingTables, nil
+}
+
+// tablesFree reports whether every table in tableIDs is among free
+func tablesFree(tableIDs []string, free []models.Table) bool {
+	isFree := make(map[string]bool, len(free))
+	for _, table := range free {
+		isFree[table.ID] = true
+	}
+	for _, tableID := range tableIDs {
+		if !isFree[tableID] {
+			return false
+		}
+	}
+	return true
+}
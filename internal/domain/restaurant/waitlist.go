@@ -0,0 +1,290 @@
+package restaurant
+
+import (
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/errors"
+)
+
+// errOfferSettled aborts a waitlist update when another request has already
+// moved the entry on
+var errOfferSettled = errors.NewTransitionError("The waitlist entry has already been offered, booked or expired")
+
+// JoinWaitlist queues a party for the next tables that fit them. A zero
+// bookingTime means the party wants to dine as soon as tables free up. If
+// tables are free already they are offered straight away.
+func (s *service) JoinWaitlist(numCustomers int, bookingTime time.Time, customer models.CustomerDetails) (models.WaitlistEntry, error) {
+	if s.offerHold <= 0 {
+		return models.WaitlistEntry{}, errors.ErrWaitlistDisabled
+	}
+
+	initialized, err := s.repo.IsInitialized()
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	if !initialized {
+		return models.WaitlistEntry{}, errors.ErrTableNotInitialized
+	}
+
+	if numCustomers <= 0 {
+		return models.WaitlistEntry{}, errors.NewValidationError("Number of customers must be positive")
+	}
+	now := s.clock.Now()
+	if !bookingTime.IsZero() && bookingTime.Before(now) {
+		return models.WaitlistEntry{}, errors.NewValidationError("Booking time must not be in the past")
+	}
+	// A party that wants the first free tables has to be able to dine now
+	requested := bookingTime
+	if requested.IsZero() {
+		requested = now
+	}
+	if err := s.schedule.Check(requested); err != nil {
+		return models.WaitlistEntry{}, err
+	}
+
+	customer, err = normalizeCustomer(customer)
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	// The party has to be reachable when tables are offered
+	if customer.Phone == "" && customer.Email == "" {
+		return models.WaitlistEntry{}, errors.NewValidationError("A phone number or email is required to join the waitlist")
+	}
+
+	for attempt := 0; attempt < maxCodeAttempts; attempt++ {
+		entryID, err := s.codes.Generate()
+		if err != nil {
+			return models.WaitlistEntry{}, errors.NewReservationError(err.Error())
+		}
+
+		entry := models.NewWaitlistEntry(entryID, customer, numCustomers, bookingTime, now)
+		err = s.repo.AddToWaitlist(*entry)
+		if err == errors.ErrDuplicateWaitlistID {
+			continue
+		}
+		if err != nil {
+			return models.WaitlistEntry{}, errors.NewReservationError(err.Error())
+		}
+
+		s.promoteWaitlist()
+		return s.repo.GetWaitlistEntry(entryID)
+	}
+
+	return models.WaitlistEntry{}, errors.NewReservationError("no unused waitlist code found")
+}
+
+// GetWaitlistEntry looks up a waitlist entry by its code. Lapsed offers are
+// closed by the hold reaper; until it runs, such an entry is reported with
+// the status the reaper will give it.
+func (s *service) GetWaitlistEntry(entryID string) (models.WaitlistEntry, error) {
+	if err := s.validateEntryID(entryID); err != nil {
+		return models.WaitlistEntry{}, err
+	}
+
+	entry, err := s.repo.GetWaitlistEntry(entryID)
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	if s.offerHold > 0 && entry.Status == models.WaitlistStatusOffered && !s.clock.Now().Before(entry.OfferExpiresAt) {
+		entry.Status = s.lapsedOfferStatus(entry)
+	}
+	return entry, nil
+}
+
+// ConfirmWaitlistOffer accepts the tables offered to a waitlisted party,
+// turning the booking holding them into a confirmed one. It returns
+// ErrOfferExpired once the hold has lapsed.
+func (s *service) ConfirmWaitlistOffer(entryID string) (models.Booking, error) {
+	if err := s.validateEntryID(entryID); err != nil {
+		return models.Booking{}, err
+	}
+
+	now := s.clock.Now()
+	entry, err := s.repo.UpdateWaitlistEntry(entryID, func(entry models.WaitlistEntry) (models.WaitlistEntry, error) {
+		switch {
+		case entry.Status == models.WaitlistStatusExpired:
+			return entry, errors.ErrOfferExpired
+		case entry.Status != models.WaitlistStatusOffered:
+			return entry, errors.NewTransitionError("Tables have not been offered to this waitlist entry")
+		case !now.Before(entry.OfferExpiresAt):
+			return entry, errors.ErrOfferExpired
+		}
+		entry.Status = models.WaitlistStatusBooked
+		return entry, nil
+	})
+	if err != nil {
+		if err == errors.ErrOfferExpired {
+			// Release the lapsed hold now rather than on the reaper's next run
+			s.ReleaseExpiredHolds()
+			return models.Booking{}, err
+		}
+		if err == errors.ErrWaitlistEntryNotFound || errors.IsTransitionError(err) {
+			return models.Booking{}, err
+		}
+		return models.Booking{}, errors.NewReservationError(err.Error())
+	}
+
+	booking, _, err := s.repo.UpdateStatus(entry.BookingID, func(booking models.Booking) (models.Booking, error) {
+		if err := checkTransition(booking.Status, models.BookingStatusConfirmed); err != nil {
+			return booking, err
+		}
+		booking.Status = models.BookingStatusConfirmed
+		booking.HoldExpiresAt = time.Time{}
+		return booking, nil
+	})
+	if err != nil {
+		// The held booking was cancelled behind the offer's back
+		s.repo.UpdateWaitlistEntry(entryID, func(entry models.WaitlistEntry) (models.WaitlistEntry, error) {
+			entry.Status = models.WaitlistStatusExpired
+			return entry, nil
+		})
+		return models.Booking{}, errors.ErrOfferExpired
+	}
+	return booking, nil
+}
+
+// validateEntryID checks the shape of a waitlist code, which is generated
+// like a booking code
+func (s *service) validateEntryID(entryID string) error {
+	if s.offerHold <= 0 {
+		return errors.ErrWaitlistDisabled
+	}
+	err := s.codes.Validate(entryID)
+	if err == errors.ErrInvalidBookingID {
+		return errors.ErrWaitlistEntryNotFound
+	}
+	return err
+}
+
+// promoteWaitlist offers free tables to waiting parties in the order they
+// joined. It is best effort: a party that cannot be seated, or a failure to
+// offer, leaves the entry waiting for the next tables to free up.
+func (s *service) promoteWaitlist() {
+	if s.offerHold <= 0 {
+		return
+	}
+
+	entries, err := s.repo.ListWaitlist(models.WaitlistStatusWaiting)
+	if err != nil {
+		return
+	}
+
+	now := s.clock.Now()
+	for _, entry := range entries {
+		bookingTime := entry.BookingTime
+		if bookingTime.IsZero() {
+			bookingTime = now
+		} else if bookingTime.Before(now) {
+			// The time the party asked for has gone by
+			s.repo.UpdateWaitlistEntry(entry.ID, func(entry models.WaitlistEntry) (models.WaitlistEntry, error) {
+				if entry.Status != models.WaitlistStatusWaiting {
+					return entry, errOfferSettled
+				}
+				entry.Status = models.WaitlistStatusExpired
+				return entry, nil
+			})
+			continue
+		}
+		if s.schedule.Check(bookingTime) != nil {
+			// Parties waiting for the first free tables are only offered
+			// them while the restaurant takes bookings
+			continue
+		}
+		s.offer(entry, bookingTime, now)
+	}
+}
+
+// offer holds tables for a waiting party with a pending booking that lapses
+// after the offer hold
+func (s *service) offer(entry models.WaitlistEntry, bookingTime time.Time, now time.Time) {
+	for attempt := 0; attempt < maxCodeAttempts; attempt++ {
+		bookingID, err := s.codes.Generate()
+		if err != nil {
+			return
+		}
+		booking := models.NewBooking(bookingID, entry.CustomerName, entry.NumCustomers, nil, bookingTime, s.turnTime(entry.NumCustomers, bookingTime))
+		booking.CustomerDetails = entry.CustomerDetails
+		booking.Status = models.BookingStatusPending
+		booking.HoldExpiresAt = now.Add(s.offerHold)
+
+		held, _, err := s.repo.ReserveTables(*booking, s.allocate)
+		if err == errors.ErrDuplicateBookingID {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		_, err = s.repo.UpdateWaitlistEntry(entry.ID, func(entry models.WaitlistEntry) (models.WaitlistEntry, error) {
+			if entry.Status != models.WaitlistStatusWaiting {
+				return entry, errOfferSettled
+			}
+			entry.Status = models.WaitlistStatusOffered
+			entry.BookingID = held.ID
+			entry.OfferExpiresAt = held.HoldExpiresAt
+			return entry, nil
+		})
+		if err != nil {
+			// Another request got to the entry first
+			s.releaseHold(held.ID)
+		}
+		return
+	}
+}
+
+// expireOffers closes the offers whose hold lapsed by now. Their bookings are
+// cancelled if the repository has not released them already.
+func (s *service) expireOffers(now time.Time) {
+	if s.offerHold <= 0 {
+		return
+	}
+
+	entries, err := s.repo.ListWaitlist(models.WaitlistStatusOffered)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if now.Before(entry.OfferExpiresAt) {
+			continue
+		}
+
+		status := s.lapsedOfferStatus(entry)
+
+		// Expiring the entry first means a confirmation racing with this
+		// sweep either wins outright or fails with ErrOfferExpired
+		_, err := s.repo.UpdateWaitlistEntry(entry.ID, func(entry models.WaitlistEntry) (models.WaitlistEntry, error) {
+			if entry.Status != models.WaitlistStatusOffered || now.Before(entry.OfferExpiresAt) {
+				return entry, errOfferSettled
+			}
+			entry.Status = status
+			return entry, nil
+		})
+		if err == nil && status == models.WaitlistStatusExpired {
+			s.releaseHold(entry.BookingID)
+		}
+	}
+}
+
+// lapsedOfferStatus returns the status an offer whose hold lapsed settles
+// on: booked if the held booking was confirmed as a hold directly, otherwise
+// expired
+func (s *service) lapsedOfferStatus(entry models.WaitlistEntry) string {
+	if booking, err := s.repo.GetBooking(entry.BookingID); err == nil && booking.Status == models.BookingStatusConfirmed {
+		return models.WaitlistStatusBooked
+	}
+	return models.WaitlistStatusExpired
+}
+
+// releaseHold cancels a pending booking that was holding tables for a
+// waitlist offer
+func (s *service) releaseHold(bookingID string) {
+	s.repo.UpdateStatus(bookingID, func(booking models.Booking) (models.Booking, error) {
+		if booking.Status != models.BookingStatusPending {
+			return booking, errOfferSettled
+		}
+		booking.Status = models.BookingStatusCancelled
+		return booking, nil
+	})
+}
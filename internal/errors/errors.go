@@ -6,12 +6,30 @@ import (
 )
 
 var (
-	ErrTableInitialized     = errors.New("tables have already been initialized")
-	ErrTableNotInitialized  = errors.New("tables have not been initialized")
-	ErrInsufficientTables   = errors.New("not enough tables available for the reservation")
-	ErrInvalidBookingID     = errors.New("invalid booking ID")
-	ErrInvalidCustomerCount = errors.New("invalid customer count")
-	ErrMaxTablesExceeded    = errors.New("maximum number of tables exceeded")
+	ErrTableInitialized      = errors.New("tables have already been initialized")
+	ErrTableNotInitialized   = errors.New("tables have not been initialized")
+	ErrInsufficientTables    = errors.New("not enough tables available for the reservation")
+	ErrNoTableCombination    = errors.New("enough seats are free but no free tables can be pushed together to seat the party")
+	ErrSectionUnavailable    = errors.New("no free tables in the requested section can seat the party")
+	ErrInvalidBookingID      = errors.New("invalid booking ID")
+	ErrInvalidCustomerCount  = errors.New("invalid customer count")
+	ErrMaxTablesExceeded     = errors.New("maximum number of tables exceeded")
+	ErrDuplicateBookingID    = errors.New("booking ID already exists")
+	ErrTableNotFound         = errors.New("table not found")
+	ErrDuplicateTableID      = errors.New("table ID already exists")
+	ErrTableRetired          = errors.New("table is retired")
+	ErrTableHasBookings      = errors.New("the table holds upcoming bookings that cannot be moved to other tables")
+	ErrTableOccupied         = errors.New("table is already occupied")
+	ErrTableNotOccupied      = errors.New("table is not occupied")
+	ErrTableReserved         = errors.New("table is held by a booking right now")
+	ErrWaitlistDisabled      = errors.New("the waitlist is not enabled")
+	ErrWaitlistEntryNotFound = errors.New("waitlist entry not found")
+	ErrDuplicateWaitlistID   = errors.New("waitlist ID already exists")
+	ErrOfferExpired          = errors.New("the offered tables are no longer held")
+	ErrHoldsDisabled         = errors.New("holds are not enabled")
+	ErrHoldExpired           = errors.New("the hold has expired and its tables were released")
+	ErrIdempotencyKeyReused  = errors.New("the idempotency key was already used for a different request")
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is still being processed")
 )
 
 type RestaurantError struct {
@@ -38,8 +56,14 @@ const (
 	ErrCodeReservation    = "RESERVATION_ERROR"
 	ErrCodeCancellation   = "CANCELLATION_ERROR"
 	ErrCodeValidation     = "VALIDATION_ERROR"
+	ErrCodeCheckCharacter = "CHECK_CHARACTER_ERROR"
+	ErrCodeTransition     = "INVALID_TRANSITION"
 )
 
+// ErrInvalidCheckCharacter is returned for a booking ID whose check character
+// does not match the rest of the code, which usually means it was mistyped
+var ErrInvalidCheckCharacter = NewRestaurantError(ErrCodeCheckCharacter, "booking ID check character does not match, please check the code for typos")
+
 // Helper functions to create specific errors
 func NewInitializationError(msg string) *RestaurantError {
 	return NewRestaurantError(ErrCodeInitialization, msg)
@@ -56,3 +80,80 @@ func NewCancellationError(msg string) *RestaurantError {
 func NewValidationError(msg string) *RestaurantError {
 	return NewRestaurantError(ErrCodeValidation, msg)
 }
+
+func NewTransitionError(msg string) *RestaurantError {
+	return NewRestaurantError(ErrCodeTransition, msg)
+}
+
+// Reasons a booking time falls outside the restaurant's schedule
+const (
+	ScheduleReasonClosure      = "CLOSURE"
+	ScheduleReasonClosedDay    = "CLOSED_DAY"
+	ScheduleReasonOutsideHours = "OUTSIDE_HOURS"
+)
+
+// ServicePeriod is a window of the day in which bookings may start, given as
+// local clock times
+type ServicePeriod struct {
+	Name  string
+	Open  string
+	Close string
+}
+
+// ScheduleError is a validation error for a booking time the restaurant does
+// not accept. Reason says why, Date is the local date asked for and Periods
+// lists the service periods open that day, if any.
+type ScheduleError struct {
+	*RestaurantError
+	Reason  string
+	Date    string
+	Periods []ServicePeriod
+}
+
+// NewScheduleError creates a ScheduleError, which is also a validation error
+func NewScheduleError(reason string, date string, periods []ServicePeriod, msg string) *ScheduleError {
+	return &ScheduleError{
+		RestaurantError: NewValidationError(msg),
+		Reason:          reason,
+		Date:            date,
+		Periods:         periods,
+	}
+}
+
+// Unwrap returns the underlying validation error
+func (e *ScheduleError) Unwrap() error {
+	return e.RestaurantError
+}
+
+// IsNoTablesError reports whether err means the free tables cannot seat a
+// party: too few seats, no tables that can be pushed together, or none in a
+// section the guest insisted on
+func IsNoTablesError(err error) bool {
+	return err == ErrInsufficientTables || err == ErrNoTableCombination || err == ErrSectionUnavailable
+}
+
+// IsValidationError reports whether err is a RestaurantError caused by invalid input
+func IsValidationError(err error) bool {
+	return hasCode(err, ErrCodeValidation)
+}
+
+// IsInitializationError reports whether err is a RestaurantError raised while setting up tables
+func IsInitializationError(err error) bool {
+	return hasCode(err, ErrCodeInitialization)
+}
+
+// IsTransitionError reports whether err is a RestaurantError for a booking status change that is not allowed
+func IsTransitionError(err error) bool {
+	return hasCode(err, ErrCodeTransition)
+}
+
+// AsScheduleError returns the ScheduleError in err's chain, if there is one
+func AsScheduleError(err error) (*ScheduleError, bool) {
+	var scheduleErr *ScheduleError
+	return scheduleErr, errors.As(err, &scheduleErr)
+}
+
+func hasCode(err error, code string) bool {
+	var restaurantErr *RestaurantError
+	return errors.As(err, &restaurantErr) && restaurantErr.Code == code
+}
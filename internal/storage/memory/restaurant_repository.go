@@ -2,28 +2,51 @@ package memory
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"booking-dinner/internal/domain/models"
+	apperrors "booking-dinner/internal/errors"
+	"booking-dinner/pkg/clock"
 )
 
 // RestaurantRepository represents an in-memory storage for restaurant data
 type RestaurantRepository struct {
-	tables        int
-	bookings      map[string]models.Booking
+	tables   []models.Table
+	bookings map[string]models.Booking
+	// byTime holds the booking IDs ordered by booking time and then ID, so
+	// listing bookings is a binary search and a scan rather than a sort
+	byTime []string
+	// longest is the longest duration of any stored booking, which bounds
+	// how far before a window the bookings overlapping it can start
+	longest  time.Duration
+	waitlist map[string]models.WaitlistEntry
+	// waitlistOrder holds the waitlist entry IDs ordered by when they joined
+	// and then ID
+	waitlistOrder []string
+	// idempotency holds the responses stored under idempotency keys
+	idempotency   map[string]models.IdempotencyRecord
 	mutex         sync.RWMutex
 	isInitialized bool
+	// clock decides when lapsed holds stop holding their tables
+	clock clock.Clock
 }
 
 // NewRestaurantRepository creates a new instance of RestaurantRepository
-func NewRestaurantRepository() *RestaurantRepository {
+func NewRestaurantRepository(clock clock.Clock) *RestaurantRepository {
 	return &RestaurantRepository{
-		bookings: make(map[string]models.Booking),
+		bookings:    make(map[string]models.Booking),
+		waitlist:    make(map[string]models.WaitlistEntry),
+		idempotency: make(map[string]models.IdempotencyRecord),
+		clock:       clock,
 	}
 }
 
-// InitializeTables sets the initial number of tables in the restaurant
-func (r *RestaurantRepository) InitializeTables(tables int) error {
+// InitializeTables stores the restaurant's table inventory
+func (r *RestaurantRepository) InitializeTables(tables []models.Table) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
@@ -31,54 +54,517 @@ func (r *RestaurantRepository) InitializeTables(tables int) error {
 		return errors.New("tables have already been initialized")
 	}
 
-	r.tables = tables
+	r.tables = append([]models.Table(nil), tables...)
 	r.isInitialized = true
 	return nil
 }
 
-// ReserveTables reserves tables for a booking
-func (r *RestaurantRepository) ReserveTables(booking models.Booking) error {
+// ReserveTables assigns tables to the booking from those free during its
+// window and stores it, all under the write lock
+func (r *RestaurantRepository) ReserveTables(booking models.Booking, allocate func(booking models.Booking, free []models.Table) (models.Booking, error)) (models.Booking, int, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
 	if !r.isInitialized {
-		return errors.New("tables have not been initialized")
+		return models.Booking{}, 0, errors.New("tables have not been initialized")
 	}
 
-	if r.tables < booking.TablesBooked {
-		return errors.New("not enough tables available")
+	if _, exists := r.bookings[booking.ID]; exists {
+		return models.Booking{}, 0, apperrors.ErrDuplicateBookingID
 	}
 
-	r.bookings[booking.ID] = booking
-	r.tables -= booking.TablesBooked
-	return nil
+	free := r.freeTables(booking.BookingTime, booking.EndTime(), "")
+	booking, err := allocate(booking, free)
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	if err := checkTablesFree(booking, free); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	r.store(booking)
+	return booking, len(free) - booking.TablesBooked(), nil
+}
+
+// UpdateStatus applies update to a booking's status, duration and hold
+// expiry under the write lock
+func (r *RestaurantRepository) UpdateStatus(bookingID string, update func(booking models.Booking) (models.Booking, error)) (models.Booking, int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	current, exists := r.bookings[bookingID]
+	if !exists {
+		return models.Booking{}, 0, apperrors.ErrInvalidBookingID
+	}
+
+	updated, err := update(current)
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	booking := current
+	booking.Status = updated.Status
+	booking.Duration = updated.Duration
+	booking.HoldExpiresAt = updated.HoldExpiresAt
+	r.bookings[current.ID] = booking
+	r.longest = max(r.longest, booking.Duration)
+	return booking, len(r.freeTables(current.BookingTime, current.EndTime(), "")), nil
 }
 
-// CancelReservation cancels a booking and frees up the tables
-func (r *RestaurantRepository) CancelReservation(bookingID string) (int, error) {
+// ModifyReservation applies update to a booking and reallocates its tables
+// from those free in the updated window, counting the booking's own tables as
+// free, all under the write lock. The stored booking is left as it was if
+// update or allocate fails.
+func (r *RestaurantRepository) ModifyReservation(bookingID string, update func(booking models.Booking) (models.Booking, error), allocate func(booking models.Booking, free []models.Table) (models.Booking, error)) (models.Booking, int, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
+	current, exists := r.bookings[bookingID]
+	if !exists {
+		return models.Booking{}, 0, apperrors.ErrInvalidBookingID
+	}
+
+	booking, err := update(current)
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	booking.ID = current.ID
+
+	free := r.freeTables(booking.BookingTime, booking.EndTime(), bookingID)
+	booking, err = allocate(booking, free)
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	if err := checkTablesFree(booking, free); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	r.remove(current)
+	r.store(booking)
+	return booking, len(free) - booking.TablesBooked(), nil
+}
+
+// GetBooking returns the booking with the given ID
+func (r *RestaurantRepository) GetBooking(bookingID string) (models.Booking, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
 	booking, exists := r.bookings[bookingID]
 	if !exists {
-		return 0, errors.New("booking not found")
+		return models.Booking{}, apperrors.ErrInvalidBookingID
+	}
+	return booking, nil
+}
+
+// ListBookings returns up to limit bookings matching filter in booking time
+// order, starting after the cursor if one is given
+func (r *RestaurantRepository) ListBookings(filter models.BookingFilter, after *models.BookingCursor, limit int) ([]models.Booking, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	start := 0
+	if after != nil {
+		start = r.searchByTime(*after)
+		if start < len(r.byTime) && r.byTime[start] == after.ID {
+			start++
+		}
+	}
+	if !filter.From.IsZero() {
+		if i := r.searchByTime(models.BookingCursor{BookingTime: filter.From}); i > start {
+			start = i
+		}
 	}
 
-	delete(r.bookings, bookingID)
-	r.tables += booking.TablesBooked
-	return booking.TablesBooked, nil
+	bookings := []models.Booking{}
+	for _, id := range r.byTime[start:] {
+		if len(bookings) == limit {
+			break
+		}
+		booking := r.bookings[id]
+		if !filter.To.IsZero() && !booking.BookingTime.Before(filter.To) {
+			break
+		}
+		if filter.Matches(booking) {
+			bookings = append(bookings, booking)
+		}
+	}
+	return bookings, nil
 }
 
-// GetAvailableTables returns the number of available tables
-func (r *RestaurantRepository) GetAvailableTables() int {
+// GetAvailableTables returns the tables free for the whole of [start, end)
+func (r *RestaurantRepository) GetAvailableTables(start, end time.Time) ([]models.Table, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	return r.tables
+	return r.freeTables(start, end, ""), nil
+}
+
+// GetReservedWindows returns the tables and the windows in which bookings
+// hold them during [start, end). Only bookings starting after start minus the
+// longest booking duration are looked at, found by binary search on byTime.
+func (r *RestaurantRepository) GetReservedWindows(start, end time.Time) ([]models.Table, []models.ReservedWindow, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	now := r.clock.Now()
+	var windows []models.ReservedWindow
+	for i := r.searchByTime(models.BookingCursor{BookingTime: start.Add(-r.longest)}); i < len(r.byTime); i++ {
+		booking := r.bookings[r.byTime[i]]
+		if !booking.BookingTime.Before(end) {
+			break
+		}
+		if !booking.HoldsTables(now) || !booking.Overlaps(start, end) {
+			continue
+		}
+		for _, tableID := range booking.TableIDs {
+			windows = append(windows, models.ReservedWindow{TableID: tableID, Start: booking.BookingTime, End: booking.EndTime()})
+		}
+	}
+
+	tables := append([]models.Table(nil), r.tables...)
+	return tables, windows, nil
+}
+
+// GetTables returns every table with its occupancy
+func (r *RestaurantRepository) GetTables() ([]models.Table, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return append([]models.Table{}, r.tables...), nil
+}
+
+// UpdateOccupancy applies update to a table's occupancy under the write lock
+func (r *RestaurantRepository) UpdateOccupancy(tableID string, at time.Time, update func(table models.Table, reserved bool) (models.Table, error)) (models.Table, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, table := range r.tables {
+		if table.ID != tableID {
+			continue
+		}
+
+		reserved := r.bookedTables(at, at.Add(time.Nanosecond), "")[tableID]
+		updated, err := update(table, reserved)
+		if err != nil {
+			return models.Table{}, err
+		}
+
+		table.IsOccupied = updated.IsOccupied
+		table.OccupiedSince = updated.OccupiedSince
+		table.PartySize = updated.PartySize
+		table.OccupiedBy = updated.OccupiedBy
+		r.tables[i] = table
+		return table, nil
+	}
+	return models.Table{}, apperrors.ErrTableNotFound
 }
 
 // IsInitialized checks if the tables have been initialized
-func (r *RestaurantRepository) IsInitialized() bool {
+func (r *RestaurantRepository) IsInitialized() (bool, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.isInitialized, nil
+}
+
+// AddTable appends a table to the inventory under the write lock
+func (r *RestaurantRepository) AddTable(table models.Table, maxTables int) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	inService := 0
+	for _, existing := range r.tables {
+		if existing.ID == table.ID {
+			return apperrors.ErrDuplicateTableID
+		}
+		if !existing.Retired {
+			inService++
+		}
+	}
+	if inService >= maxTables {
+		return apperrors.ErrMaxTablesExceeded
+	}
+	r.tables = append(r.tables, table)
+	return nil
+}
+
+// RetireTable marks a table retired and moves the bookings holding it at or
+// after now under the write lock, undoing every change if check fails
+func (r *RestaurantRepository) RetireTable(tableID string, now time.Time, remove bool, move func(booking models.Booking, free []models.Table) (models.Booking, error), check func(moved, stranded []models.Booking) error) (models.TableChange, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	index := -1
+	for i, table := range r.tables {
+		if table.ID == tableID {
+			index = i
+		}
+	}
+	if index < 0 {
+		return models.TableChange{}, apperrors.ErrTableNotFound
+	}
+	previous := r.tables[index]
+	r.tables[index].Retired = true
+
+	var affected []models.Booking
+	for _, id := range r.byTime {
+		booking := r.bookings[id]
+		if booking.HoldsTables(now) && booking.EndTime().After(now) && slices.Contains(booking.TableIDs, tableID) {
+			affected = append(affected, booking)
+		}
+	}
+
+	change := models.TableChange{TableID: tableID}
+	var originals []models.Booking
+	for _, booking := range affected {
+		free := r.freeTables(booking.BookingTime, booking.EndTime(), booking.ID)
+		moved, err := move(booking, free)
+		if err == nil {
+			moved.ID = booking.ID
+			err = checkTablesFree(moved, free)
+		}
+		if err != nil {
+			change.Stranded = append(change.Stranded, booking)
+			continue
+		}
+		r.remove(booking)
+		r.store(moved)
+		originals = append(originals, booking)
+		change.Moved = append(change.Moved, moved)
+	}
+
+	if err := check(change.Moved, change.Stranded); err != nil {
+		for i, booking := range originals {
+			r.remove(change.Moved[i])
+			r.store(booking)
+		}
+		r.tables[index] = previous
+		return models.TableChange{}, err
+	}
+
+	if remove && !r.referenced(tableID) {
+		r.tables = slices.Delete(r.tables, index, index+1)
+		change.Deleted = true
+	}
+	return change, nil
+}
+
+// ReleaseExpiredHolds cancels the pending bookings whose hold expired by now
+// under the write lock
+func (r *RestaurantRepository) ReleaseExpiredHolds(now time.Time) ([]models.Booking, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	released := []models.Booking{}
+	for _, id := range r.byTime {
+		booking := r.bookings[id]
+		if booking.Status != models.BookingStatusPending || booking.HoldExpiresAt.IsZero() || now.Before(booking.HoldExpiresAt) {
+			continue
+		}
+		booking.Status = models.BookingStatusCancelled
+		r.bookings[id] = booking
+		released = append(released, booking)
+	}
+	return released, nil
+}
+
+// AddToWaitlist stores a new waitlist entry in its place in the queue
+func (r *RestaurantRepository) AddToWaitlist(entry models.WaitlistEntry) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, exists := r.waitlist[entry.ID]; exists {
+		return apperrors.ErrDuplicateWaitlistID
+	}
+
+	r.waitlist[entry.ID] = entry
+	i := sort.Search(len(r.waitlistOrder), func(i int) bool {
+		other := r.waitlist[r.waitlistOrder[i]]
+		if !other.CreatedAt.Equal(entry.CreatedAt) {
+			return other.CreatedAt.After(entry.CreatedAt)
+		}
+		return other.ID >= entry.ID
+	})
+	r.waitlistOrder = append(r.waitlistOrder, "")
+	copy(r.waitlistOrder[i+1:], r.waitlistOrder[i:])
+	r.waitlistOrder[i] = entry.ID
+	return nil
+}
+
+// GetWaitlistEntry returns the waitlist entry with the given ID
+func (r *RestaurantRepository) GetWaitlistEntry(entryID string) (models.WaitlistEntry, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	entry, exists := r.waitlist[entryID]
+	if !exists {
+		return models.WaitlistEntry{}, apperrors.ErrWaitlistEntryNotFound
+	}
+	return entry, nil
+}
+
+// ListWaitlist returns the entries with the given status in the order they
+// joined
+func (r *RestaurantRepository) ListWaitlist(status string) ([]models.WaitlistEntry, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
-	return r.isInitialized
+
+	entries := []models.WaitlistEntry{}
+	for _, id := range r.waitlistOrder {
+		if entry := r.waitlist[id]; entry.Status == status {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// UpdateWaitlistEntry applies update to an entry's offer state under the
+// write lock
+func (r *RestaurantRepository) UpdateWaitlistEntry(entryID string, update func(entry models.WaitlistEntry) (models.WaitlistEntry, error)) (models.WaitlistEntry, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	current, exists := r.waitlist[entryID]
+	if !exists {
+		return models.WaitlistEntry{}, apperrors.ErrWaitlistEntryNotFound
+	}
+
+	updated, err := update(current)
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+
+	entry := current
+	entry.Status = updated.Status
+	entry.BookingID = updated.BookingID
+	entry.OfferExpiresAt = updated.OfferExpiresAt
+	r.waitlist[current.ID] = entry
+	return entry, nil
+}
+
+// ClaimIdempotencyKey stores record under its key, unless a live record is
+// already there, after dropping the expired ones
+func (r *RestaurantRepository) ClaimIdempotencyKey(record models.IdempotencyRecord, now time.Time) (models.IdempotencyRecord, bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, stored := range r.idempotency {
+		if !now.Before(stored.ExpiresAt) {
+			delete(r.idempotency, key)
+		}
+	}
+
+	if stored, exists := r.idempotency[record.Key]; exists {
+		return stored, false, nil
+	}
+	r.idempotency[record.Key] = record
+	return record, true, nil
+}
+
+// SaveIdempotentResponse stores the response on the record under key
+func (r *RestaurantRepository) SaveIdempotentResponse(key string, statusCode int, body []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	record, exists := r.idempotency[key]
+	if !exists {
+		return nil
+	}
+	record.StatusCode = statusCode
+	record.Body = append([]byte(nil), body...)
+	r.idempotency[record.Key] = record
+	return nil
+}
+
+// ReleaseIdempotencyKey deletes the record under key
+func (r *RestaurantRepository) ReleaseIdempotencyKey(key string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.idempotency, key)
+	return nil
+}
+
+// store adds a booking and indexes it by time. The caller must hold the mutex.
+func (r *RestaurantRepository) store(booking models.Booking) {
+	r.bookings[booking.ID] = booking
+	r.longest = max(r.longest, booking.Duration)
+	i := r.searchByTime(models.BookingCursor{BookingTime: booking.BookingTime, ID: booking.ID})
+	r.byTime = append(r.byTime, "")
+	copy(r.byTime[i+1:], r.byTime[i:])
+	r.byTime[i] = booking.ID
+}
+
+// remove deletes a stored booking and its index entry. The caller must hold
+// the mutex.
+func (r *RestaurantRepository) remove(booking models.Booking) {
+	i := r.searchByTime(models.BookingCursor{BookingTime: booking.BookingTime, ID: booking.ID})
+	r.byTime = append(r.byTime[:i], r.byTime[i+1:]...)
+	delete(r.bookings, booking.ID)
+}
+
+// referenced reports whether any booking, whatever its status, was assigned
+// the table. The caller must hold the mutex.
+func (r *RestaurantRepository) referenced(tableID string) bool {
+	for _, booking := range r.bookings {
+		if slices.Contains(booking.TableIDs, tableID) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchByTime returns the index in byTime of the first booking that is not
+// before the cursor. The caller must hold the mutex.
+func (r *RestaurantRepository) searchByTime(cursor models.BookingCursor) int {
+	return sort.Search(len(r.byTime), func(i int) bool {
+		booking := r.bookings[r.byTime[i]]
+		if !booking.BookingTime.Equal(cursor.BookingTime) {
+			return booking.BookingTime.After(cursor.BookingTime)
+		}
+		return booking.ID >= cursor.ID
+	})
+}
+
+// freeTables returns the tables in service, in inventory order, that no
+// booking other than ignoreID holds during [start, end) and nobody is sitting
+// at if the window covers now. The caller must hold the mutex.
+func (r *RestaurantRepository) freeTables(start, end time.Time, ignoreID string) []models.Table {
+	now := r.clock.Now()
+	booked := r.bookedTables(start, end, ignoreID)
+	free := make([]models.Table, 0, len(r.tables))
+	for _, table := range r.tables {
+		if !table.Retired && !booked[table.ID] && !table.OccupiedDuring(start, end, now) {
+			free = append(free, table)
+		}
+	}
+	return free
+}
+
+// bookedTables returns the IDs of the tables a booking other than ignoreID
+// holds during [start, end). The caller must hold the mutex.
+func (r *RestaurantRepository) bookedTables(start, end time.Time, ignoreID string) map[string]bool {
+	now := r.clock.Now()
+	booked := make(map[string]bool)
+	for _, booking := range r.bookings {
+		if booking.ID == ignoreID || !booking.HoldsTables(now) || !booking.Overlaps(start, end) {
+			continue
+		}
+		for _, tableID := range booking.TableIDs {
+			booked[tableID] = true
+		}
+	}
+	return booked
+}
+
+// checkTablesFree returns an error if the booking was assigned a table that
+// is not in free
+func checkTablesFree(booking models.Booking, free []models.Table) error {
+	isFree := make(map[string]bool, len(free))
+	for _, table := range free {
+		isFree[table.ID] = true
+	}
+	for _, tableID := range booking.TableIDs {
+		if !isFree[tableID] {
+			return fmt.Errorf("table %s is not available", tableID)
+		}
+	}
+	return nil
 }
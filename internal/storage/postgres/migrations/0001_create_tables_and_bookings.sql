@@ -0,0 +1,28 @@
+CREATE TABLE restaurant_tables (
+    id       TEXT PRIMARY KEY,
+    position INTEGER NOT NULL,
+    capacity INTEGER NOT NULL CHECK (capacity > 0)
+);
+
+-- Times are Unix nanoseconds, matching the other SQL backends
+CREATE TABLE bookings (
+    id             TEXT PRIMARY KEY,
+    customer_name  TEXT NOT NULL DEFAULT '',
+    num_customers  INTEGER NOT NULL,
+    seats_assigned INTEGER NOT NULL,
+    booking_time   BIGINT NOT NULL,
+    duration       BIGINT NOT NULL
+);
+
+-- One row per table held by a booking. The booking window is copied here so
+-- availability checks only need this table.
+CREATE TABLE booking_tables (
+    booking_id   TEXT NOT NULL REFERENCES bookings (id) ON DELETE CASCADE,
+    table_id     TEXT NOT NULL REFERENCES restaurant_tables (id),
+    position     INTEGER NOT NULL,
+    booking_time BIGINT NOT NULL,
+    end_time     BIGINT NOT NULL,
+    PRIMARY KEY (booking_id, table_id)
+);
+
+CREATE INDEX booking_tables_window ON booking_tables (table_id, booking_time, end_time);
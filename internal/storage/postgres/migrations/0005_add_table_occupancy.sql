@@ -0,0 +1,4 @@
+-- Who is sitting at each table right now, tracked apart from reservations
+ALTER TABLE restaurant_tables ADD COLUMN occupied BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE restaurant_tables ADD COLUMN occupied_since BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE restaurant_tables ADD COLUMN party_size INTEGER NOT NULL DEFAULT 0;
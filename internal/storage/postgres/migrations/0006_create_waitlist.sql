@@ -0,0 +1,22 @@
+-- Parties waiting for tables. An offered entry has tables held for it by a
+-- pending booking until offer_expires_at.
+CREATE TABLE waitlist (
+    id               TEXT PRIMARY KEY,
+    customer_name    TEXT NOT NULL DEFAULT '',
+    customer_phone   TEXT NOT NULL DEFAULT '',
+    customer_email   TEXT NOT NULL DEFAULT '',
+    special_requests TEXT NOT NULL DEFAULT '',
+    dietary_notes    TEXT NOT NULL DEFAULT '',
+    num_customers    INTEGER NOT NULL,
+    booking_time     BIGINT NOT NULL DEFAULT 0,
+    status           TEXT NOT NULL,
+    booking_id       TEXT NOT NULL DEFAULT '',
+    offer_expires_at BIGINT NOT NULL DEFAULT 0,
+    created_at       BIGINT NOT NULL
+);
+
+-- Parties are offered tables in the order they joined
+CREATE INDEX waitlist_by_status ON waitlist (status, created_at, id);
+
+-- When a pending booking stops holding its tables unless confirmed
+ALTER TABLE bookings ADD COLUMN hold_expires_at BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+-- Availability reads every table window overlapping a day. Windows that ended
+-- before the day are skipped by seeking on end_time.
+CREATE INDEX booking_tables_by_end ON booking_tables (end_time, booking_time);
@@ -0,0 +1,4 @@
+-- Which tables can be pushed together. adjacent is a comma separated list of
+-- table IDs.
+ALTER TABLE restaurant_tables ADD COLUMN join_group TEXT NOT NULL DEFAULT '';
+ALTER TABLE restaurant_tables ADD COLUMN adjacent TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- The section each table stands in, and the section a booking asked for and
+-- was given
+ALTER TABLE restaurant_tables ADD COLUMN section TEXT NOT NULL DEFAULT '';
+ALTER TABLE bookings ADD COLUMN requested_section TEXT NOT NULL DEFAULT '';
+ALTER TABLE bookings ADD COLUMN section_strict BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE bookings ADD COLUMN section TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,40 @@
+-- Every row belongs to a restaurant and IDs only need to be unique within
+-- one, so the keys become (restaurant_id, id). Rows written before there were
+-- several restaurants belong to the default one.
+ALTER TABLE booking_tables DROP CONSTRAINT booking_tables_booking_id_fkey;
+ALTER TABLE booking_tables DROP CONSTRAINT booking_tables_table_id_fkey;
+ALTER TABLE booking_tables DROP CONSTRAINT booking_tables_pkey;
+
+ALTER TABLE restaurant_tables ADD COLUMN restaurant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE restaurant_tables ALTER COLUMN restaurant_id DROP DEFAULT;
+ALTER TABLE restaurant_tables DROP CONSTRAINT restaurant_tables_pkey;
+ALTER TABLE restaurant_tables ADD PRIMARY KEY (restaurant_id, id);
+
+ALTER TABLE bookings ADD COLUMN restaurant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE bookings ALTER COLUMN restaurant_id DROP DEFAULT;
+ALTER TABLE bookings DROP CONSTRAINT bookings_pkey;
+ALTER TABLE bookings ADD PRIMARY KEY (restaurant_id, id);
+
+ALTER TABLE waitlist ADD COLUMN restaurant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE waitlist ALTER COLUMN restaurant_id DROP DEFAULT;
+ALTER TABLE waitlist DROP CONSTRAINT waitlist_pkey;
+ALTER TABLE waitlist ADD PRIMARY KEY (restaurant_id, id);
+
+ALTER TABLE booking_tables ADD COLUMN restaurant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE booking_tables ALTER COLUMN restaurant_id DROP DEFAULT;
+ALTER TABLE booking_tables ADD PRIMARY KEY (restaurant_id, booking_id, table_id);
+ALTER TABLE booking_tables ADD FOREIGN KEY (restaurant_id, booking_id) REFERENCES bookings (restaurant_id, id) ON DELETE CASCADE;
+ALTER TABLE booking_tables ADD FOREIGN KEY (restaurant_id, table_id) REFERENCES restaurant_tables (restaurant_id, id);
+
+DROP INDEX booking_tables_window;
+DROP INDEX booking_tables_by_end;
+DROP INDEX bookings_by_time;
+DROP INDEX bookings_by_phone;
+DROP INDEX bookings_by_email;
+DROP INDEX waitlist_by_status;
+CREATE INDEX booking_tables_window ON booking_tables (restaurant_id, table_id, booking_time, end_time);
+CREATE INDEX booking_tables_by_end ON booking_tables (restaurant_id, end_time, booking_time);
+CREATE INDEX bookings_by_time ON bookings (restaurant_id, booking_time, id);
+CREATE INDEX bookings_by_phone ON bookings (restaurant_id, customer_phone);
+CREATE INDEX bookings_by_email ON bookings (restaurant_id, customer_email);
+CREATE INDEX waitlist_by_status ON waitlist (restaurant_id, status, created_at, id);
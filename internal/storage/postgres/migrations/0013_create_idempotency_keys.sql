@@ -0,0 +1,14 @@
+-- Responses stored under the Idempotency-Key of a request so repeats of it
+-- are replayed. A status_code of 0 means the first request is still running.
+CREATE TABLE idempotency_keys (
+    restaurant_id   TEXT NOT NULL,
+    idempotency_key TEXT NOT NULL,
+    fingerprint     TEXT NOT NULL,
+    status_code     INTEGER NOT NULL DEFAULT 0,
+    body            BYTEA,
+    expires_at      BIGINT NOT NULL,
+    PRIMARY KEY (restaurant_id, idempotency_key)
+);
+
+-- Expired keys are deleted as new ones are claimed
+CREATE INDEX idempotency_keys_by_expiry ON idempotency_keys (restaurant_id, expires_at);
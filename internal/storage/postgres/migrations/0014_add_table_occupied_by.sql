@@ -0,0 +1,3 @@
+-- The booking seated at each table, empty for walk-ins, so completing a
+-- booking only clears the tables its own party sits at
+ALTER TABLE restaurant_tables ADD COLUMN occupied_by TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+
+	"booking-dinner/internal/storage/sqlstore"
+	"booking-dinner/pkg/clock"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Open connects to the PostgreSQL database at dsn and applies any pending
+// schema migrations. Several servers may call Open on the same database; the
+// migrations are applied once.
+func Open(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres database: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+
+	scripts, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := sqlstore.Migrate(db, sqlstore.Postgres, scripts); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewRestaurantRepository creates a repository for one restaurant on a
+// database opened with Open
+func NewRestaurantRepository(db *sql.DB, restaurantID string, clock clock.Clock) *sqlstore.RestaurantRepository {
+	return sqlstore.NewRestaurantRepository(db, sqlstore.Postgres, restaurantID, clock)
+}
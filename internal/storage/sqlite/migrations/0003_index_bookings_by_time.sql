@@ -0,0 +1,2 @@
+-- Listing bookings pages through them in (booking_time, id) order
+CREATE INDEX bookings_by_time ON bookings (booking_time, id);
@@ -0,0 +1,8 @@
+ALTER TABLE bookings ADD COLUMN customer_phone TEXT NOT NULL DEFAULT '';
+ALTER TABLE bookings ADD COLUMN customer_email TEXT NOT NULL DEFAULT '';
+ALTER TABLE bookings ADD COLUMN special_requests TEXT NOT NULL DEFAULT '';
+ALTER TABLE bookings ADD COLUMN dietary_notes TEXT NOT NULL DEFAULT '';
+
+-- Hosts look bookings up by phone or email when the guest has lost the code
+CREATE INDEX bookings_by_phone ON bookings (customer_phone);
+CREATE INDEX bookings_by_email ON bookings (customer_email);
@@ -0,0 +1,2 @@
+-- A turn time a manager set on the booking, or 0 to follow the turn time rules
+ALTER TABLE bookings ADD COLUMN turn_time_override BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Retired tables stay for the bookings that used them but are never allocated
+ALTER TABLE restaurant_tables ADD COLUMN retired BOOLEAN NOT NULL DEFAULT FALSE;
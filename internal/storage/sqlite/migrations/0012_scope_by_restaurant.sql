@@ -0,0 +1,94 @@
+-- Every row belongs to a restaurant and IDs only need to be unique within
+-- one, so the keys become (restaurant_id, id). Rows written before there were
+-- several restaurants belong to the default one. SQLite cannot change a
+-- primary key in place, so the tables are rebuilt.
+CREATE TABLE restaurant_tables_new (
+    restaurant_id  TEXT NOT NULL,
+    id             TEXT NOT NULL,
+    position       INTEGER NOT NULL,
+    capacity       INTEGER NOT NULL CHECK (capacity > 0),
+    occupied       BOOLEAN NOT NULL DEFAULT FALSE,
+    occupied_since BIGINT NOT NULL DEFAULT 0,
+    party_size     INTEGER NOT NULL DEFAULT 0,
+    join_group     TEXT NOT NULL DEFAULT '',
+    adjacent       TEXT NOT NULL DEFAULT '',
+    section        TEXT NOT NULL DEFAULT '',
+    retired        BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (restaurant_id, id)
+);
+INSERT INTO restaurant_tables_new (restaurant_id, id, position, capacity, occupied, occupied_since, party_size, join_group, adjacent, section, retired)
+SELECT 'default', id, position, capacity, occupied, occupied_since, party_size, join_group, adjacent, section, retired FROM restaurant_tables;
+
+CREATE TABLE bookings_new (
+    restaurant_id      TEXT NOT NULL,
+    id                 TEXT NOT NULL,
+    customer_name      TEXT NOT NULL DEFAULT '',
+    customer_phone     TEXT NOT NULL DEFAULT '',
+    customer_email     TEXT NOT NULL DEFAULT '',
+    special_requests   TEXT NOT NULL DEFAULT '',
+    dietary_notes      TEXT NOT NULL DEFAULT '',
+    num_customers      INTEGER NOT NULL,
+    seats_assigned     INTEGER NOT NULL,
+    booking_time       INTEGER NOT NULL,
+    duration           INTEGER NOT NULL,
+    turn_time_override BIGINT NOT NULL DEFAULT 0,
+    status             TEXT NOT NULL DEFAULT 'confirmed',
+    hold_expires_at    BIGINT NOT NULL DEFAULT 0,
+    requested_section  TEXT NOT NULL DEFAULT '',
+    section_strict     BOOLEAN NOT NULL DEFAULT FALSE,
+    section            TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (restaurant_id, id)
+);
+INSERT INTO bookings_new (restaurant_id, id, customer_name, customer_phone, customer_email, special_requests, dietary_notes, num_customers, seats_assigned, booking_time, duration, turn_time_override, status, hold_expires_at, requested_section, section_strict, section)
+SELECT 'default', id, customer_name, customer_phone, customer_email, special_requests, dietary_notes, num_customers, seats_assigned, booking_time, duration, turn_time_override, status, hold_expires_at, requested_section, section_strict, section FROM bookings;
+
+CREATE TABLE booking_tables_new (
+    restaurant_id TEXT NOT NULL,
+    booking_id    TEXT NOT NULL,
+    table_id      TEXT NOT NULL,
+    position      INTEGER NOT NULL,
+    booking_time  INTEGER NOT NULL,
+    end_time      INTEGER NOT NULL,
+    PRIMARY KEY (restaurant_id, booking_id, table_id),
+    FOREIGN KEY (restaurant_id, booking_id) REFERENCES bookings_new (restaurant_id, id) ON DELETE CASCADE,
+    FOREIGN KEY (restaurant_id, table_id) REFERENCES restaurant_tables_new (restaurant_id, id)
+);
+INSERT INTO booking_tables_new (restaurant_id, booking_id, table_id, position, booking_time, end_time)
+SELECT 'default', booking_id, table_id, position, booking_time, end_time FROM booking_tables;
+
+CREATE TABLE waitlist_new (
+    restaurant_id    TEXT NOT NULL,
+    id               TEXT NOT NULL,
+    customer_name    TEXT NOT NULL DEFAULT '',
+    customer_phone   TEXT NOT NULL DEFAULT '',
+    customer_email   TEXT NOT NULL DEFAULT '',
+    special_requests TEXT NOT NULL DEFAULT '',
+    dietary_notes    TEXT NOT NULL DEFAULT '',
+    num_customers    INTEGER NOT NULL,
+    booking_time     BIGINT NOT NULL DEFAULT 0,
+    status           TEXT NOT NULL,
+    booking_id       TEXT NOT NULL DEFAULT '',
+    offer_expires_at BIGINT NOT NULL DEFAULT 0,
+    created_at       BIGINT NOT NULL,
+    PRIMARY KEY (restaurant_id, id)
+);
+INSERT INTO waitlist_new (restaurant_id, id, customer_name, customer_phone, customer_email, special_requests, dietary_notes, num_customers, booking_time, status, booking_id, offer_expires_at, created_at)
+SELECT 'default', id, customer_name, customer_phone, customer_email, special_requests, dietary_notes, num_customers, booking_time, status, booking_id, offer_expires_at, created_at FROM waitlist;
+
+-- Children go first so no foreign key is left pointing at a dropped table;
+-- renaming a table also renames the references to it
+DROP TABLE booking_tables;
+DROP TABLE bookings;
+DROP TABLE restaurant_tables;
+DROP TABLE waitlist;
+ALTER TABLE restaurant_tables_new RENAME TO restaurant_tables;
+ALTER TABLE bookings_new RENAME TO bookings;
+ALTER TABLE booking_tables_new RENAME TO booking_tables;
+ALTER TABLE waitlist_new RENAME TO waitlist;
+
+CREATE INDEX booking_tables_window ON booking_tables (restaurant_id, table_id, booking_time, end_time);
+CREATE INDEX booking_tables_by_end ON booking_tables (restaurant_id, end_time, booking_time);
+CREATE INDEX bookings_by_time ON bookings (restaurant_id, booking_time, id);
+CREATE INDEX bookings_by_phone ON bookings (restaurant_id, customer_phone);
+CREATE INDEX bookings_by_email ON bookings (restaurant_id, customer_email);
+CREATE INDEX waitlist_by_status ON waitlist (restaurant_id, status, created_at, id);
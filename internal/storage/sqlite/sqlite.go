@@ -0,0 +1,53 @@
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"booking-dinner/internal/storage/sqlstore"
+	"booking-dinner/pkg/clock"
+
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Open opens the SQLite database file at path, creating it if needed, and
+// applies any pending schema migrations
+func Open(path string) (*sql.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	dsn := fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	// SQLite allows a single writer; funnelling every statement through one
+	// connection serializes transactions instead of failing with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	scripts, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := sqlstore.Migrate(db, sqlstore.SQLite, scripts); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewRestaurantRepository creates a repository for one restaurant on a
+// database opened with Open
+func NewRestaurantRepository(db *sql.DB, restaurantID string, clock clock.Clock) *sqlstore.RestaurantRepository {
+	return sqlstore.NewRestaurantRepository(db, sqlstore.SQLite, restaurantID, clock)
+}
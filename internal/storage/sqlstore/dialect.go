@@ -0,0 +1,85 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Dialect describes how a database differs from the SQL the repository is
+// written in. Queries use ? placeholders and are rebound for the database.
+type Dialect struct {
+	// Name identifies the database in error messages
+	Name string
+	// NumberedPlaceholders rewrites ? placeholders as $1, $2, ...
+	NumberedPlaceholders bool
+	// ForUpdate is appended to SELECTs that lock the rows they read, for
+	// databases with row-level locking
+	ForUpdate string
+	// LockTable is a format string for a statement that blocks other writers
+	// to a table until the transaction ends, for databases that need it
+	LockTable string
+	// LockKey is a statement that holds a lock on a 64-bit key until the
+	// transaction ends, for databases that need it. It serializes writers
+	// that have no rows to lock, such as the first insert of a restaurant's
+	// tables.
+	LockKey string
+}
+
+// SQLite locks the whole database for writing on BEGIN IMMEDIATE, so it needs
+// no row locks
+var SQLite = Dialect{
+	Name: "sqlite",
+}
+
+// Postgres locks the rows a reservation depends on so concurrent transactions
+// on other connections or servers wait for each other
+var Postgres = Dialect{
+	Name:                 "postgres",
+	NumberedPlaceholders: true,
+	ForUpdate:            " FOR UPDATE",
+	LockTable:            "LOCK TABLE %s IN EXCLUSIVE MODE",
+	LockKey:              "SELECT pg_advisory_xact_lock(?)",
+}
+
+// rebind rewrites a query written with ? placeholders for the dialect
+func (d Dialect) rebind(query string) string {
+	if !d.NumberedPlaceholders {
+		return query
+	}
+
+	var builder strings.Builder
+	n := 0
+	for _, char := range query {
+		if char == '?' {
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		builder.WriteRune(char)
+	}
+	return builder.String()
+}
+
+// lockTable blocks other writers to table until tx ends
+func (d Dialect) lockTable(tx *sql.Tx, table string) error {
+	if d.LockTable == "" {
+		return nil
+	}
+	_, err := tx.Exec(fmt.Sprintf(d.LockTable, table))
+	return err
+}
+
+// lockKey blocks other transactions locking key until tx ends. Keys are
+// hashed, so two keys may share a lock and merely wait for each other.
+func (d Dialect) lockKey(tx *sql.Tx, key string) error {
+	if d.LockKey == "" {
+		return nil
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	_, err := tx.Exec(d.rebind(d.LockKey), int64(hash.Sum64()))
+	return err
+}
@@ -0,0 +1,79 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrate applies the *.sql files in migrations that have not been applied
+// yet, in version order. Each file is named <version>_<description>.sql and
+// runs in its own transaction.
+func Migrate(db *sql.DB, dialect Dialect, migrations fs.FS) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	files, err := fs.Glob(migrations, "*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		name := path.Base(file)
+		version, err := strconv.Atoi(strings.SplitN(name, "_", 2)[0])
+		if err != nil {
+			return fmt.Errorf("invalid migration name %s", name)
+		}
+
+		script, err := fs.ReadFile(migrations, file)
+		if err != nil {
+			return err
+		}
+
+		if err := applyMigration(db, dialect, version, string(script)); err != nil {
+			return fmt.Errorf("failed to apply %s migration %s: %w", dialect.Name, name, err)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration runs script unless version has already been applied
+func applyMigration(db *sql.DB, dialect Dialect, version int, script string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := dialect.lockTable(tx, "schema_migrations"); err != nil {
+		return err
+	}
+
+	var applied int
+	if err := tx.QueryRow(dialect.rebind(`SELECT COUNT(*) FROM schema_migrations WHERE version = ?`), version).Scan(&applied); err != nil {
+		return err
+	}
+	if applied > 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(script); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(dialect.rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`), version, time.Now().Unix()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
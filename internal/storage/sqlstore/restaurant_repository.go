@@ -0,0 +1,1053 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	apperrors "booking-dinner/internal/errors"
+	"booking-dinner/pkg/clock"
+)
+
+// RestaurantRepository stores restaurant data in a SQL database. Times are
+// stored as Unix nanoseconds so every dialect compares them the same way.
+type RestaurantRepository struct {
+	db      *sql.DB
+	dialect Dialect
+	// restaurantID scopes every row read or written, so restaurants sharing
+	// a database never see each other's tables, bookings or waitlist
+	restaurantID string
+	// clock decides when lapsed holds stop holding their tables
+	clock clock.Clock
+}
+
+// NewRestaurantRepository creates a repository for one restaurant on a
+// migrated database
+func NewRestaurantRepository(db *sql.DB, dialect Dialect, restaurantID string, clock clock.Clock) *RestaurantRepository {
+	return &RestaurantRepository{
+		db:           db,
+		dialect:      dialect,
+		restaurantID: restaurantID,
+		clock:        clock,
+	}
+}
+
+// InitializeTables stores the restaurant's table inventory
+func (r *RestaurantRepository) InitializeTables(tables []models.Table) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.lockInventory(tx); err != nil {
+		return err
+	}
+
+	var count int
+	if err := r.queryRow(tx, `SELECT COUNT(*) FROM restaurant_tables WHERE restaurant_id = ?`, r.restaurantID).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("tables have already been initialized")
+	}
+
+	for i, table := range tables {
+		if _, err := r.exec(tx,
+			`INSERT INTO restaurant_tables (restaurant_id, id, position, capacity, join_group, adjacent, section) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			r.restaurantID, table.ID, i, table.Capacity, table.JoinGroup, strings.Join(table.Adjacent, ","), table.Section,
+		); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ReserveTables assigns tables to the booking from those free during its
+// window and stores it in one transaction. Every table row is locked first,
+// so concurrent reservations, including ones from other servers, see each
+// other's bookings instead of both allocating the same free table.
+func (r *RestaurantRepository) ReserveTables(booking models.Booking, allocate func(booking models.Booking, free []models.Table) (models.Booking, error)) (models.Booking, int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	defer tx.Rollback()
+
+	if err := r.lockAllTables(tx); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	// Reservations are serialized by the table locks, so the code cannot be
+	// taken between this check and the insert
+	var existing int
+	if err := r.queryRow(tx, `SELECT COUNT(*) FROM bookings WHERE restaurant_id = ? AND id = ?`, r.restaurantID, booking.ID).Scan(&existing); err != nil {
+		return models.Booking{}, 0, err
+	}
+	if existing > 0 {
+		return models.Booking{}, 0, apperrors.ErrDuplicateBookingID
+	}
+
+	free, err := r.freeTables(tx, booking.BookingTime, booking.EndTime(), "")
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	booking, err = allocate(booking, free)
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	if err := checkTablesFree(booking, free); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	if _, err := r.exec(tx,
+		`INSERT INTO bookings (restaurant_id, id, customer_name, customer_phone, customer_email, special_requests, dietary_notes, num_customers, seats_assigned, booking_time, duration, turn_time_override, status, hold_expires_at, requested_section, section_strict, section) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.restaurantID, booking.ID, booking.CustomerName, booking.Phone, booking.Email, booking.SpecialRequests, booking.DietaryNotes,
+		booking.NumCustomers, booking.SeatsAssigned, booking.BookingTime.UnixNano(), int64(booking.Duration), int64(booking.TurnTimeOverride), booking.Status, unixNano(booking.HoldExpiresAt),
+		booking.SectionPreference.Section, booking.SectionPreference.Strict, booking.Section,
+	); err != nil {
+		return models.Booking{}, 0, err
+	}
+	if err := r.insertBookingTables(tx, booking); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Booking{}, 0, err
+	}
+	return booking, len(free) - booking.TablesBooked(), nil
+}
+
+// UpdateStatus applies update to a booking's status, duration and hold
+// expiry in one transaction
+func (r *RestaurantRepository) UpdateStatus(bookingID string, update func(booking models.Booking) (models.Booking, error)) (models.Booking, int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	defer tx.Rollback()
+
+	if err := r.lockAllTables(tx); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	current, err := r.getBooking(tx, bookingID, r.dialect.ForUpdate)
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	updated, err := update(current)
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	booking := current
+	booking.Status = updated.Status
+	booking.Duration = updated.Duration
+	booking.HoldExpiresAt = updated.HoldExpiresAt
+	if _, err := r.exec(tx,
+		`UPDATE bookings SET status = ?, duration = ?, hold_expires_at = ? WHERE restaurant_id = ? AND id = ?`,
+		booking.Status, int64(booking.Duration), unixNano(booking.HoldExpiresAt), r.restaurantID, bookingID,
+	); err != nil {
+		return models.Booking{}, 0, err
+	}
+	if _, err := r.exec(tx, `UPDATE booking_tables SET end_time = ? WHERE restaurant_id = ? AND booking_id = ?`, booking.EndTime().UnixNano(), r.restaurantID, bookingID); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	free, err := r.freeTables(tx, current.BookingTime, current.EndTime(), "")
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Booking{}, 0, err
+	}
+	return booking, len(free), nil
+}
+
+// ModifyReservation applies update to a booking and reallocates its tables
+// from those free in the updated window, counting the booking's own tables as
+// free, in one transaction. The stored booking is left as it was if update or
+// allocate fails.
+func (r *RestaurantRepository) ModifyReservation(bookingID string, update func(booking models.Booking) (models.Booking, error), allocate func(booking models.Booking, free []models.Table) (models.Booking, error)) (models.Booking, int, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	defer tx.Rollback()
+
+	if err := r.lockAllTables(tx); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	current, err := r.getBooking(tx, bookingID, r.dialect.ForUpdate)
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	booking, err := update(current)
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	booking.ID = bookingID
+
+	free, err := r.freeTables(tx, booking.BookingTime, booking.EndTime(), bookingID)
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	booking, err = allocate(booking, free)
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	if err := checkTablesFree(booking, free); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	if err := r.updateBooking(tx, booking); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Booking{}, 0, err
+	}
+	return booking, len(free) - booking.TablesBooked(), nil
+}
+
+// GetBooking returns the booking with the given ID
+func (r *RestaurantRepository) GetBooking(bookingID string) (models.Booking, error) {
+	return r.getBooking(r.db, bookingID, "")
+}
+
+// ListBookings returns up to limit bookings matching filter in booking time
+// order, starting after the cursor if one is given
+func (r *RestaurantRepository) ListBookings(filter models.BookingFilter, after *models.BookingCursor, limit int) ([]models.Booking, error) {
+	conditions := []string{`restaurant_id = ?`}
+	args := []any{r.restaurantID}
+	if after != nil {
+		conditions = append(conditions, `(booking_time > ? OR (booking_time = ? AND id > ?))`)
+		args = append(args, after.BookingTime.UnixNano(), after.BookingTime.UnixNano(), after.ID)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, `status = ?`)
+		args = append(args, filter.Status)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, `booking_time >= ?`)
+		args = append(args, filter.From.UnixNano())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, `booking_time < ?`)
+		args = append(args, filter.To.UnixNano())
+	}
+	if filter.MinCustomers > 0 {
+		conditions = append(conditions, `num_customers >= ?`)
+		args = append(args, filter.MinCustomers)
+	}
+	if filter.MaxCustomers > 0 {
+		conditions = append(conditions, `num_customers <= ?`)
+		args = append(args, filter.MaxCustomers)
+	}
+	if filter.CustomerName != "" {
+		conditions = append(conditions, `LOWER(customer_name) LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(strings.ToLower(filter.CustomerName))+"%")
+	}
+	if filter.Phone != "" {
+		conditions = append(conditions, `customer_phone = ?`)
+		args = append(args, filter.Phone)
+	}
+	if filter.Email != "" {
+		conditions = append(conditions, `customer_email = ?`)
+		args = append(args, filter.Email)
+	}
+
+	query := `SELECT ` + bookingColumns + ` FROM bookings WHERE ` + strings.Join(conditions, ` AND `) + ` ORDER BY booking_time, id LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := r.db.Query(r.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookings := []models.Booking{}
+	positions := make(map[string]int)
+	for rows.Next() {
+		booking, err := scanBooking(rows)
+		if err != nil {
+			return nil, err
+		}
+		positions[booking.ID] = len(bookings)
+		bookings = append(bookings, booking)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(bookings) == 0 {
+		return bookings, nil
+	}
+
+	// Load the tables of the whole page in one query
+	ids := []any{r.restaurantID}
+	for _, booking := range bookings {
+		ids = append(ids, booking.ID)
+	}
+	tableRows, err := r.db.Query(r.dialect.rebind(
+		`SELECT booking_id, table_id FROM booking_tables WHERE restaurant_id = ? AND booking_id IN (?`+strings.Repeat(`, ?`, len(bookings)-1)+`) ORDER BY booking_id, position`),
+		ids...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer tableRows.Close()
+
+	for tableRows.Next() {
+		var bookingID, tableID string
+		if err := tableRows.Scan(&bookingID, &tableID); err != nil {
+			return nil, err
+		}
+		i := positions[bookingID]
+		bookings[i].TableIDs = append(bookings[i].TableIDs, tableID)
+	}
+	return bookings, tableRows.Err()
+}
+
+// GetAvailableTables returns the tables free for the whole of [start, end)
+func (r *RestaurantRepository) GetAvailableTables(start, end time.Time) ([]models.Table, error) {
+	return r.freeTables(r.db, start, end, "")
+}
+
+// GetReservedWindows returns every table and the windows in which bookings
+// hold them during [start, end)
+func (r *RestaurantRepository) GetReservedWindows(start, end time.Time) ([]models.Table, []models.ReservedWindow, error) {
+	tables, err := r.GetTables()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := r.db.Query(r.dialect.rebind(`
+		SELECT b.table_id, b.booking_time, b.end_time FROM booking_tables b
+		JOIN bookings s ON s.restaurant_id = b.restaurant_id AND s.id = b.booking_id
+		WHERE b.restaurant_id = ? AND b.end_time > ? AND b.booking_time < ?
+		AND s.status IN (?, ?, ?)
+		AND NOT (s.status = ? AND s.hold_expires_at > 0 AND s.hold_expires_at <= ?)
+		ORDER BY b.booking_time, b.booking_id, b.position`),
+		r.restaurantID, start.UnixNano(), end.UnixNano(),
+		models.BookingStatusPending, models.BookingStatusConfirmed, models.BookingStatusSeated,
+		models.BookingStatusPending, r.clock.Now().UnixNano(),
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var windows []models.ReservedWindow
+	for rows.Next() {
+		var window models.ReservedWindow
+		var startNanos, endNanos int64
+		if err := rows.Scan(&window.TableID, &startNanos, &endNanos); err != nil {
+			return nil, nil, err
+		}
+		window.Start = fromUnixNano(startNanos)
+		window.End = fromUnixNano(endNanos)
+		windows = append(windows, window)
+	}
+	return tables, windows, rows.Err()
+}
+
+// GetTables returns every table with its occupancy
+func (r *RestaurantRepository) GetTables() ([]models.Table, error) {
+	rows, err := r.db.Query(r.dialect.rebind(`SELECT `+tableColumns+` FROM restaurant_tables WHERE restaurant_id = ? ORDER BY position`), r.restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []models.Table{}
+	for rows.Next() {
+		table, err := scanTable(rows)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// UpdateOccupancy applies update to a table's occupancy in one transaction
+func (r *RestaurantRepository) UpdateOccupancy(tableID string, at time.Time, update func(table models.Table, reserved bool) (models.Table, error)) (models.Table, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.Table{}, err
+	}
+	defer tx.Rollback()
+
+	if err := r.lockAllTables(tx); err != nil {
+		return models.Table{}, err
+	}
+
+	table, err := scanTable(r.queryRow(tx, `SELECT `+tableColumns+` FROM restaurant_tables WHERE restaurant_id = ? AND id = ?`, r.restaurantID, tableID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Table{}, apperrors.ErrTableNotFound
+	}
+	if err != nil {
+		return models.Table{}, err
+	}
+
+	// A booking holding the table reserves it even if the table is retired
+	var booked int
+	if err := r.queryRow(tx,
+		`SELECT COUNT(*) FROM restaurant_tables t WHERE t.restaurant_id = ? AND t.id = ? AND `+bookedCondition,
+		append([]any{r.restaurantID, tableID}, r.bookedArgs(at, at.Add(time.Nanosecond), "")...)...,
+	).Scan(&booked); err != nil {
+		return models.Table{}, err
+	}
+	reserved := booked > 0
+
+	updated, err := update(table, reserved)
+	if err != nil {
+		return models.Table{}, err
+	}
+
+	table.IsOccupied = updated.IsOccupied
+	table.OccupiedSince = updated.OccupiedSince
+	table.PartySize = updated.PartySize
+	table.OccupiedBy = updated.OccupiedBy
+	if _, err := r.exec(tx,
+		`UPDATE restaurant_tables SET occupied = ?, occupied_since = ?, party_size = ?, occupied_by = ? WHERE restaurant_id = ? AND id = ?`,
+		table.IsOccupied, unixNano(table.OccupiedSince), table.PartySize, table.OccupiedBy, r.restaurantID, tableID,
+	); err != nil {
+		return models.Table{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.Table{}, err
+	}
+	return table, nil
+}
+
+// IsInitialized checks if the tables have been initialized
+func (r *RestaurantRepository) IsInitialized() (bool, error) {
+	var count int
+	if err := r.db.QueryRow(r.dialect.rebind(`SELECT COUNT(*) FROM restaurant_tables WHERE restaurant_id = ?`), r.restaurantID).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// AddTable appends a table to the inventory
+func (r *RestaurantRepository) AddTable(table models.Table, maxTables int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.lockInventory(tx); err != nil {
+		return err
+	}
+
+	var existing, position int
+	if err := r.queryRow(tx, `SELECT COUNT(*) FROM restaurant_tables WHERE restaurant_id = ? AND id = ?`, r.restaurantID, table.ID).Scan(&existing); err != nil {
+		return err
+	}
+	if existing > 0 {
+		return apperrors.ErrDuplicateTableID
+	}
+	var inService int
+	if err := r.queryRow(tx, `SELECT COUNT(*) FROM restaurant_tables WHERE restaurant_id = ? AND NOT retired`, r.restaurantID).Scan(&inService); err != nil {
+		return err
+	}
+	if inService >= maxTables {
+		return apperrors.ErrMaxTablesExceeded
+	}
+	if err := r.queryRow(tx, `SELECT COALESCE(MAX(position), -1) + 1 FROM restaurant_tables WHERE restaurant_id = ?`, r.restaurantID).Scan(&position); err != nil {
+		return err
+	}
+
+	if _, err := r.exec(tx,
+		`INSERT INTO restaurant_tables (restaurant_id, id, position, capacity, join_group, adjacent, section) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		r.restaurantID, table.ID, position, table.Capacity, table.JoinGroup, strings.Join(table.Adjacent, ","), table.Section,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RetireTable marks a table retired and moves the bookings holding it at or
+// after now in one transaction, which is rolled back if check fails. A table
+// is only deleted once booking_tables no longer refers to it.
+func (r *RestaurantRepository) RetireTable(tableID string, now time.Time, remove bool, move func(booking models.Booking, free []models.Table) (models.Booking, error), check func(moved, stranded []models.Booking) error) (models.TableChange, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.TableChange{}, err
+	}
+	defer tx.Rollback()
+
+	if err := r.lockAllTables(tx); err != nil {
+		return models.TableChange{}, err
+	}
+
+	result, err := r.exec(tx, `UPDATE restaurant_tables SET retired = ? WHERE restaurant_id = ? AND id = ?`, true, r.restaurantID, tableID)
+	if err != nil {
+		return models.TableChange{}, err
+	}
+	if updated, err := result.RowsAffected(); err != nil {
+		return models.TableChange{}, err
+	} else if updated == 0 {
+		return models.TableChange{}, apperrors.ErrTableNotFound
+	}
+
+	rows, err := r.query(tx, `
+		SELECT s.id FROM booking_tables b
+		JOIN bookings s ON s.restaurant_id = b.restaurant_id AND s.id = b.booking_id
+		WHERE b.restaurant_id = ? AND b.table_id = ? AND b.end_time > ?
+		AND s.status IN (?, ?, ?)
+		AND NOT (s.status = ? AND s.hold_expires_at > 0 AND s.hold_expires_at <= ?)
+		ORDER BY s.booking_time, s.id`,
+		r.restaurantID, tableID, now.UnixNano(),
+		models.BookingStatusPending, models.BookingStatusConfirmed, models.BookingStatusSeated,
+		models.BookingStatusPending, now.UnixNano(),
+	)
+	if err != nil {
+		return models.TableChange{}, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return models.TableChange{}, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return models.TableChange{}, err
+	}
+
+	change := models.TableChange{TableID: tableID}
+	for _, id := range ids {
+		booking, err := r.getBooking(tx, id, "")
+		if err != nil {
+			return models.TableChange{}, err
+		}
+		free, err := r.freeTables(tx, booking.BookingTime, booking.EndTime(), booking.ID)
+		if err != nil {
+			return models.TableChange{}, err
+		}
+
+		moved, err := move(booking, free)
+		if err == nil {
+			moved.ID = booking.ID
+			err = checkTablesFree(moved, free)
+		}
+		if err != nil {
+			change.Stranded = append(change.Stranded, booking)
+			continue
+		}
+		if err := r.updateBooking(tx, moved); err != nil {
+			return models.TableChange{}, err
+		}
+		change.Moved = append(change.Moved, moved)
+	}
+
+	if err := check(change.Moved, change.Stranded); err != nil {
+		return models.TableChange{}, err
+	}
+
+	if remove {
+		var references int
+		if err := r.queryRow(tx, `SELECT COUNT(*) FROM booking_tables WHERE restaurant_id = ? AND table_id = ?`, r.restaurantID, tableID).Scan(&references); err != nil {
+			return models.TableChange{}, err
+		}
+		if references == 0 {
+			if _, err := r.exec(tx, `DELETE FROM restaurant_tables WHERE restaurant_id = ? AND id = ?`, r.restaurantID, tableID); err != nil {
+				return models.TableChange{}, err
+			}
+			change.Deleted = true
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.TableChange{}, err
+	}
+	return change, nil
+}
+
+// ReleaseExpiredHolds cancels the pending bookings whose hold expired by now
+// in one transaction
+func (r *RestaurantRepository) ReleaseExpiredHolds(now time.Time) ([]models.Booking, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := r.lockAllTables(tx); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.query(tx,
+		`SELECT id FROM bookings WHERE restaurant_id = ? AND status = ? AND hold_expires_at > 0 AND hold_expires_at <= ? ORDER BY booking_time, id`,
+		r.restaurantID, models.BookingStatusPending, now.UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	released := []models.Booking{}
+	for _, id := range ids {
+		if _, err := r.exec(tx, `UPDATE bookings SET status = ? WHERE restaurant_id = ? AND id = ?`, models.BookingStatusCancelled, r.restaurantID, id); err != nil {
+			return nil, err
+		}
+		booking, err := r.getBooking(tx, id, "")
+		if err != nil {
+			return nil, err
+		}
+		released = append(released, booking)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return released, nil
+}
+
+// AddToWaitlist stores a new waitlist entry
+func (r *RestaurantRepository) AddToWaitlist(entry models.WaitlistEntry) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var existing int
+	if err := r.queryRow(tx, `SELECT COUNT(*) FROM waitlist WHERE restaurant_id = ? AND id = ?`, r.restaurantID, entry.ID).Scan(&existing); err != nil {
+		return err
+	}
+	if existing > 0 {
+		return apperrors.ErrDuplicateWaitlistID
+	}
+
+	if _, err := r.exec(tx,
+		`INSERT INTO waitlist (restaurant_id, `+waitlistColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.restaurantID, entry.ID, entry.CustomerName, entry.Phone, entry.Email, entry.SpecialRequests, entry.DietaryNotes,
+		entry.NumCustomers, unixNano(entry.BookingTime), entry.Status, entry.BookingID, unixNano(entry.OfferExpiresAt), entry.CreatedAt.UnixNano(),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetWaitlistEntry returns the waitlist entry with the given ID
+func (r *RestaurantRepository) GetWaitlistEntry(entryID string) (models.WaitlistEntry, error) {
+	return r.getWaitlistEntry(r.db, entryID, "")
+}
+
+// ListWaitlist returns the entries with the given status in the order they
+// joined
+func (r *RestaurantRepository) ListWaitlist(status string) ([]models.WaitlistEntry, error) {
+	rows, err := r.db.Query(r.dialect.rebind(`SELECT `+waitlistColumns+` FROM waitlist WHERE restaurant_id = ? AND status = ? ORDER BY created_at, id`), r.restaurantID, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.WaitlistEntry{}
+	for rows.Next() {
+		entry, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// UpdateWaitlistEntry applies update to an entry's offer state in one
+// transaction
+func (r *RestaurantRepository) UpdateWaitlistEntry(entryID string, update func(entry models.WaitlistEntry) (models.WaitlistEntry, error)) (models.WaitlistEntry, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	defer tx.Rollback()
+
+	current, err := r.getWaitlistEntry(tx, entryID, r.dialect.ForUpdate)
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+
+	updated, err := update(current)
+	if err != nil {
+		return models.WaitlistEntry{}, err
+	}
+
+	entry := current
+	entry.Status = updated.Status
+	entry.BookingID = updated.BookingID
+	entry.OfferExpiresAt = updated.OfferExpiresAt
+	if _, err := r.exec(tx,
+		`UPDATE waitlist SET status = ?, booking_id = ?, offer_expires_at = ? WHERE restaurant_id = ? AND id = ?`,
+		entry.Status, entry.BookingID, unixNano(entry.OfferExpiresAt), r.restaurantID, entryID,
+	); err != nil {
+		return models.WaitlistEntry{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	return entry, nil
+}
+
+// getWaitlistEntry loads a waitlist entry, appending lock to the query so a
+// transaction can hold the row
+func (r *RestaurantRepository) getWaitlistEntry(q queryer, entryID string, lock string) (models.WaitlistEntry, error) {
+	entry, err := scanWaitlistEntry(q.QueryRow(r.dialect.rebind(`SELECT `+waitlistColumns+` FROM waitlist WHERE restaurant_id = ? AND id = ?`+lock), r.restaurantID, entryID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.WaitlistEntry{}, apperrors.ErrWaitlistEntryNotFound
+	}
+	return entry, err
+}
+
+// ClaimIdempotencyKey deletes the restaurant's expired idempotency records
+// and stores record unless a record with its key remains. A concurrent claim
+// of the same key makes the insert wait for it and then do nothing.
+func (r *RestaurantRepository) ClaimIdempotencyKey(record models.IdempotencyRecord, now time.Time) (models.IdempotencyRecord, bool, error) {
+	if _, err := r.db.Exec(r.dialect.rebind(
+		`DELETE FROM idempotency_keys WHERE restaurant_id = ? AND expires_at <= ?`),
+		r.restaurantID, now.UnixNano(),
+	); err != nil {
+		return models.IdempotencyRecord{}, false, err
+	}
+
+	for {
+		result, err := r.db.Exec(r.dialect.rebind(
+			`INSERT INTO idempotency_keys (restaurant_id, idempotency_key, fingerprint, expires_at) VALUES (?, ?, ?, ?) ON CONFLICT (restaurant_id, idempotency_key) DO NOTHING`),
+			r.restaurantID, record.Key, record.Fingerprint, record.ExpiresAt.UnixNano(),
+		)
+		if err != nil {
+			return models.IdempotencyRecord{}, false, err
+		}
+		if inserted, err := result.RowsAffected(); err != nil {
+			return models.IdempotencyRecord{}, false, err
+		} else if inserted > 0 {
+			return record, true, nil
+		}
+
+		stored := models.IdempotencyRecord{Key: record.Key}
+		var expiresAt int64
+		err = r.db.QueryRow(r.dialect.rebind(
+			`SELECT fingerprint, status_code, body, expires_at FROM idempotency_keys WHERE restaurant_id = ? AND idempotency_key = ?`),
+			r.restaurantID, record.Key,
+		).Scan(&stored.Fingerprint, &stored.StatusCode, &stored.Body, &expiresAt)
+		// The record was released since the insert, so claim the key again
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return models.IdempotencyRecord{}, false, err
+		}
+		stored.ExpiresAt = time.Unix(0, expiresAt)
+		return stored, false, nil
+	}
+}
+
+// SaveIdempotentResponse stores the response on the record under key
+func (r *RestaurantRepository) SaveIdempotentResponse(key string, statusCode int, body []byte) error {
+	_, err := r.db.Exec(r.dialect.rebind(
+		`UPDATE idempotency_keys SET status_code = ?, body = ? WHERE restaurant_id = ? AND idempotency_key = ?`),
+		statusCode, body, r.restaurantID, key,
+	)
+	return err
+}
+
+// ReleaseIdempotencyKey deletes the record under key
+func (r *RestaurantRepository) ReleaseIdempotencyKey(key string) error {
+	_, err := r.db.Exec(r.dialect.rebind(
+		`DELETE FROM idempotency_keys WHERE restaurant_id = ? AND idempotency_key = ?`),
+		r.restaurantID, key,
+	)
+	return err
+}
+
+// bookedCondition holds for a restaurant table t that a booking holds during
+// a window, taking the arguments bookedArgs returns. Holds that lapsed before
+// they were released no longer count.
+const bookedCondition = `EXISTS (
+			SELECT 1 FROM booking_tables b
+			JOIN bookings s ON s.restaurant_id = b.restaurant_id AND s.id = b.booking_id
+			WHERE b.restaurant_id = t.restaurant_id AND b.table_id = t.id AND b.booking_time < ? AND b.end_time > ? AND b.booking_id <> ?
+			AND s.status IN (?, ?, ?)
+			AND NOT (s.status = ? AND s.hold_expires_at > 0 AND s.hold_expires_at <= ?)
+		)`
+
+// bookedArgs returns the arguments of bookedCondition for bookings other
+// than ignoreID during [start, end)
+func (r *RestaurantRepository) bookedArgs(start, end time.Time, ignoreID string) []any {
+	return []any{
+		end.UnixNano(), start.UnixNano(), ignoreID,
+		models.BookingStatusPending, models.BookingStatusConfirmed, models.BookingStatusSeated,
+		models.BookingStatusPending, r.clock.Now().UnixNano(),
+	}
+}
+
+// lockInventory serializes the transactions that add tables to the
+// restaurant with each other and with those locking its table rows. Only
+// this restaurant's writers wait; other restaurants sharing the database do
+// not.
+func (r *RestaurantRepository) lockInventory(tx *sql.Tx) error {
+	if err := r.dialect.lockKey(tx, "restaurant_tables/"+r.restaurantID); err != nil {
+		return err
+	}
+	return r.lockAllTables(tx)
+}
+
+// lockAllTables locks every table row of the restaurant until tx ends,
+// serializing the transactions that change which of its tables are free
+func (r *RestaurantRepository) lockAllTables(tx *sql.Tx) error {
+	rows, err := r.query(tx, `SELECT id FROM restaurant_tables WHERE restaurant_id = ? ORDER BY id`+r.dialect.ForUpdate, r.restaurantID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// updateBooking saves every field of a stored booking and replaces its table
+// assignments
+func (r *RestaurantRepository) updateBooking(tx *sql.Tx, booking models.Booking) error {
+	if _, err := r.exec(tx,
+		`UPDATE bookings SET customer_name = ?, customer_phone = ?, customer_email = ?, special_requests = ?, dietary_notes = ?, num_customers = ?, seats_assigned = ?, booking_time = ?, duration = ?, turn_time_override = ?, status = ?, hold_expires_at = ?, requested_section = ?, section_strict = ?, section = ? WHERE restaurant_id = ? AND id = ?`,
+		booking.CustomerName, booking.Phone, booking.Email, booking.SpecialRequests, booking.DietaryNotes,
+		booking.NumCustomers, booking.SeatsAssigned, booking.BookingTime.UnixNano(), int64(booking.Duration), int64(booking.TurnTimeOverride), booking.Status, unixNano(booking.HoldExpiresAt),
+		booking.SectionPreference.Section, booking.SectionPreference.Strict, booking.Section, r.restaurantID, booking.ID,
+	); err != nil {
+		return err
+	}
+	if _, err := r.exec(tx, `DELETE FROM booking_tables WHERE restaurant_id = ? AND booking_id = ?`, r.restaurantID, booking.ID); err != nil {
+		return err
+	}
+	return r.insertBookingTables(tx, booking)
+}
+
+// insertBookingTables stores the booking's table assignments with its window
+func (r *RestaurantRepository) insertBookingTables(tx *sql.Tx, booking models.Booking) error {
+	start, end := booking.BookingTime.UnixNano(), booking.EndTime().UnixNano()
+	for i, tableID := range booking.TableIDs {
+		if _, err := r.exec(tx,
+			`INSERT INTO booking_tables (restaurant_id, booking_id, table_id, position, booking_time, end_time) VALUES (?, ?, ?, ?, ?, ?)`,
+			r.restaurantID, booking.ID, tableID, i, start, end,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// freeTables returns the tables in service, in inventory order, that no
+// booking other than ignoreID holds during [start, end) and nobody is sitting
+// at if the window covers now
+func (r *RestaurantRepository) freeTables(q queryer, start, end time.Time, ignoreID string) ([]models.Table, error) {
+	unbooked, err := r.unbookedTables(q, start, end, ignoreID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := r.clock.Now()
+	free := unbooked[:0]
+	for _, table := range unbooked {
+		if !table.OccupiedDuring(start, end, now) {
+			free = append(free, table)
+		}
+	}
+	return free, nil
+}
+
+// unbookedTables returns the tables in service, in inventory order, that no
+// booking other than ignoreID holds during [start, end)
+func (r *RestaurantRepository) unbookedTables(q queryer, start, end time.Time, ignoreID string) ([]models.Table, error) {
+	rows, err := q.Query(r.dialect.rebind(`
+		SELECT `+tableColumns+` FROM restaurant_tables t
+		WHERE t.restaurant_id = ? AND NOT t.retired AND NOT `+bookedCondition+`
+		ORDER BY t.position`),
+		append([]any{r.restaurantID}, r.bookedArgs(start, end, ignoreID)...)...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []models.Table{}
+	for rows.Next() {
+		table, err := scanTable(rows)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// getBooking loads a booking and its tables, appending lock to the booking
+// query so a transaction can hold the row
+func (r *RestaurantRepository) getBooking(q queryer, bookingID string, lock string) (models.Booking, error) {
+	booking, err := scanBooking(q.QueryRow(r.dialect.rebind(
+		`SELECT `+bookingColumns+` FROM bookings WHERE restaurant_id = ? AND id = ?`+lock),
+		r.restaurantID, bookingID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Booking{}, apperrors.ErrInvalidBookingID
+	}
+	if err != nil {
+		return models.Booking{}, err
+	}
+
+	rows, err := q.Query(r.dialect.rebind(`SELECT table_id FROM booking_tables WHERE restaurant_id = ? AND booking_id = ? ORDER BY position`), r.restaurantID, bookingID)
+	if err != nil {
+		return models.Booking{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tableID string
+		if err := rows.Scan(&tableID); err != nil {
+			return models.Booking{}, err
+		}
+		booking.TableIDs = append(booking.TableIDs, tableID)
+	}
+	return booking, rows.Err()
+}
+
+// checkTablesFree returns an error if the booking was assigned a table that
+// is not in free
+func checkTablesFree(booking models.Booking, free []models.Table) error {
+	isFree := make(map[string]bool, len(free))
+	for _, table := range free {
+		isFree[table.ID] = true
+	}
+	for _, tableID := range booking.TableIDs {
+		if !isFree[tableID] {
+			return fmt.Errorf("table %s is not available", tableID)
+		}
+	}
+	return nil
+}
+
+// bookingColumns are the bookings columns scanBooking reads, in order
+const bookingColumns = `id, customer_name, customer_phone, customer_email, special_requests, dietary_notes, num_customers, seats_assigned, booking_time, duration, turn_time_override, status, hold_expires_at, requested_section, section_strict, section`
+
+// scanBooking reads a row of bookingColumns into a booking
+func scanBooking(row interface{ Scan(dest ...any) error }) (models.Booking, error) {
+	var booking models.Booking
+	var bookingTime, duration, turnTimeOverride, holdExpiresAt int64
+	if err := row.Scan(
+		&booking.ID, &booking.CustomerName, &booking.Phone, &booking.Email, &booking.SpecialRequests, &booking.DietaryNotes,
+		&booking.NumCustomers, &booking.SeatsAssigned, &bookingTime, &duration, &turnTimeOverride, &booking.Status, &holdExpiresAt,
+		&booking.SectionPreference.Section, &booking.SectionPreference.Strict, &booking.Section,
+	); err != nil {
+		return models.Booking{}, err
+	}
+	booking.BookingTime = time.Unix(0, bookingTime)
+	booking.Duration = time.Duration(duration)
+	booking.TurnTimeOverride = time.Duration(turnTimeOverride)
+	booking.HoldExpiresAt = fromUnixNano(holdExpiresAt)
+	return booking, nil
+}
+
+// tableColumns are the restaurant_tables columns scanTable reads, in order
+const tableColumns = `id, capacity, occupied, occupied_since, party_size, occupied_by, join_group, adjacent, section, retired`
+
+// scanTable reads a row of tableColumns into a table
+func scanTable(row interface{ Scan(dest ...any) error }) (models.Table, error) {
+	var table models.Table
+	var since int64
+	var adjacent string
+	if err := row.Scan(&table.ID, &table.Capacity, &table.IsOccupied, &since, &table.PartySize, &table.OccupiedBy, &table.JoinGroup, &adjacent, &table.Section, &table.Retired); err != nil {
+		return models.Table{}, err
+	}
+	table.OccupiedSince = fromUnixNano(since)
+	if adjacent != "" {
+		table.Adjacent = strings.Split(adjacent, ",")
+	}
+	return table, nil
+}
+
+// waitlistColumns are the waitlist columns scanWaitlistEntry reads, in order
+const waitlistColumns = `id, customer_name, customer_phone, customer_email, special_requests, dietary_notes, num_customers, booking_time, status, booking_id, offer_expires_at, created_at`
+
+// scanWaitlistEntry reads a row of waitlistColumns into a waitlist entry
+func scanWaitlistEntry(row interface{ Scan(dest ...any) error }) (models.WaitlistEntry, error) {
+	var entry models.WaitlistEntry
+	var bookingTime, offerExpiresAt, createdAt int64
+	if err := row.Scan(
+		&entry.ID, &entry.CustomerName, &entry.Phone, &entry.Email, &entry.SpecialRequests, &entry.DietaryNotes,
+		&entry.NumCustomers, &bookingTime, &entry.Status, &entry.BookingID, &offerExpiresAt, &createdAt,
+	); err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	entry.BookingTime = fromUnixNano(bookingTime)
+	entry.OfferExpiresAt = fromUnixNano(offerExpiresAt)
+	entry.CreatedAt = time.Unix(0, createdAt)
+	return entry, nil
+}
+
+// unixNano stores an optional time, with the zero time as 0
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// fromUnixNano reads an optional time stored by unixNano
+func fromUnixNano(nanos int64) time.Time {
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// likeEscaper escapes the LIKE wildcards in a user-supplied search term
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (r *RestaurantRepository) exec(tx *sql.Tx, query string, args ...any) (sql.Result, error) {
+	return tx.Exec(r.dialect.rebind(query), args...)
+}
+
+func (r *RestaurantRepository) query(tx *sql.Tx, query string, args ...any) (*sql.Rows, error) {
+	return tx.Query(r.dialect.rebind(query), args...)
+}
+
+func (r *RestaurantRepository) queryRow(tx *sql.Tx, query string, args ...any) *sql.Row {
+	return tx.QueryRow(r.dialect.rebind(query), args...)
+}
@@ -0,0 +1,17 @@
+package clock
+
+import "time"
+
+// Clock tells the current time. Code that depends on the time takes a Clock
+// so tests can control it.
+type Clock interface {
+	Now() time.Time
+}
+
+// System is the Clock backed by the system time
+type System struct{}
+
+// Now returns the current system time
+func (System) Now() time.Time {
+	return time.Now()
+}
@@ -31,11 +31,23 @@ func New(isProduction bool) (*Logger, error) {
 	}, nil
 }
 
+// NewNop creates a Logger that discards everything
+func NewNop() *Logger {
+	return &Logger{
+		zapLogger: zap.NewNop(),
+	}
+}
+
 // Info logs a message at InfoLevel
 func (l *Logger) Info(msg string, fields ...zap.Field) {
 	l.zapLogger.Info(msg, fields...)
 }
 
+// Warn logs a message at WarnLevel
+func (l *Logger) Warn(msg string, fields ...zap.Field) {
+	l.zapLogger.Warn(msg, fields...)
+}
+
 // Error logs a message at ErrorLevel
 func (l *Logger) Error(msg string, fields ...zap.Field) {
 	l.zapLogger.Error(msg, fields...)
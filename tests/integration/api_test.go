@@ -2,39 +2,79 @@ package integration
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"booking-dinner/internal/api"
 	"booking-dinner/internal/api/handlers"
 	"booking-dinner/internal/config"
+	"booking-dinner/internal/domain/models"
 	"booking-dinner/internal/domain/restaurant"
+	"booking-dinner/internal/errors"
 	"booking-dinner/internal/storage/memory"
+	"booking-dinner/pkg/clock"
+	"booking-dinner/pkg/logger"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func setupTestApp() *fiber.App {
+	app, _ := setupTestAppWithRepository(memory.NewRestaurantRepository(clock.System{}))
+	return app
+}
+
+// setupTestAppWithRepository builds the API on repo and also returns the
+// service so tests can inspect state the API does not expose
+func setupTestAppWithRepository(repo restaurant.Repository) (*fiber.App, restaurant.Service) {
 	cfg := &config.Config{
 		Restaurant: config.RestaurantConfig{
-			MaxTables:     20,
-			SeatsPerTable: 4,
+			MaxTables:           20,
+			SeatsPerTable:       4,
+			ReservationDuration: 2 * time.Hour,
+			SlotInterval:        30 * time.Minute,
 			Code: config.CodeConfig{
 				Charset: "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
 				Length:  6,
 			},
+			Waitlist: config.WaitlistConfig{
+				Enabled:   true,
+				OfferHold: 15 * time.Minute,
+			},
+			Holds: config.HoldsConfig{
+				Enabled: true,
+				TTL:     5 * time.Minute,
+			},
+			Idempotency: config.IdempotencyConfig{
+				Enabled: true,
+				TTL:     24 * time.Hour,
+			},
 		}}
-	repo := memory.NewRestaurantRepository()
-	service := restaurant.NewService(repo, cfg.Restaurant.SeatsPerTable, cfg.Restaurant.MaxTables, cfg.Restaurant.Code.Charset, cfg.Restaurant.Code.Length)
-	handler := handlers.NewRestaurantHandler(service)
+	strategy, _ := restaurant.NewAllocationStrategy(cfg.Restaurant.Allocation.Strategy, cfg.Restaurant.Allocation.LargePartySize)
+	codes, _ := restaurant.NewCodeGenerator(cfg.Restaurant.Code.Charset, cfg.Restaurant.Code.Length, cfg.Restaurant.Code.ExcludeAmbiguous, cfg.Restaurant.Code.CheckCharacter)
+	service := restaurant.NewService(repo, restaurant.Options{
+		Strategy:            strategy,
+		Codes:               codes,
+		SeatsPerTable:       cfg.Restaurant.SeatsPerTable,
+		MaxTables:           cfg.Restaurant.MaxTables,
+		ReservationDuration: cfg.Restaurant.ReservationDuration,
+		SlotInterval:        cfg.Restaurant.SlotInterval,
+		OfferHold:           cfg.Restaurant.Waitlist.OfferHold,
+		HoldTTL:             cfg.Restaurant.Holds.TTL,
+		IdempotencyTTL:      cfg.Restaurant.Idempotency.TTL,
+	}, clock.System{})
+	handler := handlers.NewRestaurantHandler(service, logger.NewNop())
 
 	app := fiber.New()
-	api.SetupRoutes(app, handler)
+	api.SetupRoutes(app, handler, handlers.NewTenantHandler(map[string]handlers.Handler{config.DefaultRestaurantID: handler}))
 
-	return app
+	return app, service
 }
 
 func TestInitializeTables(t *testing.T) {
@@ -97,6 +137,79 @@ func TestReserveTables(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 }
 
+func TestReserveTablesByTimeSlot(t *testing.T) {
+	app := setupTestApp()
+
+	initReq := httptest.NewRequest(http.MethodPost, "/api/v1/initialize", strings.NewReader(`{"tables": 2}`))
+	initReq.Header.Set("Content-Type", "application/json")
+	app.Test(initReq)
+
+	seven := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	reserve := func(at time.Time) *http.Response {
+		body := `{"customers": 8, "bookingTime": "` + at.Format(time.RFC3339) + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/reserve", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp
+	}
+
+	// A 7pm booking for every table does not block the 9pm slot
+	assert.Equal(t, http.StatusOK, reserve(seven).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, reserve(seven.Add(time.Hour)).StatusCode)
+	assert.Equal(t, http.StatusOK, reserve(seven.Add(2*time.Hour)).StatusCode)
+
+	// Bookings in the past are rejected
+	assert.Equal(t, http.StatusBadRequest, reserve(time.Now().Add(-time.Hour)).StatusCode)
+
+	// So are invalid customer details
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reserve", strings.NewReader(`{"customers": 2, "email": "nope"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestReserveMixedTables(t *testing.T) {
+	app := setupTestApp()
+
+	initReq := httptest.NewRequest(http.MethodPost, "/api/v1/initialize", strings.NewReader(`{"tables": [{"id": "A1", "capacity": 2}, {"id": "B1", "capacity": 4}, {"id": "C1", "capacity": 8}]}`))
+	initReq.Header.Set("Content-Type", "application/json")
+	initResp, err := app.Test(initReq)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, initResp.StatusCode)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/reserve", strings.NewReader(`{"customers": 5}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result map[string]interface{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	data := result["data"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"A1", "B1"}, data["tableIDs"])
+	assert.Equal(t, float64(2), data["tablesBooked"])
+	assert.Equal(t, float64(1), data["remainingTables"])
+
+	// Duplicate table IDs are rejected
+	app = setupTestApp()
+	dupReq := httptest.NewRequest(http.MethodPost, "/api/v1/initialize", strings.NewReader(`{"tables": [{"id": "A1", "capacity": 2}, {"id": "A1", "capacity": 4}]}`))
+	dupReq.Header.Set("Content-Type", "application/json")
+	dupResp, err := app.Test(dupReq)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, dupResp.StatusCode)
+
+	// Capacities must be between 1 and the most seats a table may have
+	for _, capacity := range []string{"0", "-2", "101", "200000000"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/initialize", strings.NewReader(`{"tables": [{"id": "A1", "capacity": `+capacity+`}]}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "capacity %s", capacity)
+	}
+}
+
 func TestCancelReservation(t *testing.T) {
 	app := setupTestApp()
 
@@ -138,6 +251,864 @@ func TestCancelReservation(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, invalidCancelResp.StatusCode)
 }
 
+func TestGetBooking(t *testing.T) {
+	app := setupTestApp()
+
+	initReq := httptest.NewRequest(http.MethodPost, "/api/v1/initialize", strings.NewReader(`{"tables": 10}`))
+	initReq.Header.Set("Content-Type", "application/json")
+	app.Test(initReq)
+
+	reserveReq := httptest.NewRequest(http.MethodPost, "/api/v1/reserve", strings.NewReader(`{"customers": 6, "name": "Somchai", "phone": "081-234-5678", "email": "somchai@example.com", "dietaryNotes": "Vegetarian"}`))
+	reserveReq.Header.Set("Content-Type", "application/json")
+	reserveResp, _ := app.Test(reserveReq)
+
+	var reserveResult map[string]interface{}
+	assert.NoError(t, json.NewDecoder(reserveResp.Body).Decode(&reserveResult))
+	bookingID := reserveResult["data"].(map[string]interface{})["bookingID"].(string)
+
+	// Hosts can find the booking by phone when the guest forgot the code
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/bookings?phone=0812345678", nil))
+	assert.NoError(t, err)
+	var listResult map[string]interface{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&listResult))
+	found := listResult["data"].(map[string]interface{})["bookings"].([]interface{})
+	assert.Len(t, found, 1)
+	assert.Equal(t, bookingID, found[0].(map[string]interface{})["bookingID"])
+
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/bookings/"+bookingID, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result map[string]interface{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	data := result["data"].(map[string]interface{})
+	assert.Equal(t, bookingID, data["bookingID"])
+	assert.Equal(t, float64(6), data["customers"])
+	assert.Equal(t, []interface{}{"T1", "T2"}, data["tableIDs"])
+	assert.Equal(t, "confirmed", data["status"])
+	assert.Equal(t, "Somchai", data["name"])
+	assert.Equal(t, "0812345678", data["phone"])
+	assert.Equal(t, "Vegetarian", data["dietaryNotes"])
+	assert.Contains(t, data, "bookingTime")
+
+	// Unknown and malformed codes are both not found
+	resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/bookings/ZZZZZZ", nil))
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/bookings/invalid-id", nil))
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestListBookings(t *testing.T) {
+	app := setupTestApp()
+
+	initReq := httptest.NewRequest(http.MethodPost, "/api/v1/initialize", strings.NewReader(`{"tables": 10}`))
+	initReq.Header.Set("Content-Type", "application/json")
+	app.Test(initReq)
+
+	seven := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	for i, customers := range []int{2, 6, 3} {
+		body := fmt.Sprintf(`{"customers": %d, "bookingTime": "%s"}`, customers, seven.Add(time.Duration(i)*time.Hour).Format(time.RFC3339))
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/reserve", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	list := func(query string) (int, map[string]interface{}) {
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/bookings"+query, nil))
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		data, _ := result["data"].(map[string]interface{})
+		return resp.StatusCode, data
+	}
+
+	status, data := list("?limit=2")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, data["bookings"], 2)
+	assert.Equal(t, float64(2), data["bookings"].([]interface{})[0].(map[string]interface{})["customers"])
+
+	status, data = list("?limit=2&cursor=" + data["nextCursor"].(string))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, data["bookings"], 1)
+	assert.Equal(t, "", data["nextCursor"])
+
+	status, data = list("?minCustomers=3&status=confirmed&from=" + url.QueryEscape(seven.Format(time.RFC3339)))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, data["bookings"], 2)
+
+	status, _ = list("?from=tomorrow")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = list("?limit=abc")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = list("?cursor=%25%25")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestModifyReservation(t *testing.T) {
+	app := setupTestApp()
+
+	initReq := httptest.NewRequest(http.MethodPost, "/api/v1/initialize", strings.NewReader(`{"tables": 3}`))
+	initReq.Header.Set("Content-Type", "application/json")
+	app.Test(initReq)
+
+	reserveReq := httptest.NewRequest(http.MethodPost, "/api/v1/reserve", strings.NewReader(`{"customers": 4}`))
+	reserveReq.Header.Set("Content-Type", "application/json")
+	reserveResp, _ := app.Test(reserveReq)
+
+	var reserveResult map[string]interface{}
+	assert.NoError(t, json.NewDecoder(reserveResp.Body).Decode(&reserveResult))
+	bookingID := reserveResult["data"].(map[string]interface{})["bookingID"].(string)
+
+	modify := func(id string, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodPatch, "/api/v1/bookings/"+id, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		data, _ := result["data"].(map[string]interface{})
+		return resp.StatusCode, data
+	}
+
+	// Growing from 4 to 6 keeps the code and takes a second table
+	status, data := modify(bookingID, `{"customers": 6}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, bookingID, data["bookingID"])
+	assert.Equal(t, []interface{}{"T1", "T2"}, data["tableIDs"])
+	assert.Equal(t, float64(1), data["remainingTables"])
+
+	// A change that does not fit leaves the booking as it was
+	status, _ = modify(bookingID, `{"customers": 20}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/bookings/"+bookingID, nil))
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	assert.Equal(t, float64(6), result["data"].(map[string]interface{})["customers"])
+
+	status, _ = modify("ZZZZZZ", `{"customers": 2}`)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = modify(bookingID, `{"customers": -1}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestSetTurnTime(t *testing.T) {
+	app := setupTestApp()
+
+	initReq := httptest.NewRequest(http.MethodPost, "/api/v1/initialize", strings.NewReader(`{"tables": 3}`))
+	initReq.Header.Set("Content-Type", "application/json")
+	app.Test(initReq)
+
+	reserveReq := httptest.NewRequest(http.MethodPost, "/api/v1/reserve", strings.NewReader(`{"customers": 4}`))
+	reserveReq.Header.Set("Content-Type", "application/json")
+	reserveResp, _ := app.Test(reserveReq)
+
+	var reserveResult map[string]interface{}
+	assert.NoError(t, json.NewDecoder(reserveResp.Body).Decode(&reserveResult))
+	bookingID := reserveResult["data"].(map[string]interface{})["bookingID"].(string)
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/bookings/"+bookingID, nil))
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	assert.Equal(t, float64(120), result["data"].(map[string]interface{})["turnTimeMinutes"])
+
+	setTurnTime := func(id string, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/bookings/"+id+"/turn-time", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		data, _ := result["data"].(map[string]interface{})
+		return resp.StatusCode, data
+	}
+
+	status, data := setTurnTime(bookingID, `{"minutes": 180}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, float64(180), data["turnTimeMinutes"])
+	assert.Equal(t, true, data["turnTimeOverridden"])
+
+	// Zero goes back to the configured turn time
+	status, data = setTurnTime(bookingID, `{"minutes": 0}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, float64(120), data["turnTimeMinutes"])
+	assert.Nil(t, data["turnTimeOverridden"])
+
+	status, _ = setTurnTime(bookingID, `{"minutes": -5}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = setTurnTime("ZZZZZZ", `{"minutes": 60}`)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	cancelReq := httptest.NewRequest(http.MethodPost, "/api/v1/cancel", strings.NewReader(`{"bookingID":"`+bookingID+`"}`))
+	cancelReq.Header.Set("Content-Type", "application/json")
+	cancelResp, _ := app.Test(cancelReq)
+	assert.Equal(t, http.StatusOK, cancelResp.StatusCode)
+	status, _ = setTurnTime(bookingID, `{"minutes": 60}`)
+	assert.Equal(t, http.StatusConflict, status)
+}
+
+func TestBookingLifecycle(t *testing.T) {
+	app := setupTestApp()
+
+	initReq := httptest.NewRequest(http.MethodPost, "/api/v1/initialize", strings.NewReader(`{"tables": 1}`))
+	initReq.Header.Set("Content-Type", "application/json")
+	app.Test(initReq)
+
+	reserve := func() string {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/reserve", strings.NewReader(`{"customers": 4}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := app.Test(req)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		data, _ := result["data"].(map[string]interface{})
+		id, _ := data["bookingID"].(string)
+		return id
+	}
+	post := func(path string) int {
+		resp, err := app.Test(httptest.NewRequest(http.MethodPost, path, nil))
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	bookingID := reserve()
+	require.NotEmpty(t, bookingID)
+	assert.Empty(t, reserve(), "the only table is taken")
+
+	// A confirmed booking cannot be completed before the party is seated
+	assert.Equal(t, http.StatusConflict, post("/api/v1/bookings/"+bookingID+"/complete"))
+	assert.Equal(t, http.StatusOK, post("/api/v1/bookings/"+bookingID+"/seat"))
+	assert.Equal(t, http.StatusConflict, post("/api/v1/bookings/"+bookingID+"/seat"))
+	assert.Equal(t, http.StatusConflict, post("/api/v1/bookings/"+bookingID+"/no-show"))
+	assert.Equal(t, http.StatusOK, post("/api/v1/bookings/"+bookingID+"/complete"))
+
+	// Completing frees the table and the booking stays on record
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/bookings/"+bookingID, nil))
+	var result map[string]interface{}
+	json.NewDecoder(resp.Body).Decode(&result)
+	assert.Equal(t, "completed", result["data"].(map[string]interface{})["status"])
+	assert.NotEmpty(t, reserve())
+
+	assert.Equal(t, http.StatusNotFound, post("/api/v1/bookings/ZZZZZZ/seat"))
+}
+
+func TestFloorPlan(t *testing.T) {
+	app := setupTestApp()
+
+	// A1 and A2 stand end to end; B1 is a booth that is never combined
+	initReq := httptest.NewRequest(http.MethodPost, "/api/v1/initialize", strings.NewReader(`{"tables": [
+		{"id": "A1", "capacity": 4, "adjacent": ["A2"]},
+		{"id": "A2", "capacity": 4},
+		{"id": "B1", "capacity": 6, "group": "booth"}
+	]}`))
+	initReq.Header.Set("Content-Type", "application/json")
+	initResp, err := app.Test(initReq)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, initResp.StatusCode)
+
+	reserve := func(body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/reserve", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	status, result := reserve(`{"customers": 8}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []interface{}{"A1", "A2"}, result["data"].(map[string]interface{})["tableIDs"])
+
+	// Ten seats are left in A1 and B1 later on, but they cannot be combined
+	later := time.Now().Add(48 * time.Hour).Truncate(time.Hour).Format(time.RFC3339)
+	status, _ = reserve(`{"customers": 4, "bookingTime": "` + later + `"}`)
+	assert.Equal(t, http.StatusOK, status)
+	status, result = reserve(`{"customers": 10, "bookingTime": "` + later + `"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, errors.ErrNoTableCombination.Error(), result["error"])
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/tables", nil))
+	assert.NoError(t, err)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	tables := result["data"].(map[string]interface{})["tables"].([]interface{})
+	require.Len(t, tables, 3)
+	assert.Equal(t, []interface{}{"A2"}, tables[0].(map[string]interface{})["adjacent"])
+	assert.Equal(t, "booth", tables[2].(map[string]interface{})["group"])
+
+	// Adjacent tables must exist
+	app = setupTestApp()
+	badReq := httptest.NewRequest(http.MethodPost, "/api/v1/initialize", strings.NewReader(`{"tables": [{"id": "A1", "adjacent": ["Z9"]}]}`))
+	badReq.Header.Set("Content-Type", "application/json")
+	badResp, err := app.Test(badReq)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, badResp.StatusCode)
+}
+
+func TestSections(t *testing.T) {
+	app := setupTestApp()
+
+	initReq := httptest.NewRequest(http.MethodPost, "/api/v1/initialize", strings.NewReader(`{"tables": [
+		{"id": "I1", "capacity": 4, "section": "indoor"},
+		{"id": "T1", "capacity": 2, "section": "terrace"},
+		{"id": "P1", "capacity": 8, "section": "private"}
+	]}`))
+	initReq.Header.Set("Content-Type", "application/json")
+	initResp, err := app.Test(initReq)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, initResp.StatusCode)
+
+	reserve := func(body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/reserve", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		data, _ := result["data"].(map[string]interface{})
+		return resp.StatusCode, data
+	}
+
+	later := time.Now().Add(48 * time.Hour).Truncate(time.Hour).Format(time.RFC3339)
+	status, data := reserve(`{"customers": 2, "bookingTime": "` + later + `", "section": "terrace", "sectionStrict": true}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "terrace", data["section"])
+	assert.Equal(t, []interface{}{"T1"}, data["tableIDs"])
+
+	// The terrace is taken: a strict request fails, a best-effort one is
+	// seated elsewhere
+	status, _ = reserve(`{"customers": 2, "bookingTime": "` + later + `", "section": "terrace", "sectionStrict": true}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, data = reserve(`{"customers": 2, "bookingTime": "` + later + `", "section": "terrace"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "indoor", data["section"])
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/bookings/"+data["bookingID"].(string), nil))
+	assert.NoError(t, err)
+	var result map[string]interface{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	booking := result["data"].(map[string]interface{})
+	assert.Equal(t, "indoor", booking["section"])
+	assert.Equal(t, "terrace", booking["requestedSection"])
+
+	// Availability can be narrowed to one section
+	date := time.Now().Add(72 * time.Hour).Format("2006-01-02")
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/availability?date="+date+"&party=6&section=private", nil))
+	assert.NoError(t, err)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	slots := result["data"].(map[string]interface{})["slots"].([]interface{})
+	require.NotEmpty(t, slots)
+	assert.Equal(t, float64(8), slots[0].(map[string]interface{})["availableSeats"])
+	resp, err = app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/availability?date="+date+"&party=6&section=terrace", nil))
+	assert.NoError(t, err)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	assert.Empty(t, result["data"].(map[string]interface{})["slots"])
+}
+
+func TestWalkInsAndFloorStatus(t *testing.T) {
+	app := setupTestApp()
+
+	initReq := httptest.NewRequest(http.MethodPost, "/api/v1/initialize", strings.NewReader(`{"tables": [{"id": "A1", "capacity": 2}, {"id": "B1", "capacity": 4}]}`))
+	initReq.Header.Set("Content-Type", "application/json")
+	app.Test(initReq)
+
+	// A booking starting now holds A1
+	reserveReq := httptest.NewRequest(http.MethodPost, "/api/v1/reserve", strings.NewReader(`{"customers": 2}`))
+	reserveReq.Header.Set("Content-Type", "application/json")
+	reserveResp, _ := app.Test(reserveReq)
+	assert.Equal(t, http.StatusOK, reserveResp.StatusCode)
+
+	post := func(path string, body string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusConflict, post("/api/v1/tables/A1/seat", `{"customers": 2}`))
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/tables/B1/seat", `{"customers": 6}`))
+	assert.Equal(t, http.StatusOK, post("/api/v1/tables/B1/seat", `{"customers": 3}`))
+	assert.Equal(t, http.StatusConflict, post("/api/v1/tables/B1/seat", `{"customers": 3}`))
+	assert.Equal(t, http.StatusNotFound, post("/api/v1/tables/Z9/seat", `{"customers": 3}`))
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v1/tables", nil))
+	assert.NoError(t, err)
+	var result map[string]interface{}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	tables := result["data"].(map[string]interface{})["tables"].([]interface{})
+	require.Len(t, tables, 2)
+	assert.Equal(t, "reserved", tables[0].(map[string]interface{})["state"])
+	assert.Contains(t, tables[0], "currentBooking")
+	assert.Equal(t, "occupied", tables[1].(map[string]interface{})["state"])
+	assert.Equal(t, float64(3), tables[1].(map[string]interface{})["partySize"])
+
+	// The walk-in keeps a reservation for now off B1 until the table is
+	// cleared
+	assert.Equal(t, http.StatusBadRequest, post("/api/v1/reserve", `{"customers": 2}`))
+
+	assert.Equal(t, http.StatusOK, post("/api/v1/tables/B1/clear", ``))
+	assert.Equal(t, http.StatusConflict, post("/api/v1/tables/B1/clear", ``))
+	assert.Equal(t, http.StatusOK, post("/api/v1/reserve", `{"customers": 2}`))
+}
+
+func TestTableInventory(t *testing.T) {
+	app := setupTestApp()
+
+	send := func(method string, path string, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	status, _ := send(http.MethodPost, "/api/v1/tables", `{"id": "C1"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	status, _ = send(http.MethodPost, "/api/v1/initialize", `{"tables": [{"id": "A1", "capacity": 2}, {"id": "B1", "capacity": 4}]}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	// A table added later takes bookings straight away
+	status, result := send(http.MethodPost, "/api/v1/tables", `{"id": "C1", "capacity": 4, "section": "terrace"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "C1", result["data"].(map[string]interface{})["tableID"])
+	status, _ = send(http.MethodPost, "/api/v1/tables", `{"id": "C1"}`)
+	assert.Equal(t, http.StatusConflict, status)
+	status, _ = send(http.MethodPost, "/api/v1/tables", `{"id": "C2", "capacity": 0}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = send(http.MethodPost, "/api/v1/tables", `{"id": "C2", "capacity": 200000000}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	later := time.Now().Add(48 * time.Hour).Truncate(time.Hour).Format(time.RFC3339)
+	status, result = send(http.MethodPost, "/api/v1/reserve", `{"customers": 4, "section": "terrace", "bookingTime": "`+later+`"}`)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []interface{}{"C1"}, result["data"].(map[string]interface{})["tableIDs"])
+	movedID := result["data"].(map[string]interface{})["bookingID"].(string)
+
+	// The later booking on C1 can move indoors, but the party sitting at A1
+	// cannot
+	status, result = send(http.MethodPost, "/api/v1/reserve", `{"customers": 2}`)
+	require.Equal(t, http.StatusOK, status)
+	seatedID := result["data"].(map[string]interface{})["bookingID"].(string)
+	status, _ = send(http.MethodPost, "/api/v1/bookings/"+seatedID+"/seat", ``)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, result = send(http.MethodPost, "/api/v1/tables/C1/retire", ``)
+	assert.Equal(t, http.StatusOK, status)
+	moved := result["data"].(map[string]interface{})["movedBookings"].([]interface{})
+	require.Len(t, moved, 1)
+	assert.Equal(t, movedID, moved[0].(map[string]interface{})["bookingID"])
+	assert.Equal(t, []interface{}{"A1", "B1"}, moved[0].(map[string]interface{})["tableIDs"])
+
+	status, result = send(http.MethodPost, "/api/v1/tables/A1/retire", ``)
+	assert.Equal(t, http.StatusConflict, status)
+	stranded := result["details"].(map[string]interface{})["strandedBookings"].([]interface{})
+	require.Len(t, stranded, 1)
+	assert.Equal(t, seatedID, stranded[0].(map[string]interface{})["bookingID"])
+
+	status, result = send(http.MethodPost, "/api/v1/tables/A1/retire?force=true", ``)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Len(t, result["data"].(map[string]interface{})["strandedBookings"], 1)
+
+	status, result = send(http.MethodGet, "/api/v1/tables", ``)
+	assert.Equal(t, http.StatusOK, status)
+	tables := result["data"].(map[string]interface{})["tables"].([]interface{})
+	require.Len(t, tables, 3)
+	assert.Equal(t, "occupied", tables[0].(map[string]interface{})["state"])
+	assert.Equal(t, float64(2), tables[0].(map[string]interface{})["partySize"])
+	assert.Equal(t, "retired", tables[2].(map[string]interface{})["state"])
+	status, _ = send(http.MethodPost, "/api/v1/tables/C1/seat", `{"customers": 2}`)
+	assert.Equal(t, http.StatusConflict, status)
+
+	// Removing keeps a table that bookings refer to and deletes one they
+	// no longer do
+	status, _ = send(http.MethodDelete, "/api/v1/tables/A1", ``)
+	assert.Equal(t, http.StatusConflict, status)
+	status, result = send(http.MethodDelete, "/api/v1/tables/A1?force=true", ``)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, false, result["data"].(map[string]interface{})["deleted"])
+	status, result = send(http.MethodDelete, "/api/v1/tables/C1", ``)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, true, result["data"].(map[string]interface{})["deleted"])
+
+	status, result = send(http.MethodPost, "/api/v1/tables", `{}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "T1", result["data"].(map[string]interface{})["tableID"])
+
+	status, _ = send(http.MethodDelete, "/api/v1/tables/Z9", ``)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestRestaurants(t *testing.T) {
+	// The riverside outlet has fewer, larger tables and longer codes
+	codes, _ := restaurant.NewCodeGenerator("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 8, false, false)
+	riverside := handlers.NewRestaurantHandler(restaurant.NewService(memory.NewRestaurantRepository(clock.System{}), restaurant.Options{Strategy: restaurant.FirstFit{}, Codes: codes, SeatsPerTable: 6, MaxTables: 3, ReservationDuration: 2 * time.Hour}, clock.System{}), logger.NewNop())
+	codes, _ = restaurant.NewCodeGenerator("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 6, false, false)
+	siam := handlers.NewRestaurantHandler(restaurant.NewService(memory.NewRestaurantRepository(clock.System{}), restaurant.Options{Strategy: restaurant.FirstFit{}, Codes: codes, SeatsPerTable: 4, MaxTables: 20, ReservationDuration: 2 * time.Hour}, clock.System{}), logger.NewNop())
+	app := fiber.New()
+	api.SetupRoutes(app, siam, handlers.NewTenantHandler(map[string]handlers.Handler{"siam": siam, "riverside": riverside}))
+
+	send := func(method string, path string, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		assert.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp.StatusCode, result
+	}
+
+	// Each restaurant has its own table limit and seats per table
+	status, _ := send(http.MethodPost, "/api/v1/restaurants/riverside/initialize", `{"tables": 5}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = send(http.MethodPost, "/api/v1/restaurants/riverside/initialize", `{"tables": 3}`)
+	assert.Equal(t, http.StatusOK, status)
+	status, _ = send(http.MethodPost, "/api/v1/restaurants/riverside/initialize", `{"tables": 3}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = send(http.MethodPost, "/api/v1/initialize", `{"tables": 5}`)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, result := send(http.MethodGet, "/api/v1/restaurants/riverside/tables", ``)
+	assert.Equal(t, http.StatusOK, status)
+	tables := result["data"].(map[string]interface{})["tables"].([]interface{})
+	require.Len(t, tables, 3)
+	assert.Equal(t, float64(6), tables[0].(map[string]interface{})["capacity"])
+
+	// The routes without a restaurant serve the default one
+	status, result = send(http.MethodGet, "/api/v1/restaurants/siam/tables", ``)
+	assert.Equal(t, http.StatusOK, status)
+	tables = result["data"].(map[string]interface{})["tables"].([]interface{})
+	require.Len(t, tables, 5)
+	assert.Equal(t, float64(4), tables[0].(map[string]interface{})["capacity"])
+
+	// A booking is only known to its own restaurant
+	status, result = send(http.MethodPost, "/api/v1/restaurants/riverside/reserve", `{"customers": 6}`)
+	require.Equal(t, http.StatusOK, status)
+	booking := result["data"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"T1"}, booking["tableIDs"])
+	bookingID := booking["bookingID"].(string)
+	assert.Len(t, bookingID, 8)
+
+	status, _ = send(http.MethodGet, "/api/v1/bookings/"+bookingID, ``)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = send(http.MethodPost, "/api/v1/restaurants/siam/cancel", `{"bookingID": "`+bookingID+`"}`)
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = send(http.MethodGet, "/api/v1/restaurants/riverside/bookings/"+bookingID, ``)
+	assert.Equal(t, http.StatusOK, status)
+	status, result = send(http.MethodGet, "/api/v1/restaurants/siam/bookings", ``)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Empty(t, result["data"].(map[string]interface{})["bookings"])
+
+	status, result = send(http.MethodGet, "/api/v1/restaurants/nowhere/tables", ``)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, "Restaurant not found", result["message"])
+}
+
+func TestWaitlist(t *testing.T) {
+	app := setupTestApp()
+
+	send := func(method, path, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var result map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		data, _ := result["data"].(map[string]interface{})
+		return resp.StatusCode, data
+	}
+
+	send(http.MethodPost, "/api/v1/initialize", `{"tables": 1}`)
+	status, booking := send(http.MethodPost, "/api/v1/reserve", `{"customers": 4}`)
+	require.Equal(t, http.StatusOK, status)
+	status, _ = send(http.MethodPost, "/api/v1/reserve", `{"customers": 2}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	// With every table taken the party waits
+	status, _ = send(http.MethodPost, "/api/v1/waitlist", `{"customers": 2, "name": "Anna"}`)
+	assert.Equal(t, http.StatusBadRequest, status, "contact details are required")
+	status, entry := send(http.MethodPost, "/api/v1/waitlist", `{"customers": 2, "name": "Anna", "phone": "0812345678"}`)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "waiting", entry["status"])
+	entryID := entry["entryID"].(string)
+
+	status, _ = send(http.MethodPost, "/api/v1/waitlist/"+entryID+"/confirm", ``)
+	assert.Equal(t, http.StatusConflict, status, "nothing has been offered yet")
+
+	// Cancelling frees the table, which is held for the waiting party
+	status, _ = send(http.MethodPost, "/api/v1/cancel", `{"bookingID": "`+booking["bookingID"].(string)+`"}`)
+	require.Equal(t, http.StatusOK, status)
+
+	status, entry = send(http.MethodGet, "/api/v1/waitlist/"+entryID, ``)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "offered", entry["status"])
+	assert.Contains(t, entry, "offerExpiresAt")
+	heldID := entry["bookingID"].(string)
+
+	status, held := send(http.MethodGet, "/api/v1/bookings/"+heldID, ``)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "pending", held["status"])
+	assert.Equal(t, "Anna", held["name"])
+	status, _ = send(http.MethodPost, "/api/v1/reserve", `{"customers": 2}`)
+	assert.Equal(t, http.StatusBadRequest, status, "the held table is not available to others")
+
+	status, confirmed := send(http.MethodPost, "/api/v1/waitlist/"+entryID+"/confirm", ``)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, heldID, confirmed["bookingID"])
+	assert.Equal(t, "confirmed", confirmed["status"])
+	assert.NotContains(t, confirmed, "holdExpiresAt")
+
+	status, entry = send(http.MethodGet, "/api/v1/waitlist/"+entryID, ``)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "booked", entry["status"])
+
+	status, _ = send(http.MethodGet, "/api/v1/waitlist/ZZZZZZ", ``)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestHolds(t *testing.T) {
+	app := setupTestApp()
+
+	send := func(method, path, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var result map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		data, _ := result["data"].(map[string]interface{})
+		return resp.StatusCode, data
+	}
+
+	send(http.MethodPost, "/api/v1/initialize", `{"tables": 1}`)
+	status, held := send(http.MethodPost, "/api/v1/holds", `{"customers": 4}`)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "pending", held["status"])
+	assert.Contains(t, held, "holdExpiresAt")
+	bookingID := held["bookingID"].(string)
+
+	status, _ = send(http.MethodPost, "/api/v1/reserve", `{"customers": 2}`)
+	assert.Equal(t, http.StatusBadRequest, status, "the held table is not available to others")
+
+	status, _ = send(http.MethodPost, "/api/v1/holds/"+bookingID+"/confirm", `{"name": "Anna", "phone": "12"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, confirmed := send(http.MethodPost, "/api/v1/holds/"+bookingID+"/confirm", `{"name": "Anna", "phone": "0812345678"}`)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "confirmed", confirmed["status"])
+	assert.Equal(t, "Anna", confirmed["name"])
+	assert.NotContains(t, confirmed, "holdExpiresAt")
+
+	status, _ = send(http.MethodPost, "/api/v1/holds/"+bookingID+"/confirm", `{}`)
+	assert.Equal(t, http.StatusConflict, status, "a booking is confirmed only once")
+	status, _ = send(http.MethodPost, "/api/v1/holds/ZZZZZZ/confirm", `{}`)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	app := setupTestApp()
+
+	send := func(method string, path string, key string, body string) (*http.Response, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(handlers.IdempotencyKeyHeader, key)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var result map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return resp, result
+	}
+
+	resp, _ := send(http.MethodPost, "/api/v1/initialize", "", `{"tables": 3}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// A retried reservation gets the first response instead of a second
+	// booking
+	resp, first := send(http.MethodPost, "/api/v1/reserve", "reserve-1", `{"customers": 4, "name": "Somchai"}`)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(handlers.IdempotentReplayedHeader))
+	bookingID := first["data"].(map[string]interface{})["bookingID"].(string)
+
+	resp, retried := send(http.MethodPost, "/api/v1/reserve", "reserve-1", `{"customers": 4, "name": "Somchai"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get(handlers.IdempotentReplayedHeader))
+	assert.Equal(t, first, retried)
+
+	// The same request with its fields reordered or spaced differently is a
+	// retry too
+	resp, retried = send(http.MethodPost, "/api/v1/reserve", "reserve-1", "{\n  \"name\":\"Somchai\",\n  \"customers\":4\n}")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get(handlers.IdempotentReplayedHeader))
+	assert.Equal(t, first, retried)
+
+	_, result := send(http.MethodGet, "/api/v1/bookings", "", ``)
+	assert.Len(t, result["data"].(map[string]interface{})["bookings"], 1)
+
+	// The key cannot be reused for a different request
+	resp, _ = send(http.MethodPost, "/api/v1/reserve", "reserve-1", `{"customers": 2}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	resp, _ = send(http.MethodPost, "/api/v1/cancel", "reserve-1", `{"bookingID": "`+bookingID+`"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+	// Errors are replayed too, except server errors
+	resp, _ = send(http.MethodPost, "/api/v1/reserve", "reserve-2", `{"customers": 0}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, _ = send(http.MethodPost, "/api/v1/reserve", "reserve-2", `{"customers": 0}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "true", resp.Header.Get(handlers.IdempotentReplayedHeader))
+
+	resp, _ = send(http.MethodPatch, "/api/v1/bookings/"+bookingID, "modify-1", `{"customers": 6}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, result = send(http.MethodPatch, "/api/v1/bookings/"+bookingID, "modify-1", `{"customers": 6}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, float64(6), result["data"].(map[string]interface{})["customers"])
+
+	// Cancelling twice fails, but retrying the same cancellation does not
+	resp, cancelled := send(http.MethodPost, "/api/v1/cancel", "cancel-1", `{"bookingID": "`+bookingID+`"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, retried = send(http.MethodPost, "/api/v1/cancel", "cancel-1", `{"bookingID": "`+bookingID+`"}`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, cancelled, retried)
+	resp, _ = send(http.MethodPost, "/api/v1/cancel", "", `{"bookingID": "`+bookingID+`"}`)
+	assert.NotEqual(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = send(http.MethodPost, "/api/v1/reserve", strings.Repeat("k", 256), `{"customers": 2}`)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+// panickingRepository panics in ReserveTables while panics is set
+type panickingRepository struct {
+	restaurant.Repository
+	panics bool
+}
+
+func (r *panickingRepository) ReserveTables(booking models.Booking, allocate func(booking models.Booking, free []models.Table) (models.Booking, error)) (models.Booking, int, error) {
+	if r.panics {
+		panic("reserve tables")
+	}
+	return r.Repository.ReserveTables(booking, allocate)
+}
+
+func TestIdempotencyKeyReleasedOnPanic(t *testing.T) {
+	repo := &panickingRepository{Repository: memory.NewRestaurantRepository(clock.System{})}
+	app, _ := setupTestAppWithRepository(repo)
+
+	send := func(path string, key string, body string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(handlers.IdempotencyKeyHeader, key)
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, send("/api/v1/initialize", "init-1", `{"tables": 3}`))
+
+	// The panic becomes a 500 and the retry runs instead of getting 409
+	repo.panics = true
+	assert.Equal(t, http.StatusInternalServerError, send("/api/v1/reserve", "reserve-1", `{"customers": 2}`))
+	repo.panics = false
+	assert.Equal(t, http.StatusOK, send("/api/v1/reserve", "reserve-1", `{"customers": 2}`))
+}
+
+func TestAvailability(t *testing.T) {
+	app := setupTestApp()
+
+	send := func(method, path, body string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var result map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		data, _ := result["data"].(map[string]interface{})
+		return resp.StatusCode, data
+	}
+
+	send(http.MethodPost, "/api/v1/initialize", `{"tables": 1}`)
+	seven := time.Date(2030, 1, 2, 19, 0, 0, 0, time.Local)
+	status, _ := send(http.MethodPost, "/api/v1/reserve", fmt.Sprintf(`{"customers": 4, "bookingTime": %q}`, seven.Format(time.RFC3339)))
+	require.Equal(t, http.StatusOK, status)
+
+	// Without a schedule there is a slot every half hour all day, except
+	// those whose two hours overlap the 7pm booking
+	status, data := send(http.MethodGet, "/api/v1/availability?date=2030-01-02&party=4", "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "2030-01-02", data["date"])
+	assert.Equal(t, float64(4), data["party"])
+	slots := data["slots"].([]interface{})
+	assert.Len(t, slots, 48-7)
+	for _, slot := range slots {
+		slot := slot.(map[string]interface{})
+		slotTime, err := time.Parse(time.RFC3339, slot["time"].(string))
+		require.NoError(t, err)
+		assert.False(t, slotTime.After(seven.Add(-2*time.Hour)) && slotTime.Before(seven.Add(2*time.Hour)), slotTime)
+		assert.Equal(t, float64(1), slot["availableTables"])
+		assert.Equal(t, float64(4), slot["availableSeats"])
+	}
+
+	status, data = send(http.MethodGet, "/api/v1/availability?date=2030-01-02&party=5", "")
+	require.Equal(t, http.StatusOK, status)
+	assert.Empty(t, data["slots"])
+
+	status, _ = send(http.MethodGet, "/api/v1/availability?party=4", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = send(http.MethodGet, "/api/v1/availability?date=tomorrow&party=4", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = send(http.MethodGet, "/api/v1/availability?date=2030-01-02&party=many", "")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestSchedule(t *testing.T) {
+	schedule, err := restaurant.NewSchedule("Asia/Bangkok",
+		[]restaurant.ServicePeriod{{Name: "dinner", Open: "17:30", Close: "21:30"}},
+		map[string][]string{"tuesday": {"dinner"}, "wednesday": {"dinner"}},
+		[]restaurant.Closure{{Date: "2030-01-01", Reason: "New Year's Day"}},
+		nil,
+	)
+	require.NoError(t, err)
+	codes, _ := restaurant.NewCodeGenerator("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 6, false, false)
+	service := restaurant.NewService(memory.NewRestaurantRepository(clock.System{}), restaurant.Options{Strategy: restaurant.FirstFit{}, Codes: codes, SeatsPerTable: 4, MaxTables: 20, ReservationDuration: 2 * time.Hour, Schedule: schedule}, clock.System{})
+	handler := handlers.NewRestaurantHandler(service, logger.NewNop())
+	app := fiber.New()
+	api.SetupRoutes(app, handler, handlers.NewTenantHandler(map[string]handlers.Handler{config.DefaultRestaurantID: handler}))
+
+	reserve := func(bookingTime string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/reserve", strings.NewReader(fmt.Sprintf(`{"customers": 2, "bookingTime": %q}`, bookingTime)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		var result map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return resp.StatusCode, result
+	}
+
+	require.NoError(t, service.InitializeTableCount(2))
+	status, _ := reserve("2030-01-08T19:00:00+07:00")
+	assert.Equal(t, http.StatusOK, status)
+
+	status, result := reserve("2030-01-08T15:00:00+07:00")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, map[string]interface{}{
+		"reason":  "OUTSIDE_HOURS",
+		"date":    "2030-01-08",
+		"periods": []interface{}{map[string]interface{}{"name": "dinner", "open": "17:30", "close": "21:30"}},
+	}, result["details"])
+
+	status, result = reserve("2030-01-01T19:00:00+07:00")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "CLOSURE", result["details"].(map[string]interface{})["reason"])
+	assert.Contains(t, result["error"], "New Year's Day")
+
+	status, result = reserve("2030-01-10T19:00:00+07:00")
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "CLOSED_DAY", result["details"].(map[string]interface{})["reason"])
+}
+
 func TestEdgeCases(t *testing.T) {
 	app := setupTestApp()
 
@@ -0,0 +1,189 @@
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"booking-dinner/internal/config"
+	"booking-dinner/internal/domain/restaurant"
+	"booking-dinner/internal/storage/memory"
+	"booking-dinner/internal/storage/sqlite"
+	"booking-dinner/pkg/clock"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postJSON sends body to path and decodes the response envelope
+func postJSON(app *fiber.App, path string, body string) (int, map[string]interface{}, error) {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return resp.StatusCode, nil, err
+	}
+	return resp.StatusCode, result, nil
+}
+
+// TestConcurrentReserveAndCancel fires thousands of parallel reservations and
+// cancellations for the same time window and checks that no table is ever
+// given to two live bookings and that the counts returned to clients agree
+// with the final state.
+func TestConcurrentReserveAndCancel(t *testing.T) {
+	const (
+		numTables  = 20
+		operations = 2000
+		workers    = 64
+	)
+
+	backends := map[string]func(t *testing.T) restaurant.Repository{
+		"memory": func(t *testing.T) restaurant.Repository {
+			return memory.NewRestaurantRepository(clock.System{})
+		},
+		"sqlite": func(t *testing.T) restaurant.Repository {
+			db, err := sqlite.Open(filepath.Join(t.TempDir(), "booking.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+			return sqlite.NewRestaurantRepository(db, config.DefaultRestaurantID, clock.System{})
+		},
+	}
+
+	for name, newRepository := range backends {
+		t.Run(name, func(t *testing.T) {
+			app, service := setupTestAppWithRepository(newRepository(t))
+			status, _, err := postJSON(app, "/api/v1/initialize", fmt.Sprintf(`{"tables": %d}`, numTables))
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, status)
+
+			bookingTime := time.Now().Add(24 * time.Hour).Truncate(time.Minute)
+			reserveBody := func(customers int) string {
+				return fmt.Sprintf(`{"customers": %d, "bookingTime": "%s"}`, customers, bookingTime.Format(time.RFC3339))
+			}
+
+			var (
+				mu        sync.Mutex
+				live      = map[string][]string{} // booking ID -> tables
+				cancelled = map[string]bool{}
+				pending   = make(chan string, operations)
+				failures  []string
+			)
+			fail := func(format string, args ...interface{}) {
+				mu.Lock()
+				failures = append(failures, fmt.Sprintf(format, args...))
+				mu.Unlock()
+			}
+
+			jobs := make(chan int)
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range jobs {
+						if i%3 == 2 {
+							select {
+							case bookingID := <-pending:
+								status, result, err := postJSON(app, "/api/v1/cancel", `{"bookingID": "`+bookingID+`"}`)
+								if err != nil || status != http.StatusOK {
+									fail("cancel %s: status %d err %v", bookingID, status, err)
+									continue
+								}
+								data := result["data"].(map[string]interface{})
+								if remaining := int(data["remainingTables"].(float64)); remaining < 1 || remaining > numTables {
+									fail("cancel %s: remainingTables %d out of range", bookingID, remaining)
+								}
+
+								mu.Lock()
+								if cancelled[bookingID] {
+									failures = append(failures, "booking "+bookingID+" cancelled twice")
+								}
+								cancelled[bookingID] = true
+								delete(live, bookingID)
+								mu.Unlock()
+
+								// A second cancellation is an invalid transition and frees nothing
+								if status, _, _ := postJSON(app, "/api/v1/cancel", `{"bookingID": "`+bookingID+`"}`); status != http.StatusConflict {
+									fail("second cancel of %s: status %d", bookingID, status)
+								}
+								continue
+							default:
+							}
+						}
+
+						status, result, err := postJSON(app, "/api/v1/reserve", reserveBody(1+i%8))
+						if err != nil {
+							fail("reserve: %v", err)
+							continue
+						}
+						if status == http.StatusBadRequest {
+							continue // the window is full
+						}
+						if status != http.StatusOK {
+							fail("reserve: status %d %v", status, result)
+							continue
+						}
+
+						data := result["data"].(map[string]interface{})
+						bookingID := data["bookingID"].(string)
+						remaining := int(data["remainingTables"].(float64))
+						if remaining < 0 || remaining > numTables-1 {
+							fail("reserve %s: remainingTables %d out of range", bookingID, remaining)
+						}
+
+						var tables []string
+						for _, id := range data["tableIDs"].([]interface{}) {
+							tables = append(tables, id.(string))
+						}
+
+						mu.Lock()
+						if _, exists := live[bookingID]; exists {
+							failures = append(failures, "booking code "+bookingID+" issued twice")
+						}
+						live[bookingID] = tables
+						mu.Unlock()
+						pending <- bookingID
+					}
+				}()
+			}
+
+			for i := 0; i < operations; i++ {
+				jobs <- i
+			}
+			close(jobs)
+			wg.Wait()
+
+			assert.Empty(t, failures)
+
+			// Every table belongs to at most one live booking, and the tables
+			// the repository reports free are exactly the unassigned ones
+			owner := map[string]string{}
+			for bookingID, tables := range live {
+				for _, tableID := range tables {
+					if other, taken := owner[tableID]; taken {
+						t.Errorf("table %s assigned to both %s and %s", tableID, other, bookingID)
+					}
+					owner[tableID] = bookingID
+				}
+			}
+
+			available, err := service.GetAvailableTables(bookingTime, bookingTime.Add(time.Minute))
+			assert.NoError(t, err)
+			assert.Equal(t, numTables-len(owner), available)
+			assert.NotEmpty(t, cancelled)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/domain/restaurant"
+	"booking-dinner/internal/errors"
+	"booking-dinner/internal/storage/memory"
+	"booking-dinner/tests/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reap runs the hold reaper for a single tick and waits for it to finish
+func reap(service restaurant.Service) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ticks := make(chan time.Time)
+	done := make(chan struct{})
+	go func() {
+		restaurant.RunHoldReaper(ctx, service, ticks, nil)
+		close(done)
+	}()
+
+	ticks <- time.Time{}
+	cancel()
+	<-done
+}
+
+func TestHoldReaper(t *testing.T) {
+	for name, newRepository := range repositoryFactories() {
+		t.Run(name, func(t *testing.T) {
+			clock := testutil.NewFakeClock(time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC))
+			codes, _ := restaurant.NewCodeGenerator("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 6, false, false)
+			repo := newRepository(t, clock)
+			service := restaurant.NewService(repo, restaurant.Options{Strategy: restaurant.FirstFit{}, Codes: codes, SeatsPerTable: 4, MaxTables: 20, ReservationDuration: 2 * time.Hour, HoldTTL: 5 * time.Minute}, clock)
+			require.NoError(t, service.InitializeTableCount(1))
+
+			held, remaining, err := service.HoldTables(4, time.Time{}, models.SectionPreference{})
+			require.NoError(t, err)
+			assert.Equal(t, 0, remaining)
+			assert.Equal(t, models.BookingStatusPending, held.Status)
+			assert.Equal(t, clock.Now().Add(5*time.Minute), held.HoldExpiresAt)
+
+			// The hold keeps its table from other guests until it lapses
+			_, _, err = service.ReserveTables(2, time.Time{}, models.CustomerDetails{}, models.SectionPreference{})
+			assert.Equal(t, errors.ErrInsufficientTables, err)
+
+			clock.Advance(4 * time.Minute)
+			reap(service)
+			stored, err := service.GetBooking(held.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, models.BookingStatusPending, stored.Status)
+
+			clock.Advance(time.Minute)
+			reap(service)
+			stored, err = service.GetBooking(held.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, models.BookingStatusCancelled, stored.Status)
+
+			_, err = service.ConfirmHold(held.ID, models.CustomerDetails{CustomerName: "Anna"})
+			assert.Equal(t, errors.ErrHoldExpired, err)
+			_, _, err = service.ReserveTables(2, time.Time{}, models.CustomerDetails{}, models.SectionPreference{})
+			assert.NoError(t, err, "the released table can be booked")
+		})
+	}
+}
+
+func TestHoldReaperReleasesLapsedWaitlistOffers(t *testing.T) {
+	clock := testutil.NewFakeClock(time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC))
+	codes, _ := restaurant.NewCodeGenerator("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 6, false, false)
+	service := restaurant.NewService(memory.NewRestaurantRepository(clock), restaurant.Options{Strategy: restaurant.FirstFit{}, Codes: codes, SeatsPerTable: 4, MaxTables: 20, ReservationDuration: 2 * time.Hour, OfferHold: 15 * time.Minute}, clock)
+	require.NoError(t, service.InitializeTableCount(1))
+
+	booking, _, err := service.ReserveTables(4, time.Time{}, models.CustomerDetails{}, models.SectionPreference{})
+	require.NoError(t, err)
+	first, err := service.JoinWaitlist(2, time.Time{}, models.CustomerDetails{Phone: "0811111111"})
+	require.NoError(t, err)
+	clock.Advance(time.Minute)
+	second, err := service.JoinWaitlist(2, time.Time{}, models.CustomerDetails{Phone: "0822222222"})
+	require.NoError(t, err)
+
+	_, _, err = service.CancelReservation(booking.ID)
+	require.NoError(t, err)
+	first, _ = service.GetWaitlistEntry(first.ID)
+	assert.Equal(t, models.WaitlistStatusOffered, first.Status)
+
+	// The first party lets the offer lapse, so the table goes to the next
+	clock.Advance(15 * time.Minute)
+	reap(service)
+	first, _ = service.GetWaitlistEntry(first.ID)
+	assert.Equal(t, models.WaitlistStatusExpired, first.Status)
+	second, _ = service.GetWaitlistEntry(second.ID)
+	assert.Equal(t, models.WaitlistStatusOffered, second.Status)
+	assert.Equal(t, clock.Now().Add(15*time.Minute), second.OfferExpiresAt)
+
+	_, err = service.ConfirmWaitlistOffer(first.ID)
+	assert.Equal(t, errors.ErrOfferExpired, err)
+	confirmed, err := service.ConfirmWaitlistOffer(second.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, models.BookingStatusConfirmed, confirmed.Status)
+}
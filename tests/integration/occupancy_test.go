@@ -0,0 +1,56 @@
+package integration
+
+import (
+	"testing"
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/domain/restaurant"
+	"booking-dinner/internal/errors"
+	"booking-dinner/tests/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeatedBookingOccupancy(t *testing.T) {
+	for name, newRepository := range repositoryFactories() {
+		t.Run(name, func(t *testing.T) {
+			now := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+			clock := testutil.NewFakeClock(now)
+			codes, _ := restaurant.NewCodeGenerator("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 6, false, false)
+			service := restaurant.NewService(newRepository(t, clock), restaurant.Options{Strategy: restaurant.FirstFit{}, Codes: codes, SeatsPerTable: 4, MaxTables: 20, ReservationDuration: 2 * time.Hour}, clock)
+			require.NoError(t, service.InitializeTableCount(1))
+
+			booking, _, err := service.ReserveTables(2, now, models.CustomerDetails{}, models.SectionPreference{})
+			require.NoError(t, err)
+			_, err = service.SeatBooking(booking.ID)
+			require.NoError(t, err)
+
+			floor, err := service.GetFloorStatus()
+			require.NoError(t, err)
+			assert.True(t, floor[0].IsOccupied)
+			assert.Equal(t, booking.ID, floor[0].OccupiedBy)
+
+			// The party overruns its window, so the host clears the table and
+			// seats a walk-in there
+			clock.Advance(3 * time.Hour)
+			_, err = service.ClearTable("T1")
+			require.NoError(t, err)
+			_, err = service.SeatWalkIn("T1", 3)
+			require.NoError(t, err)
+
+			// Completing the old booking leaves the walk-in where they are
+			_, _, err = service.CompleteBooking(booking.ID)
+			require.NoError(t, err)
+			floor, err = service.GetFloorStatus()
+			require.NoError(t, err)
+			assert.True(t, floor[0].IsOccupied)
+			assert.Equal(t, 3, floor[0].PartySize)
+			assert.Empty(t, floor[0].OccupiedBy)
+
+			_, _, err = service.ReserveTables(2, clock.Now(), models.CustomerDetails{}, models.SectionPreference{})
+			assert.True(t, errors.IsNoTablesError(err), "%v", err)
+		})
+	}
+}
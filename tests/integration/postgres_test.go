@@ -0,0 +1,99 @@
+package integration
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"booking-dinner/internal/config"
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/storage/postgres"
+	"booking-dinner/pkg/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postgresDSN returns the database the Postgres tests run against, e.g. the
+// one started by `docker compose up -d postgres`. The tests are skipped when
+// POSTGRES_DSN is not set.
+func postgresDSN(t *testing.T) string {
+	dsn := os.Getenv("POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_DSN is not set")
+	}
+	return dsn
+}
+
+// openPostgresSchema creates an empty schema for the test and returns a DSN
+// whose connections use it, so tests do not see each other's data
+func openPostgresSchema(t *testing.T) string {
+	dsn := postgresDSN(t)
+
+	admin, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	defer admin.Close()
+
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	_, err = admin.Exec("CREATE SCHEMA " + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		admin, err := sql.Open("pgx", dsn)
+		if err == nil {
+			admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+			admin.Close()
+		}
+	})
+
+	u, err := url.Parse(dsn)
+	require.NoError(t, err)
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func openPostgres(t *testing.T, dsn string) *sql.DB {
+	db, err := postgres.Open(dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestPostgresConcurrentReservations(t *testing.T) {
+	dsn := openPostgresSchema(t)
+
+	// Two connection pools stand in for two API replicas
+	replicas := []*sql.DB{openPostgres(t, dsn), openPostgres(t, dsn)}
+	require.NoError(t, postgres.NewRestaurantRepository(replicas[0], config.DefaultRestaurantID, clock.System{}).InitializeTables(newTables(4, 4)))
+
+	at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			repo := postgres.NewRestaurantRepository(replicas[i%len(replicas)], config.DefaultRestaurantID, clock.System{})
+			booking := models.NewBooking(fmt.Sprintf("B%05d", i), "", 4, nil, at.Add(time.Duration(i%3)*time.Minute), 2*time.Hour)
+			if reserve(repo, *booking, "T1") == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Every booking overlaps the others on T1, so exactly one may win it
+	assert.Equal(t, 1, succeeded)
+
+	free, err := postgres.NewRestaurantRepository(replicas[1], config.DefaultRestaurantID, clock.System{}).GetAvailableTables(at, at.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, newTables(4, 4)[1:], free)
+}
@@ -0,0 +1,1009 @@
+package integration
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"booking-dinner/internal/config"
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/domain/restaurant"
+	"booking-dinner/internal/errors"
+	"booking-dinner/internal/storage/memory"
+	"booking-dinner/internal/storage/postgres"
+	"booking-dinner/internal/storage/sqlite"
+	"booking-dinner/pkg/clock"
+	"booking-dinner/tests/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// repositoryFactories builds a fresh, empty repository on the given clock for
+// every backend
+func repositoryFactories() map[string]func(t *testing.T, clock clock.Clock) restaurant.Repository {
+	return map[string]func(t *testing.T, clock clock.Clock) restaurant.Repository{
+		"memory": func(t *testing.T, clock clock.Clock) restaurant.Repository {
+			return memory.NewRestaurantRepository(clock)
+		},
+		"sqlite": func(t *testing.T, clock clock.Clock) restaurant.Repository {
+			db, err := sqlite.Open(filepath.Join(t.TempDir(), "booking.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+			return sqlite.NewRestaurantRepository(db, config.DefaultRestaurantID, clock)
+		},
+		"postgres": func(t *testing.T, clock clock.Clock) restaurant.Repository {
+			return postgres.NewRestaurantRepository(openPostgres(t, openPostgresSchema(t)), config.DefaultRestaurantID, clock)
+		},
+	}
+}
+
+func newTables(capacities ...int) []models.Table {
+	tables := make([]models.Table, len(capacities))
+	for i, capacity := range capacities {
+		tables[i] = *models.NewTable(fmt.Sprintf("T%d", i+1), capacity)
+	}
+	return tables
+}
+
+// reserve stores booking on the given tables, failing if any is not free
+func reserve(repo restaurant.Repository, booking models.Booking, tableIDs ...string) error {
+	_, _, err := repo.ReserveTables(booking, func(booking models.Booking, free []models.Table) (models.Booking, error) {
+		booking.TableIDs = tableIDs
+		return booking, nil
+	})
+	return err
+}
+
+// setStatus moves a booking to status without checking the transition
+func setStatus(repo restaurant.Repository, bookingID string, status string) (models.Booking, int, error) {
+	return repo.UpdateStatus(bookingID, func(booking models.Booking) (models.Booking, error) {
+		booking.Status = status
+		return booking, nil
+	})
+}
+
+func TestRepositories(t *testing.T) {
+	for name, newRepository := range repositoryFactories() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Initialize", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+
+				initialized, err := repo.IsInitialized()
+				assert.NoError(t, err)
+				assert.False(t, initialized)
+
+				assert.NoError(t, repo.InitializeTables(newTables(2, 4)))
+				assert.Error(t, repo.InitializeTables(newTables(8)))
+
+				initialized, err = repo.IsInitialized()
+				assert.NoError(t, err)
+				assert.True(t, initialized)
+			})
+
+			t.Run("FloorPlan", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				tables := newTables(4, 4, 4)
+				tables[0].Adjacent = []string{"T2", "T3"}
+				tables[1].JoinGroup = "patio"
+				tables[2].JoinGroup = "patio"
+				require.NoError(t, repo.InitializeTables(tables))
+
+				stored, err := repo.GetTables()
+				assert.NoError(t, err)
+				assert.Equal(t, tables, stored)
+
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				free, err := repo.GetAvailableTables(at, at.Add(time.Hour))
+				assert.NoError(t, err)
+				assert.Equal(t, tables, free)
+			})
+
+			t.Run("Sections", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				tables := newTables(4, 2)
+				tables[0].Section = "indoor"
+				tables[1].Section = "terrace"
+				require.NoError(t, repo.InitializeTables(tables))
+
+				stored, err := repo.GetTables()
+				assert.NoError(t, err)
+				assert.Equal(t, tables, stored)
+
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				booking := models.NewBooking("AAAAAA", "", 2, nil, at, 2*time.Hour)
+				booking.SectionPreference = models.SectionPreference{Section: "terrace", Strict: true}
+				booking.Section = "terrace"
+				require.NoError(t, reserve(repo, *booking, "T2"))
+
+				saved, err := repo.GetBooking("AAAAAA")
+				assert.NoError(t, err)
+				assert.Equal(t, booking.SectionPreference, saved.SectionPreference)
+				assert.Equal(t, "terrace", saved.Section)
+
+				// A change of section is saved with the new tables
+				modified, _, err := repo.ModifyReservation("AAAAAA", func(booking models.Booking) (models.Booking, error) {
+					booking.SectionPreference = models.SectionPreference{}
+					return booking, nil
+				}, func(booking models.Booking, free []models.Table) (models.Booking, error) {
+					booking.TableIDs = []string{"T1"}
+					booking.Section = "indoor"
+					return booking, nil
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, "indoor", modified.Section)
+				saved, _ = repo.GetBooking("AAAAAA")
+				assert.Equal(t, models.SectionPreference{}, saved.SectionPreference)
+				assert.Equal(t, "indoor", saved.Section)
+			})
+
+			t.Run("TimeWindows", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(4, 4)))
+
+				seven := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				nine := seven.Add(2 * time.Hour)
+
+				assert.NoError(t, reserve(repo, *models.NewBooking("AAAAAA", "", 8, nil, seven, 2*time.Hour), "T1", "T2"))
+
+				// The 7pm booking holds both tables until 9pm, then they are free again
+				free, err := repo.GetAvailableTables(seven, nine)
+				assert.NoError(t, err)
+				assert.Empty(t, free)
+				free, _ = repo.GetAvailableTables(seven.Add(-time.Hour), seven.Add(time.Minute))
+				assert.Empty(t, free)
+				free, _ = repo.GetAvailableTables(nine, nine.Add(2*time.Hour))
+				assert.Equal(t, newTables(4, 4), free)
+
+				assert.Error(t, reserve(repo, *models.NewBooking("BBBBBB", "", 4, nil, seven.Add(time.Hour), 2*time.Hour), "T2"))
+				assert.Error(t, reserve(repo, *models.NewBooking("DDDDDD", "", 4, nil, nine, 2*time.Hour), "T9"))
+				assert.NoError(t, reserve(repo, *models.NewBooking("CCCCCC", "", 4, nil, nine, 2*time.Hour), "T2"))
+				free, _ = repo.GetAvailableTables(nine, nine.Add(2*time.Hour))
+				assert.Equal(t, newTables(4), free)
+			})
+
+			t.Run("Cancel", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(2, 4)))
+
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				booking := models.NewBooking("AAAAAA", "", 5, nil, at, 90*time.Minute)
+				booking.SeatsAssigned = 6
+				require.NoError(t, reserve(repo, *booking, "T2", "T1"))
+
+				cancelled, remaining, err := setStatus(repo, "AAAAAA", models.BookingStatusCancelled)
+				assert.NoError(t, err)
+				assert.Equal(t, 2, remaining)
+				assert.Equal(t, booking.ID, cancelled.ID)
+				assert.Equal(t, []string{"T2", "T1"}, cancelled.TableIDs)
+				assert.Equal(t, 6, cancelled.SeatsAssigned)
+				assert.True(t, at.Equal(cancelled.BookingTime))
+				assert.Equal(t, 90*time.Minute, cancelled.Duration)
+
+				free, _ := repo.GetAvailableTables(at, at.Add(time.Hour))
+				assert.Len(t, free, 2)
+
+				// The cancelled booking is kept with its tables as history
+				stored, err := repo.GetBooking("AAAAAA")
+				assert.NoError(t, err)
+				assert.Equal(t, models.BookingStatusCancelled, stored.Status)
+				assert.Equal(t, []string{"T2", "T1"}, stored.TableIDs)
+
+				_, _, err = setStatus(repo, "BBBBBB", models.BookingStatusCancelled)
+				assert.ErrorIs(t, err, errors.ErrInvalidBookingID)
+			})
+
+			t.Run("UpdateStatus", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(4)))
+
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				require.NoError(t, reserve(repo, *models.NewBooking("AAAAAA", "", 4, nil, at, 2*time.Hour), "T1"))
+
+				// Seated parties keep their tables
+				_, remaining, err := setStatus(repo, "AAAAAA", models.BookingStatusSeated)
+				assert.NoError(t, err)
+				assert.Equal(t, 0, remaining)
+
+				// Completing early shortens the window so the table frees up
+				completed, remaining, err := repo.UpdateStatus("AAAAAA", func(booking models.Booking) (models.Booking, error) {
+					booking.Status = models.BookingStatusCompleted
+					booking.Duration = time.Hour
+					booking.NumCustomers = 99
+					return booking, nil
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, 1, remaining)
+				assert.Equal(t, time.Hour, completed.Duration)
+				assert.Equal(t, 4, completed.NumCustomers)
+				require.NoError(t, reserve(repo, *models.NewBooking("BBBBBB", "", 4, nil, at.Add(time.Hour), 2*time.Hour), "T1"))
+
+				// An update error changes nothing
+				_, _, err = repo.UpdateStatus("BBBBBB", func(booking models.Booking) (models.Booking, error) {
+					return booking, errors.NewTransitionError("no")
+				})
+				assert.True(t, errors.IsTransitionError(err))
+				stored, _ := repo.GetBooking("BBBBBB")
+				assert.Equal(t, models.BookingStatusConfirmed, stored.Status)
+			})
+
+			t.Run("GetBooking", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(2, 4)))
+
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				booking := models.NewBooking("AAAAAA", "Somchai", 5, nil, at, 2*time.Hour)
+				booking.SeatsAssigned = 6
+				booking.Phone = "+66812345678"
+				booking.Email = "somchai@example.com"
+				booking.SpecialRequests = "Window seat"
+				booking.DietaryNotes = "No peanuts"
+				require.NoError(t, reserve(repo, *booking, "T2", "T1"))
+
+				found, err := repo.GetBooking("AAAAAA")
+				assert.NoError(t, err)
+				assert.Equal(t, booking.CustomerDetails, found.CustomerDetails)
+				assert.Equal(t, 5, found.NumCustomers)
+				assert.Equal(t, []string{"T2", "T1"}, found.TableIDs)
+				assert.True(t, at.Equal(found.BookingTime))
+				assert.Equal(t, models.BookingStatusConfirmed, found.Status)
+
+				_, err = repo.GetBooking("BBBBBB")
+				assert.ErrorIs(t, err, errors.ErrInvalidBookingID)
+			})
+
+			t.Run("ListBookings", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(4, 4, 4, 4)))
+
+				seven := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				somchai := models.NewBooking("EEEEEE", "Somchai", 2, nil, seven.Add(24*time.Hour), 2*time.Hour)
+				somchai.Phone = "0812345678"
+				somchai.Email = "somchai@example.com"
+				require.NoError(t, reserve(repo, *somchai, "T1"))
+				require.NoError(t, reserve(repo, *models.NewBooking("CCCCCC", "Anna", 4, nil, seven, 2*time.Hour), "T1"))
+				require.NoError(t, reserve(repo, *models.NewBooking("AAAAAA", "ANNABELLE", 6, nil, seven, 2*time.Hour), "T2", "T3"))
+				require.NoError(t, reserve(repo, *models.NewBooking("DDDDDD", "100%_real", 2, nil, seven.Add(time.Hour), 2*time.Hour), "T4"))
+
+				ids := func(bookings []models.Booking) []string {
+					result := []string{}
+					for _, booking := range bookings {
+						result = append(result, booking.ID)
+					}
+					return result
+				}
+
+				// Pages follow booking time, then ID, and resume after the cursor
+				page, err := repo.ListBookings(models.BookingFilter{}, nil, 2)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"AAAAAA", "CCCCCC"}, ids(page))
+				assert.Equal(t, []string{"T2", "T3"}, page[0].TableIDs)
+				page, _ = repo.ListBookings(models.BookingFilter{}, &models.BookingCursor{BookingTime: page[1].BookingTime, ID: page[1].ID}, 2)
+				assert.Equal(t, []string{"DDDDDD", "EEEEEE"}, ids(page))
+
+				// A cursor still works after its booking changes
+				_, _, err = setStatus(repo, "CCCCCC", models.BookingStatusCancelled)
+				require.NoError(t, err)
+				page, _ = repo.ListBookings(models.BookingFilter{}, &models.BookingCursor{BookingTime: seven, ID: "CCCCCC"}, 10)
+				assert.Equal(t, []string{"DDDDDD", "EEEEEE"}, ids(page))
+
+				page, _ = repo.ListBookings(models.BookingFilter{From: seven.Add(time.Hour), To: seven.Add(24 * time.Hour)}, nil, 10)
+				assert.Equal(t, []string{"DDDDDD"}, ids(page))
+				page, _ = repo.ListBookings(models.BookingFilter{MinCustomers: 2, MaxCustomers: 4}, nil, 10)
+				assert.Equal(t, []string{"CCCCCC", "DDDDDD", "EEEEEE"}, ids(page))
+				page, _ = repo.ListBookings(models.BookingFilter{CustomerName: "anna"}, nil, 10)
+				assert.Equal(t, []string{"AAAAAA", "CCCCCC"}, ids(page))
+				page, _ = repo.ListBookings(models.BookingFilter{CustomerName: "%_"}, nil, 10)
+				assert.Equal(t, []string{"DDDDDD"}, ids(page))
+				page, _ = repo.ListBookings(models.BookingFilter{Phone: "0812345678"}, nil, 10)
+				assert.Equal(t, []string{"EEEEEE"}, ids(page))
+				page, _ = repo.ListBookings(models.BookingFilter{Email: "somchai@example.com"}, nil, 10)
+				assert.Equal(t, []string{"EEEEEE"}, ids(page))
+				page, _ = repo.ListBookings(models.BookingFilter{Status: models.BookingStatusConfirmed}, nil, 10)
+				assert.Len(t, page, 3)
+				page, _ = repo.ListBookings(models.BookingFilter{Status: models.BookingStatusCancelled}, nil, 10)
+				assert.Equal(t, []string{"CCCCCC"}, ids(page))
+				page, _ = repo.ListBookings(models.BookingFilter{Status: "unknown"}, nil, 10)
+				assert.Empty(t, page)
+			})
+
+			t.Run("Modify", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(4, 4, 4)))
+
+				seven := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				require.NoError(t, reserve(repo, *models.NewBooking("AAAAAA", "", 4, nil, seven, 2*time.Hour), "T1"))
+				require.NoError(t, reserve(repo, *models.NewBooking("BBBBBB", "", 4, nil, seven, 2*time.Hour), "T2"))
+
+				grow := func(booking models.Booking) (models.Booking, error) {
+					booking.NumCustomers = 8
+					return booking, nil
+				}
+
+				// The booking's own table is offered back alongside the free ones
+				var seen []models.Table
+				modified, remaining, err := repo.ModifyReservation("AAAAAA", grow, func(booking models.Booking, free []models.Table) (models.Booking, error) {
+					seen = free
+					booking.TableIDs = []string{"T1", "T3"}
+					return booking, nil
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, []models.Table{*models.NewTable("T1", 4), *models.NewTable("T3", 4)}, seen)
+				assert.Equal(t, 0, remaining)
+				assert.Equal(t, 8, modified.NumCustomers)
+
+				stored, err := repo.GetBooking("AAAAAA")
+				assert.NoError(t, err)
+				assert.Equal(t, 8, stored.NumCustomers)
+				assert.Equal(t, []string{"T1", "T3"}, stored.TableIDs)
+
+				// A failed allocation leaves the booking untouched
+				_, _, err = repo.ModifyReservation("AAAAAA", grow, func(booking models.Booking, free []models.Table) (models.Booking, error) {
+					return booking, errors.ErrInsufficientTables
+				})
+				assert.ErrorIs(t, err, errors.ErrInsufficientTables)
+				_, _, err = repo.ModifyReservation("AAAAAA", grow, func(booking models.Booking, free []models.Table) (models.Booking, error) {
+					booking.TableIDs = []string{"T2"}
+					return booking, nil
+				})
+				assert.Error(t, err)
+				stored, _ = repo.GetBooking("AAAAAA")
+				assert.Equal(t, []string{"T1", "T3"}, stored.TableIDs)
+
+				// Moving the booking frees its old window and stays listed in order
+				move := func(booking models.Booking) (models.Booking, error) {
+					booking.BookingTime = seven.Add(-2 * time.Hour)
+					return booking, nil
+				}
+				_, _, err = repo.ModifyReservation("BBBBBB", move, func(booking models.Booking, free []models.Table) (models.Booking, error) {
+					booking.TableIDs = []string{"T2"}
+					return booking, nil
+				})
+				assert.NoError(t, err)
+				free, _ := repo.GetAvailableTables(seven, seven.Add(time.Hour))
+				assert.Equal(t, []models.Table{*models.NewTable("T2", 4)}, free)
+				page, _ := repo.ListBookings(models.BookingFilter{}, nil, 10)
+				require.Len(t, page, 2)
+				assert.Equal(t, "BBBBBB", page[0].ID)
+
+				_, _, err = repo.ModifyReservation("CCCCCC", grow, func(booking models.Booking, free []models.Table) (models.Booking, error) {
+					return booking, nil
+				})
+				assert.ErrorIs(t, err, errors.ErrInvalidBookingID)
+			})
+
+			t.Run("TurnTimeOverride", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(4, 4)))
+
+				seven := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				require.NoError(t, reserve(repo, *models.NewBooking("AAAAAA", "", 4, nil, seven, 90*time.Minute), "T1"))
+
+				keep := func(booking models.Booking, free []models.Table) (models.Booking, error) {
+					return booking, nil
+				}
+				_, _, err := repo.ModifyReservation("AAAAAA", func(booking models.Booking) (models.Booking, error) {
+					booking.TurnTimeOverride = 3 * time.Hour
+					booking.Duration = 3 * time.Hour
+					return booking, nil
+				}, keep)
+				require.NoError(t, err)
+
+				stored, err := repo.GetBooking("AAAAAA")
+				assert.NoError(t, err)
+				assert.Equal(t, 3*time.Hour, stored.TurnTimeOverride)
+				assert.Equal(t, 3*time.Hour, stored.Duration)
+
+				// The longer window keeps the table taken later in the evening
+				free, _ := repo.GetAvailableTables(seven.Add(2*time.Hour), seven.Add(150*time.Minute))
+				assert.Equal(t, []models.Table{*models.NewTable("T2", 4)}, free)
+			})
+
+			t.Run("Occupancy", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(2, 4)))
+
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				require.NoError(t, reserve(repo, *models.NewBooking("AAAAAA", "", 2, nil, at, 2*time.Hour), "T1"))
+
+				// The update sees whether a booking holds the table at that moment
+				reservedAt := map[string]bool{}
+				seat := func(table models.Table, reserved bool) (models.Table, error) {
+					reservedAt[table.ID] = reserved
+					table.IsOccupied = true
+					table.OccupiedSince = at
+					table.PartySize = 3
+					table.Capacity = 99
+					return table, nil
+				}
+				_, err := repo.UpdateOccupancy("T1", at.Add(time.Hour), seat)
+				assert.NoError(t, err)
+				seated, err := repo.UpdateOccupancy("T2", at.Add(time.Hour), seat)
+				assert.NoError(t, err)
+				assert.Equal(t, map[string]bool{"T1": true, "T2": false}, reservedAt)
+				assert.Equal(t, 4, seated.Capacity)
+
+				tables, err := repo.GetTables()
+				assert.NoError(t, err)
+				require.Len(t, tables, 2)
+				assert.True(t, tables[1].IsOccupied)
+				assert.True(t, at.Equal(tables[1].OccupiedSince))
+				assert.Equal(t, 3, tables[1].PartySize)
+				assert.Equal(t, 4, tables[1].Capacity)
+
+				// Occupancy is tracked apart from reservations
+				free, _ := repo.GetAvailableTables(at, at.Add(time.Hour))
+				assert.Equal(t, []string{"T2"}, []string{free[0].ID})
+
+				_, err = repo.UpdateOccupancy("T2", at, func(table models.Table, reserved bool) (models.Table, error) {
+					return table, errors.ErrTableOccupied
+				})
+				assert.ErrorIs(t, err, errors.ErrTableOccupied)
+				_, err = repo.UpdateOccupancy("T9", at, seat)
+				assert.ErrorIs(t, err, errors.ErrTableNotFound)
+			})
+
+			t.Run("RetiredTableOccupancy", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(2, 4, 4)))
+
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				require.NoError(t, reserve(repo, *models.NewBooking("AAAAAA", "", 2, nil, at, 2*time.Hour), "T1"))
+				stay := func(booking models.Booking, free []models.Table) (models.Booking, error) {
+					return booking, errors.ErrTableHasBookings
+				}
+				accept := func(moved, stranded []models.Booking) error { return nil }
+				_, err := repo.RetireTable("T1", at.Add(-time.Hour), false, stay, accept)
+				require.NoError(t, err)
+				_, err = repo.RetireTable("T2", at.Add(-time.Hour), false, stay, accept)
+				require.NoError(t, err)
+
+				// Only a booking reserves a table: the one stranded on retired
+				// T1 still does, while retired T2 holds none
+				reservedAt := map[string]bool{}
+				check := func(table models.Table, reserved bool) (models.Table, error) {
+					reservedAt[table.ID] = reserved
+					return table, nil
+				}
+				for _, tableID := range []string{"T1", "T2", "T3"} {
+					_, err := repo.UpdateOccupancy(tableID, at.Add(time.Hour), check)
+					require.NoError(t, err)
+				}
+				assert.Equal(t, map[string]bool{"T1": true, "T2": false, "T3": false}, reservedAt)
+			})
+
+			t.Run("OccupiedTables", func(t *testing.T) {
+				now := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				repo := newRepository(t, testutil.NewFakeClock(now))
+				require.NoError(t, repo.InitializeTables(newTables(2, 4)))
+
+				_, err := repo.UpdateOccupancy("T1", now, func(table models.Table, reserved bool) (models.Table, error) {
+					table.IsOccupied = true
+					table.OccupiedSince = now
+					table.PartySize = 2
+					return table, nil
+				})
+				require.NoError(t, err)
+
+				// A walk-in rules its table out for windows covering now only
+				free, err := repo.GetAvailableTables(now, now.Add(2*time.Hour))
+				assert.NoError(t, err)
+				require.Len(t, free, 1)
+				assert.Equal(t, "T2", free[0].ID)
+				free, err = repo.GetAvailableTables(now.Add(time.Hour), now.Add(3*time.Hour))
+				assert.NoError(t, err)
+				assert.Len(t, free, 2)
+
+				assert.Error(t, reserve(repo, *models.NewBooking("AAAAAA", "", 2, nil, now, 2*time.Hour), "T1"))
+				assert.NoError(t, reserve(repo, *models.NewBooking("BBBBBB", "", 2, nil, now, 2*time.Hour), "T2"))
+				assert.NoError(t, reserve(repo, *models.NewBooking("CCCCCC", "", 2, nil, now.Add(time.Hour), 2*time.Hour), "T1"))
+			})
+
+			t.Run("AddTable", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(2, 4)))
+
+				added := *models.NewTable("A1", 6)
+				added.Section = "terrace"
+				added.Adjacent = []string{"T2"}
+				assert.NoError(t, repo.AddTable(added, 20))
+				assert.ErrorIs(t, repo.AddTable(*models.NewTable("T1", 8), 20), errors.ErrDuplicateTableID)
+				assert.ErrorIs(t, repo.AddTable(*models.NewTable("A2", 8), 3), errors.ErrMaxTablesExceeded)
+
+				tables, err := repo.GetTables()
+				assert.NoError(t, err)
+				assert.Equal(t, append(newTables(2, 4), added), tables)
+
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				free, err := repo.GetAvailableTables(at, at.Add(time.Hour))
+				assert.NoError(t, err)
+				assert.Len(t, free, 3)
+			})
+
+			t.Run("ConcurrentAddTable", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(2, 4)))
+
+				// Concurrent additions cannot pass the maximum together
+				results := make(chan error, 10)
+				for i := 0; i < cap(results); i++ {
+					go func(i int) {
+						results <- repo.AddTable(*models.NewTable(fmt.Sprintf("A%d", i), 4), 5)
+					}(i)
+				}
+				added := 0
+				for i := 0; i < cap(results); i++ {
+					if err := <-results; err == nil {
+						added++
+					} else {
+						assert.ErrorIs(t, err, errors.ErrMaxTablesExceeded)
+					}
+				}
+				assert.Equal(t, 3, added)
+
+				tables, err := repo.GetTables()
+				assert.NoError(t, err)
+				assert.Len(t, tables, 5)
+			})
+
+			t.Run("RetireTable", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(4, 4, 4, 4)))
+
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				now := at.Add(-2 * time.Hour)
+				require.NoError(t, reserve(repo, *models.NewBooking("PPPPPP", "", 2, nil, now.Add(-3*time.Hour), 2*time.Hour), "T1"))
+				require.NoError(t, reserve(repo, *models.NewBooking("AAAAAA", "", 2, nil, at, 2*time.Hour), "T1"))
+				require.NoError(t, reserve(repo, *models.NewBooking("BBBBBB", "", 2, nil, at.Add(3*time.Hour), 2*time.Hour), "T1"))
+				require.NoError(t, reserve(repo, *models.NewBooking("CCCCCC", "", 2, nil, at, 2*time.Hour), "T2"))
+
+				// AAAAAA moves to the first table free without T1 and T2, and
+				// BBBBBB cannot be moved
+				offered := map[string][]string{}
+				move := func(booking models.Booking, free []models.Table) (models.Booking, error) {
+					for _, table := range free {
+						offered[booking.ID] = append(offered[booking.ID], table.ID)
+					}
+					if booking.ID == "BBBBBB" {
+						return booking, errors.ErrInsufficientTables
+					}
+					booking.TableIDs = []string{free[0].ID}
+					return booking, nil
+				}
+				refuse := func(moved, stranded []models.Booking) error {
+					return errors.ErrTableHasBookings
+				}
+				accept := func(moved, stranded []models.Booking) error {
+					return nil
+				}
+
+				// A refusal leaves the table and its bookings as they were
+				_, err := repo.RetireTable("T1", now, false, move, refuse)
+				assert.ErrorIs(t, err, errors.ErrTableHasBookings)
+				assert.Equal(t, []string{"T3", "T4"}, offered["AAAAAA"])
+				saved, _ := repo.GetBooking("AAAAAA")
+				assert.Equal(t, []string{"T1"}, saved.TableIDs)
+				tables, _ := repo.GetTables()
+				assert.False(t, tables[0].Retired)
+
+				change, err := repo.RetireTable("T1", now, false, move, accept)
+				require.NoError(t, err)
+				assert.Equal(t, "T1", change.TableID)
+				assert.False(t, change.Deleted)
+				require.Len(t, change.Moved, 1)
+				assert.Equal(t, "AAAAAA", change.Moved[0].ID)
+				assert.Equal(t, []string{"T3"}, change.Moved[0].TableIDs)
+				require.Len(t, change.Stranded, 1)
+				assert.Equal(t, "BBBBBB", change.Stranded[0].ID)
+
+				saved, _ = repo.GetBooking("AAAAAA")
+				assert.Equal(t, []string{"T3"}, saved.TableIDs)
+				tables, _ = repo.GetTables()
+				assert.True(t, tables[0].Retired)
+				free, _ := repo.GetAvailableTables(at.Add(24*time.Hour), at.Add(25*time.Hour))
+				assert.Len(t, free, 3)
+				assert.NotEqual(t, "T1", free[0].ID)
+
+				// Bookings still refer to T1, so removing it keeps it retired,
+				// while T4 was never booked and is deleted
+				change, err = repo.RetireTable("T1", now, true, move, accept)
+				assert.NoError(t, err)
+				assert.False(t, change.Deleted)
+				change, err = repo.RetireTable("T4", now, true, move, accept)
+				assert.NoError(t, err)
+				assert.True(t, change.Deleted)
+				assert.Empty(t, change.Moved)
+				tables, _ = repo.GetTables()
+				assert.Len(t, tables, 3)
+
+				_, err = repo.RetireTable("T9", now, false, move, accept)
+				assert.ErrorIs(t, err, errors.ErrTableNotFound)
+			})
+
+			t.Run("Waitlist", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+
+				joined := time.Date(2030, 1, 1, 18, 0, 0, 0, time.UTC)
+				customer := models.CustomerDetails{CustomerName: "Anna", Phone: "0812345678"}
+				require.NoError(t, repo.AddToWaitlist(*models.NewWaitlistEntry("WAIT02", customer, 2, time.Time{}, joined.Add(time.Minute))))
+				require.NoError(t, repo.AddToWaitlist(*models.NewWaitlistEntry("WAIT01", customer, 4, joined.Add(time.Hour), joined)))
+				assert.ErrorIs(t, repo.AddToWaitlist(*models.NewWaitlistEntry("WAIT01", customer, 4, time.Time{}, joined)), errors.ErrDuplicateWaitlistID)
+
+				// Entries come back in the order they joined
+				waiting, err := repo.ListWaitlist(models.WaitlistStatusWaiting)
+				assert.NoError(t, err)
+				require.Len(t, waiting, 2)
+				assert.Equal(t, "WAIT01", waiting[0].ID)
+				assert.Equal(t, "WAIT02", waiting[1].ID)
+				assert.True(t, waiting[1].BookingTime.IsZero())
+				assert.Equal(t, customer, waiting[0].CustomerDetails)
+
+				// Only the offer state is saved
+				offerExpiresAt := joined.Add(15 * time.Minute)
+				offered, err := repo.UpdateWaitlistEntry("WAIT01", func(entry models.WaitlistEntry) (models.WaitlistEntry, error) {
+					entry.Status = models.WaitlistStatusOffered
+					entry.BookingID = "AAAAAA"
+					entry.OfferExpiresAt = offerExpiresAt
+					entry.NumCustomers = 99
+					return entry, nil
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, 4, offered.NumCustomers)
+
+				stored, err := repo.GetWaitlistEntry("WAIT01")
+				assert.NoError(t, err)
+				assert.Equal(t, models.WaitlistStatusOffered, stored.Status)
+				assert.Equal(t, "AAAAAA", stored.BookingID)
+				assert.True(t, offerExpiresAt.Equal(stored.OfferExpiresAt))
+				assert.True(t, joined.Add(time.Hour).Equal(stored.BookingTime))
+
+				waiting, _ = repo.ListWaitlist(models.WaitlistStatusWaiting)
+				assert.Len(t, waiting, 1)
+
+				_, err = repo.UpdateWaitlistEntry("WAIT02", func(entry models.WaitlistEntry) (models.WaitlistEntry, error) {
+					return entry, errors.ErrOfferExpired
+				})
+				assert.ErrorIs(t, err, errors.ErrOfferExpired)
+				_, err = repo.GetWaitlistEntry("WAIT99")
+				assert.ErrorIs(t, err, errors.ErrWaitlistEntryNotFound)
+				_, err = repo.UpdateWaitlistEntry("WAIT99", func(entry models.WaitlistEntry) (models.WaitlistEntry, error) {
+					return entry, nil
+				})
+				assert.ErrorIs(t, err, errors.ErrWaitlistEntryNotFound)
+			})
+
+			t.Run("HoldExpiry", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(4)))
+
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				booking := models.NewBooking("AAAAAA", "", 2, nil, at, 2*time.Hour)
+				booking.Status = models.BookingStatusPending
+				booking.HoldExpiresAt = at.Add(-time.Hour)
+				require.NoError(t, reserve(repo, *booking, "T1"))
+
+				stored, err := repo.GetBooking("AAAAAA")
+				assert.NoError(t, err)
+				assert.True(t, booking.HoldExpiresAt.Equal(stored.HoldExpiresAt))
+
+				// Confirming clears the hold expiry
+				confirmed, _, err := repo.UpdateStatus("AAAAAA", func(booking models.Booking) (models.Booking, error) {
+					booking.Status = models.BookingStatusConfirmed
+					booking.HoldExpiresAt = time.Time{}
+					return booking, nil
+				})
+				assert.NoError(t, err)
+				assert.True(t, confirmed.HoldExpiresAt.IsZero())
+				stored, _ = repo.GetBooking("AAAAAA")
+				assert.True(t, stored.HoldExpiresAt.IsZero())
+			})
+
+			t.Run("ReleaseExpiredHolds", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(4, 4, 4)))
+
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				expiresAt := at.Add(-time.Hour)
+				hold := func(id string, tableID string, expiresAt time.Time) {
+					booking := models.NewBooking(id, "", 2, nil, at, 2*time.Hour)
+					booking.Status = models.BookingStatusPending
+					booking.HoldExpiresAt = expiresAt
+					require.NoError(t, reserve(repo, *booking, tableID))
+				}
+				hold("AAAAAA", "T1", expiresAt)
+				hold("BBBBBB", "T2", expiresAt.Add(time.Minute))
+				// Pending bookings without a hold are left alone
+				hold("CCCCCC", "T3", time.Time{})
+
+				released, err := repo.ReleaseExpiredHolds(expiresAt.Add(-time.Second))
+				assert.NoError(t, err)
+				assert.Empty(t, released)
+
+				released, err = repo.ReleaseExpiredHolds(expiresAt)
+				assert.NoError(t, err)
+				require.Len(t, released, 1)
+				assert.Equal(t, "AAAAAA", released[0].ID)
+				assert.Equal(t, models.BookingStatusCancelled, released[0].Status)
+				assert.Equal(t, []string{"T1"}, released[0].TableIDs)
+
+				free, _ := repo.GetAvailableTables(at, at.Add(time.Hour))
+				require.Len(t, free, 1)
+				assert.Equal(t, "T1", free[0].ID)
+
+				// A released hold is not released again
+				released, err = repo.ReleaseExpiredHolds(at)
+				assert.NoError(t, err)
+				require.Len(t, released, 1)
+				assert.Equal(t, "BBBBBB", released[0].ID)
+				stored, _ := repo.GetBooking("CCCCCC")
+				assert.Equal(t, models.BookingStatusPending, stored.Status)
+			})
+
+			t.Run("LapsedHolds", func(t *testing.T) {
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				clock := testutil.NewFakeClock(at)
+				repo := newRepository(t, clock)
+				require.NoError(t, repo.InitializeTables(newTables(4)))
+
+				booking := models.NewBooking("AAAAAA", "", 2, nil, at, 2*time.Hour)
+				booking.Status = models.BookingStatusPending
+				booking.HoldExpiresAt = at.Add(5 * time.Minute)
+				require.NoError(t, reserve(repo, *booking, "T1"))
+
+				free, _ := repo.GetAvailableTables(at, at.Add(time.Hour))
+				assert.Empty(t, free)
+
+				// A lapsed hold stops holding its table before it is released
+				clock.Advance(5 * time.Minute)
+				free, _ = repo.GetAvailableTables(at, at.Add(time.Hour))
+				assert.Len(t, free, 1)
+				assert.NoError(t, reserve(repo, *models.NewBooking("BBBBBB", "", 2, nil, at, 2*time.Hour), "T1"))
+			})
+
+			t.Run("ReservedWindows", func(t *testing.T) {
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				clock := testutil.NewFakeClock(at.Add(-time.Hour))
+				repo := newRepository(t, clock)
+				require.NoError(t, repo.InitializeTables(newTables(2, 4, 6)))
+
+				require.NoError(t, reserve(repo, *models.NewBooking("AAAAAA", "", 8, nil, at, 2*time.Hour), "T2", "T3"))
+				require.NoError(t, reserve(repo, *models.NewBooking("BBBBBB", "", 2, nil, at.Add(-3*time.Hour), 4*time.Hour), "T1"))
+				require.NoError(t, reserve(repo, *models.NewBooking("CCCCCC", "", 2, nil, at.Add(3*time.Hour), 2*time.Hour), "T1"))
+				require.NoError(t, reserve(repo, *models.NewBooking("DDDDDD", "", 2, nil, at.Add(-6*time.Hour), 2*time.Hour), "T1"))
+				require.NoError(t, reserve(repo, *models.NewBooking("EEEEEE", "", 2, nil, at.Add(2*time.Hour), time.Hour), "T2"))
+				_, _, err := setStatus(repo, "EEEEEE", models.BookingStatusCancelled)
+				require.NoError(t, err)
+				lapsed := models.NewBooking("FFFFFF", "", 2, nil, at.Add(2*time.Hour), time.Hour)
+				lapsed.Status = models.BookingStatusPending
+				lapsed.HoldExpiresAt = at.Add(-time.Hour)
+				require.NoError(t, reserve(repo, *lapsed, "T3"))
+
+				// The long booking that started before the window overlaps it,
+				// the one that ended before it, the cancelled one, the lapsed
+				// hold and the one after it do not
+				tables, windows, err := repo.GetReservedWindows(at, at.Add(3*time.Hour))
+				assert.NoError(t, err)
+				assert.Equal(t, newTables(2, 4, 6), tables)
+				require.Len(t, windows, 3)
+				assert.Equal(t, "T1", windows[0].TableID)
+				assert.True(t, windows[0].Start.Equal(at.Add(-3*time.Hour)))
+				assert.True(t, windows[0].End.Equal(at.Add(time.Hour)))
+				assert.ElementsMatch(t, []string{"T2", "T3"}, []string{windows[1].TableID, windows[2].TableID})
+				assert.True(t, windows[1].Start.Equal(at))
+				assert.True(t, windows[1].End.Equal(at.Add(2*time.Hour)))
+			})
+
+			t.Run("AtomicReserve", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				require.NoError(t, repo.InitializeTables(newTables(2, 4, 8)))
+
+				at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				require.NoError(t, reserve(repo, *models.NewBooking("AAAAAA", "", 4, nil, at, 2*time.Hour), "T2"))
+
+				// The allocator sees only the tables free in the window, and the
+				// remaining count reflects its choice
+				var seen []models.Table
+				reserved, remaining, err := repo.ReserveTables(*models.NewBooking("BBBBBB", "", 2, nil, at.Add(time.Hour), 2*time.Hour), func(booking models.Booking, free []models.Table) (models.Booking, error) {
+					seen = free
+					booking.TableIDs = []string{free[0].ID}
+					return booking, nil
+				})
+				assert.NoError(t, err)
+				assert.Equal(t, []models.Table{*models.NewTable("T1", 2), *models.NewTable("T3", 8)}, seen)
+				assert.Equal(t, []string{"T1"}, reserved.TableIDs)
+				assert.Equal(t, 1, remaining)
+
+				// An allocation error aborts without storing anything
+				_, _, err = repo.ReserveTables(*models.NewBooking("CCCCCC", "", 8, nil, at, 2*time.Hour), func(booking models.Booking, free []models.Table) (models.Booking, error) {
+					return booking, errors.ErrInsufficientTables
+				})
+				assert.ErrorIs(t, err, errors.ErrInsufficientTables)
+				_, err = repo.GetBooking("CCCCCC")
+				assert.ErrorIs(t, err, errors.ErrInvalidBookingID)
+
+				// An existing code is never overwritten
+				err = reserve(repo, *models.NewBooking("AAAAAA", "", 2, nil, at.Add(24*time.Hour), 2*time.Hour), "T1")
+				assert.ErrorIs(t, err, errors.ErrDuplicateBookingID)
+			})
+
+			t.Run("Idempotency", func(t *testing.T) {
+				repo := newRepository(t, clock.System{})
+				now := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+				record := models.IdempotencyRecord{Key: "key-1", Fingerprint: "abc", ExpiresAt: now.Add(time.Hour)}
+
+				claimed, ok, err := repo.ClaimIdempotencyKey(record, now)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, record, claimed)
+
+				// Until the response is saved a repeat sees the claim
+				other := models.IdempotencyRecord{Key: "key-1", Fingerprint: "def", ExpiresAt: now.Add(2 * time.Hour)}
+				stored, ok, err := repo.ClaimIdempotencyKey(other, now)
+				assert.NoError(t, err)
+				assert.False(t, ok)
+				assert.Equal(t, "abc", stored.Fingerprint)
+				assert.False(t, stored.Completed())
+
+				require.NoError(t, repo.SaveIdempotentResponse("key-1", 200, []byte(`{"success":true}`)))
+				stored, ok, err = repo.ClaimIdempotencyKey(other, now.Add(time.Minute))
+				assert.NoError(t, err)
+				assert.False(t, ok)
+				assert.Equal(t, 200, stored.StatusCode)
+				assert.Equal(t, []byte(`{"success":true}`), stored.Body)
+				assert.True(t, now.Add(time.Hour).Equal(stored.ExpiresAt))
+
+				// An expired key can be claimed again
+				claimed, ok, err = repo.ClaimIdempotencyKey(other, now.Add(time.Hour))
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, "def", claimed.Fingerprint)
+
+				// So can a released one
+				require.NoError(t, repo.ReleaseIdempotencyKey("key-1"))
+				_, ok, err = repo.ClaimIdempotencyKey(record, now)
+				assert.NoError(t, err)
+				assert.True(t, ok)
+			})
+		})
+	}
+}
+
+// tenantRepositoryFactories builds two fresh repositories for different
+// restaurants sharing the same storage, for every backend
+func tenantRepositoryFactories() map[string]func(t *testing.T) (restaurant.Repository, restaurant.Repository) {
+	return map[string]func(t *testing.T) (restaurant.Repository, restaurant.Repository){
+		"memory": func(t *testing.T) (restaurant.Repository, restaurant.Repository) {
+			return memory.NewRestaurantRepository(clock.System{}), memory.NewRestaurantRepository(clock.System{})
+		},
+		"sqlite": func(t *testing.T) (restaurant.Repository, restaurant.Repository) {
+			db, err := sqlite.Open(filepath.Join(t.TempDir(), "booking.db"))
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+			return sqlite.NewRestaurantRepository(db, "siam", clock.System{}), sqlite.NewRestaurantRepository(db, "riverside", clock.System{})
+		},
+		"postgres": func(t *testing.T) (restaurant.Repository, restaurant.Repository) {
+			db := openPostgres(t, openPostgresSchema(t))
+			return postgres.NewRestaurantRepository(db, "siam", clock.System{}), postgres.NewRestaurantRepository(db, "riverside", clock.System{})
+		},
+	}
+}
+
+func TestRepositoriesIsolateRestaurants(t *testing.T) {
+	for name, newRepositories := range tenantRepositoryFactories() {
+		t.Run(name, func(t *testing.T) {
+			siam, riverside := newRepositories(t)
+			at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+
+			// Each restaurant has its own tables under the same IDs
+			require.NoError(t, siam.InitializeTables(newTables(2, 4)))
+			initialized, err := riverside.IsInitialized()
+			assert.NoError(t, err)
+			assert.False(t, initialized)
+			require.NoError(t, riverside.InitializeTables(newTables(6)))
+			require.NoError(t, riverside.AddTable(*models.NewTable("T2", 8), 20))
+			tables, err := riverside.GetTables()
+			assert.NoError(t, err)
+			assert.Equal(t, []models.Table{*models.NewTable("T1", 6), *models.NewTable("T2", 8)}, tables)
+
+			// Booking codes and waitlist IDs only need to be unique within
+			// a restaurant
+			require.NoError(t, reserve(siam, *models.NewBooking("AAAAAA", "", 2, nil, at, 2*time.Hour), "T1"))
+			require.NoError(t, reserve(riverside, *models.NewBooking("AAAAAA", "", 5, nil, at.Add(time.Hour), 2*time.Hour), "T1"))
+			booking, err := riverside.GetBooking("AAAAAA")
+			assert.NoError(t, err)
+			assert.Equal(t, 5, booking.NumCustomers)
+			_, _, err = riverside.UpdateStatus("AAAAAA", func(booking models.Booking) (models.Booking, error) {
+				booking.Status = models.BookingStatusCancelled
+				return booking, nil
+			})
+			assert.NoError(t, err)
+			booking, err = siam.GetBooking("AAAAAA")
+			assert.NoError(t, err)
+			assert.Equal(t, models.BookingStatusConfirmed, booking.Status)
+			_, err = riverside.GetBooking("BBBBBB")
+			assert.ErrorIs(t, err, errors.ErrInvalidBookingID)
+
+			joined := at.Add(-time.Hour)
+			require.NoError(t, siam.AddToWaitlist(*models.NewWaitlistEntry("WAIT01", models.CustomerDetails{}, 2, time.Time{}, joined)))
+			require.NoError(t, riverside.AddToWaitlist(*models.NewWaitlistEntry("WAIT01", models.CustomerDetails{}, 6, time.Time{}, joined)))
+			entry, err := siam.GetWaitlistEntry("WAIT01")
+			assert.NoError(t, err)
+			assert.Equal(t, 2, entry.NumCustomers)
+
+			// Nothing one restaurant does shows up in the other
+			bookings, err := siam.ListBookings(models.BookingFilter{}, nil, 10)
+			assert.NoError(t, err)
+			require.Len(t, bookings, 1)
+			assert.Equal(t, 2, bookings[0].NumCustomers)
+			free, err := riverside.GetAvailableTables(at, at.Add(time.Hour))
+			assert.NoError(t, err)
+			assert.Len(t, free, 2)
+			_, windows, err := riverside.GetReservedWindows(at, at.Add(time.Hour))
+			assert.NoError(t, err)
+			assert.Empty(t, windows)
+			_, err = riverside.UpdateOccupancy("T2", at, func(table models.Table, reserved bool) (models.Table, error) {
+				table.IsOccupied = true
+				return table, nil
+			})
+			assert.NoError(t, err)
+			_, err = siam.UpdateOccupancy("T3", at, func(table models.Table, reserved bool) (models.Table, error) {
+				return table, nil
+			})
+			assert.ErrorIs(t, err, errors.ErrTableNotFound)
+			tables, err = siam.GetTables()
+			assert.NoError(t, err)
+			require.Len(t, tables, 2)
+			assert.False(t, tables[1].IsOccupied)
+			waiting, err := riverside.ListWaitlist(models.WaitlistStatusWaiting)
+			assert.NoError(t, err)
+			require.Len(t, waiting, 1)
+			assert.Equal(t, 6, waiting[0].NumCustomers)
+
+			// Removing a table only removes the restaurant's own
+			keep := func(booking models.Booking, free []models.Table) (models.Booking, error) {
+				return booking, errors.ErrInsufficientTables
+			}
+			change, err := riverside.RetireTable("T2", at, true, keep, func(moved, stranded []models.Booking) error { return nil })
+			assert.NoError(t, err)
+			assert.True(t, change.Deleted)
+			tables, err = siam.GetTables()
+			assert.NoError(t, err)
+			assert.Len(t, tables, 2)
+
+			record := models.IdempotencyRecord{Key: "key-1", Fingerprint: "abc", ExpiresAt: at.Add(time.Hour)}
+			_, claimed, err := siam.ClaimIdempotencyKey(record, at)
+			assert.NoError(t, err)
+			assert.True(t, claimed)
+			_, claimed, err = riverside.ClaimIdempotencyKey(record, at)
+			assert.NoError(t, err)
+			assert.True(t, claimed)
+		})
+	}
+}
+
+func TestSQLiteRepositoryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "booking.db")
+	at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+
+	db, err := sqlite.Open(path)
+	require.NoError(t, err)
+	repo := sqlite.NewRestaurantRepository(db, config.DefaultRestaurantID, clock.System{})
+	require.NoError(t, repo.InitializeTables(newTables(2, 4)))
+	require.NoError(t, reserve(repo, *models.NewBooking("AAAAAA", "", 2, nil, at, 2*time.Hour), "T1"))
+	require.NoError(t, db.Close())
+
+	// Reopening runs the migrations again, which must be a no-op
+	db, err = sqlite.Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+	repo = sqlite.NewRestaurantRepository(db, config.DefaultRestaurantID, clock.System{})
+
+	initialized, err := repo.IsInitialized()
+	assert.NoError(t, err)
+	assert.True(t, initialized)
+
+	free, err := repo.GetAvailableTables(at, at.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Table{*models.NewTable("T2", 4)}, free)
+}
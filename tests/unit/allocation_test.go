@@ -0,0 +1,110 @@
+package unit
+
+import (
+	"testing"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/domain/restaurant"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tableIDs(tables []models.Table) []string {
+	ids := make([]string, len(tables))
+	for i, table := range tables {
+		ids[i] = table.ID
+	}
+	return ids
+}
+
+func TestNewAllocationStrategy(t *testing.T) {
+	for _, name := range []string{restaurant.StrategyFirstFit, restaurant.StrategyBestFit, restaurant.StrategyFewestTables, restaurant.StrategyLargePartyPriority} {
+		strategy, err := restaurant.NewAllocationStrategy(name, 6)
+		assert.NoError(t, err)
+		assert.Equal(t, name, strategy.Name())
+	}
+
+	strategy, err := restaurant.NewAllocationStrategy("", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, restaurant.StrategyFirstFit, strategy.Name())
+
+	_, err = restaurant.NewAllocationStrategy("random", 0)
+	assert.Error(t, err)
+
+	_, err = restaurant.NewAllocationStrategy(restaurant.StrategyLargePartyPriority, 0)
+	assert.Error(t, err)
+}
+
+func TestAllocationStrategies(t *testing.T) {
+	// T1..T6 = 2, 2, 4, 4, 8, 6 seats
+	free := newTables(2, 2, 4, 4, 8, 6)
+
+	tests := []struct {
+		strategy     restaurant.AllocationStrategy
+		numCustomers int
+		expected     []string
+	}{
+		{restaurant.FirstFit{}, 3, []string{"T1", "T2"}},
+		{restaurant.FirstFit{}, 7, []string{"T1", "T2", "T3"}},
+		{restaurant.BestFit{}, 3, []string{"T3"}},
+		{restaurant.BestFit{}, 6, []string{"T6"}},
+		{restaurant.BestFit{}, 7, []string{"T5"}},
+		{restaurant.BestFit{}, 10, []string{"T1", "T5"}},
+		{restaurant.FewestTables{}, 10, []string{"T1", "T5"}},
+		{restaurant.FewestTables{}, 12, []string{"T3", "T5"}},
+		{restaurant.BestFit{}, 12, []string{"T3", "T5"}},
+		{restaurant.LargePartyPriority{LargePartySize: 6}, 5, []string{"T1", "T3"}},
+		{restaurant.LargePartyPriority{LargePartySize: 6}, 8, []string{"T5"}},
+		{restaurant.BestFit{}, 5, []string{"T6"}},
+		{restaurant.BestFit{}, 27, nil},
+	}
+
+	for _, tt := range tests {
+		assigned := tt.strategy.Allocate(free, tt.numCustomers)
+		if tt.expected == nil {
+			assert.Nil(t, assigned, "%s for %d", tt.strategy.Name(), tt.numCustomers)
+			continue
+		}
+		assert.Equal(t, tt.expected, tableIDs(assigned), "%s for %d", tt.strategy.Name(), tt.numCustomers)
+	}
+}
+
+func TestAllocateHugeParty(t *testing.T) {
+	// A party larger than every free seat together is turned away without
+	// sizing a search for it
+	free := newTables(2, 4, 4, 8)
+	for _, strategy := range []restaurant.AllocationStrategy{restaurant.FirstFit{}, restaurant.BestFit{}, restaurant.FewestTables{}, restaurant.LargePartyPriority{LargePartySize: 6}} {
+		assert.Nil(t, strategy.Allocate(free, 1_000_000_000), strategy.Name())
+	}
+}
+
+func TestAllocateHugeTable(t *testing.T) {
+	// The search is sized by the party, not by the largest free table
+	huge := []models.Table{*models.NewTable("H1", 200_000_000)}
+	assert.Equal(t, huge, restaurant.BestFit{}.Allocate(huge, 1))
+
+	free := append(newTables(2, 4), huge[0])
+	assert.Equal(t, []models.Table{free[1]}, restaurant.BestFit{}.Allocate(free, 3))
+	assert.Equal(t, []models.Table{free[0], free[1]}, restaurant.BestFit{}.Allocate(free, 6))
+	assert.Equal(t, huge, restaurant.BestFit{}.Allocate(free, 7))
+	assert.Equal(t, huge, restaurant.FewestTables{}.Allocate(free, 6))
+}
+
+func TestSeatUtilizationByStrategy(t *testing.T) {
+	free := newTables(2, 4, 4, 8)
+
+	utilization := func(strategy restaurant.AllocationStrategy, numCustomers int) float64 {
+		assigned := strategy.Allocate(free, numCustomers)
+		booking := models.Booking{NumCustomers: numCustomers}
+		for _, table := range assigned {
+			booking.SeatsAssigned += table.Capacity
+		}
+		return booking.SeatUtilization()
+	}
+
+	// First-fit seats a couple at the 2-top but puts a party of 8 across three tables
+	assert.Equal(t, 1.0, utilization(restaurant.FirstFit{}, 2))
+	assert.Equal(t, 0.8, utilization(restaurant.FirstFit{}, 8))
+	assert.Equal(t, 1.0, utilization(restaurant.BestFit{}, 8))
+	assert.Equal(t, 0.75, utilization(restaurant.FewestTables{}, 6))
+}
@@ -0,0 +1,90 @@
+package unit
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"booking-dinner/internal/domain/restaurant"
+	"booking-dinner/internal/errors"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodeGenerator(t *testing.T) {
+	codes, err := restaurant.NewCodeGenerator("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 6, false, false)
+	assert.NoError(t, err)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		code, err := codes.Generate()
+		assert.NoError(t, err)
+		assert.True(t, codes.Valid(code), code)
+		seen[code] = true
+	}
+	assert.Greater(t, len(seen), 990)
+
+	assert.False(t, codes.Valid("ABCDE"))
+	assert.False(t, codes.Valid("ABCDEFG"))
+	assert.False(t, codes.Valid("abcdef"))
+	assert.False(t, codes.Valid("ABC-EF"))
+}
+
+func TestCodeGeneratorExcludesAmbiguousCharacters(t *testing.T) {
+	codes, err := restaurant.NewCodeGenerator("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 8, true, false)
+	assert.NoError(t, err)
+
+	for i := 0; i < 500; i++ {
+		code, err := codes.Generate()
+		assert.NoError(t, err)
+		assert.False(t, strings.ContainsAny(code, "0O1I"), code)
+	}
+	assert.False(t, codes.Valid("ABCDEFG0"))
+	assert.Equal(t, math.Pow(32, 8), codes.Keyspace())
+}
+
+func TestCodeGeneratorValidation(t *testing.T) {
+	_, err := restaurant.NewCodeGenerator("ABC", 0, false, false)
+	assert.Error(t, err)
+
+	_, err = restaurant.NewCodeGenerator("0O1I", 6, true, false)
+	assert.Error(t, err)
+
+	_, err = restaurant.NewCodeGenerator("ABCÄ", 6, false, false)
+	assert.Error(t, err)
+}
+
+func TestCodeGeneratorKeyspace(t *testing.T) {
+	small, _ := restaurant.NewCodeGenerator("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 3, false, false)
+	assert.Error(t, small.CheckKeyspace(100))
+
+	large, _ := restaurant.NewCodeGenerator("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 6, false, false)
+	assert.NoError(t, large.CheckKeyspace(100))
+}
+
+func TestCodeGeneratorCheckCharacter(t *testing.T) {
+	codes, err := restaurant.NewCodeGenerator("ABCDEFGHJKLMNPQRSTUVWXYZ23456789", 6, false, true)
+	assert.NoError(t, err)
+
+	code, err := codes.Generate()
+	assert.NoError(t, err)
+	assert.Len(t, code, 7)
+	assert.NoError(t, codes.Validate(code))
+
+	// Every single-character typo is caught by the check character
+	charset := "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	for i := 0; i < len(code); i++ {
+		for j := 0; j < len(charset); j++ {
+			if charset[j] == code[i] {
+				continue
+			}
+			typo := code[:i] + string(charset[j]) + code[i+1:]
+			assert.ErrorIs(t, codes.Validate(typo), errors.ErrInvalidCheckCharacter, typo)
+		}
+	}
+
+	// Codes of the wrong shape are still plain invalid IDs
+	assert.ErrorIs(t, codes.Validate(code[:6]), errors.ErrInvalidBookingID)
+	assert.ErrorIs(t, codes.Validate("abcdefg"), errors.ErrInvalidBookingID)
+	assert.Equal(t, math.Pow(32, 6), codes.Keyspace())
+}
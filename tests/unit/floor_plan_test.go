@@ -0,0 +1,116 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/domain/restaurant"
+	"booking-dinner/internal/errors"
+	"booking-dinner/tests/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newFloorPlan returns T1..T5 of 4 seats where T1-T2-T3 stand in a row that
+// can be pushed together end to end, and T4 and T5 are a patio pair
+func newFloorPlan() []models.Table {
+	tables := newTables(4, 4, 4, 4, 4)
+	tables[0].Adjacent = []string{"T2"}
+	tables[1].Adjacent = []string{"T3"}
+	tables[3].JoinGroup = "patio"
+	tables[4].JoinGroup = "patio"
+	return tables
+}
+
+func TestReserveTablesOnFloorPlan(t *testing.T) {
+	reserve := func(strategy restaurant.AllocationStrategy, free []models.Table, numCustomers int) ([]string, error) {
+		mockRepo := new(MockRepository)
+		options := testOptions()
+		options.Strategy = strategy
+		service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+		mockRepo.On("IsInitialized").Return(true, nil)
+		mockRepo.On("ReserveTables", mock.Anything).Return(free, nil)
+		booking, _, err := service.ReserveTables(numCustomers, time.Time{}, models.CustomerDetails{}, models.SectionPreference{})
+		return booking.TableIDs, err
+	}
+
+	// The strategy's choice is kept when the tables can be pushed together
+	tableIDs, err := reserve(restaurant.FirstFit{}, newFloorPlan(), 12)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"T1", "T2", "T3"}, tableIDs)
+
+	// T1 and T3 are not adjacent once T2 is taken, but the patio pair is
+	free := newFloorPlan()
+	free = append(free[:1], free[2:]...)
+	tableIDs, err = reserve(restaurant.FirstFit{}, free, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"T4", "T5"}, tableIDs)
+
+	// A chain is searched for a run of adjacent tables
+	row := newTables(6, 2, 2, 2)
+	row[0].Adjacent = []string{"T2"}
+	row[1].Adjacent = []string{"T3"}
+	row[2].Adjacent = []string{"T4"}
+	tableIDs, err = reserve(restaurant.BestFit{}, row, 8)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"T1", "T2"}, tableIDs)
+	tableIDs, err = reserve(restaurant.BestFit{}, row[1:], 6)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"T2", "T3", "T4"}, tableIDs)
+
+	// Enough seats are free but no free tables can be pushed together
+	_, err = reserve(restaurant.FirstFit{}, []models.Table{free[0], free[1], free[2]}, 10)
+	assert.Equal(t, errors.ErrNoTableCombination, err)
+
+	// Not enough seats at all
+	_, err = reserve(restaurant.FirstFit{}, newFloorPlan(), 30)
+	assert.Equal(t, errors.ErrInsufficientTables, err)
+
+	// Without a floor plan any tables can be combined
+	tableIDs, err = reserve(restaurant.FirstFit{}, newTables(4, 4, 4), 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"T1", "T2", "T3"}, tableIDs)
+}
+
+func TestGetAvailabilityOnFloorPlan(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.SlotInterval = time.Hour
+	options.Schedule = newTestSchedule(t)
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+
+	// T1 and T3 are free but were pushed together only through T2, which
+	// is booked all evening
+	dinner := time.Date(2030, 1, 2, 17, 30, 0, 0, bangkok)
+	mockRepo.On("GetReservedWindows", mock.Anything, mock.Anything).Return(newFloorPlan()[:3], []models.ReservedWindow{
+		{TableID: "T2", Start: dinner.Add(-6 * time.Hour), End: dinner.Add(8 * time.Hour)},
+	}, nil)
+
+	slots, err := service.GetAvailability("2030-01-02", 8, "")
+	require.NoError(t, err)
+	assert.Empty(t, slots)
+
+	slots, err = service.GetAvailability("2030-01-02", 4, "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, slots)
+}
+
+func TestInitializeTablesWithFloorPlan(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	mockRepo.On("IsInitialized").Return(false, nil)
+	mockRepo.On("InitializeTables", newFloorPlan()).Return(nil)
+	assert.NoError(t, service.InitializeTables(newFloorPlan()))
+
+	unknown := newFloorPlan()
+	unknown[0].Adjacent = []string{"T9"}
+	assert.True(t, errors.IsInitializationError(service.InitializeTables(unknown)))
+
+	self := newFloorPlan()
+	self[0].Adjacent = []string{"T1"}
+	assert.True(t, errors.IsInitializationError(service.InitializeTables(self)))
+}
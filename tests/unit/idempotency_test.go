@@ -0,0 +1,57 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/domain/restaurant"
+	"booking-dinner/internal/errors"
+	"booking-dinner/tests/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestClaimIdempotencyKey(t *testing.T) {
+	claim := func(stored models.IdempotencyRecord, claimed bool) (*models.IdempotencyRecord, error, *MockRepository) {
+		mockRepo := new(MockRepository)
+		options := testOptions()
+		options.IdempotencyTTL = 24 * time.Hour
+		service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+		mockRepo.On("ClaimIdempotencyKey", mock.Anything).Return(stored, claimed, nil)
+		record, err := service.ClaimIdempotencyKey("key-1", "abc")
+		return record, err, mockRepo
+	}
+
+	// A new key is claimed for the TTL and the request runs
+	record, err, mockRepo := claim(models.IdempotencyRecord{}, true)
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+	mockRepo.AssertCalled(t, "ClaimIdempotencyKey", models.IdempotencyRecord{Key: "key-1", Fingerprint: "abc", ExpiresAt: testNow.Add(24 * time.Hour)})
+
+	// A repeat gets the stored response
+	stored := models.IdempotencyRecord{Key: "key-1", Fingerprint: "abc", StatusCode: 200, Body: []byte(`{}`)}
+	record, err, _ = claim(stored, false)
+	assert.NoError(t, err)
+	assert.Equal(t, &stored, record)
+
+	_, err, _ = claim(models.IdempotencyRecord{Key: "key-1", Fingerprint: "abc"}, false)
+	assert.Equal(t, errors.ErrIdempotencyInProgress, err)
+
+	stored.Fingerprint = "def"
+	_, err, _ = claim(stored, false)
+	assert.Equal(t, errors.ErrIdempotencyKeyReused, err)
+}
+
+func TestIdempotencyDisabled(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	record, err := service.ClaimIdempotencyKey("key-1", "abc")
+	assert.NoError(t, err)
+	assert.Nil(t, record)
+	assert.NoError(t, service.SaveIdempotentResponse("key-1", 200, nil))
+	assert.NoError(t, service.ReleaseIdempotencyKey("key-1"))
+	mockRepo.AssertNotCalled(t, "ClaimIdempotencyKey", mock.Anything)
+}
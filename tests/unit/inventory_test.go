@@ -0,0 +1,129 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/domain/restaurant"
+	"booking-dinner/internal/errors"
+	"booking-dinner/tests/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddTable(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.MaxTables = 3
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+
+	// T2 is retired, so its number stays taken but it no longer counts
+	// towards the maximum of three tables
+	tables := newTables(4, 4, 2)
+	tables[1].Retired = true
+	mockRepo.On("IsInitialized").Return(true, nil)
+	mockRepo.On("GetTables").Return(tables, nil)
+	mockRepo.On("AddTable", mock.Anything, 3).Return(nil)
+
+	added, err := service.AddTable(models.Table{Adjacent: []string{"T3"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "T4", added.ID)
+	assert.Equal(t, 4, added.Capacity)
+	assert.Equal(t, []string{"T3"}, added.Adjacent)
+	mockRepo.AssertCalled(t, "AddTable", added, 3)
+
+	_, err = service.AddTable(*models.NewTable("T2", 6))
+	assert.Equal(t, errors.ErrDuplicateTableID, err)
+
+	_, err = service.AddTable(models.Table{ID: "A1", Adjacent: []string{"A1"}})
+	assert.True(t, errors.IsValidationError(err))
+	_, err = service.AddTable(models.Table{ID: "A1", Adjacent: []string{"T9"}})
+	assert.True(t, errors.IsValidationError(err))
+	_, err = service.AddTable(models.Table{ID: "A1", Capacity: -2})
+	assert.True(t, errors.IsValidationError(err))
+	_, err = service.AddTable(models.Table{ID: "A1", Capacity: restaurant.MaxTableCapacity + 1})
+	assert.True(t, errors.IsValidationError(err))
+}
+
+func TestAddTableLimits(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.MaxTables = 2
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+	mockRepo.On("IsInitialized").Return(true, nil).Once()
+	mockRepo.On("GetTables").Return(newTables(4, 4), nil)
+	mockRepo.On("AddTable", mock.Anything, 2).Return(errors.ErrMaxTablesExceeded)
+
+	// The repository enforces the maximum while adding the table
+	_, err := service.AddTable(models.Table{})
+	assert.Equal(t, errors.ErrMaxTablesExceeded, err)
+	mockRepo.AssertCalled(t, "AddTable", *models.NewTable("T3", 4), 2)
+
+	mockRepo.On("IsInitialized").Return(false, nil)
+	_, err = service.AddTable(models.Table{})
+	assert.Equal(t, errors.ErrTableNotInitialized, err)
+	mockRepo.AssertNumberOfCalls(t, "AddTable", 1)
+}
+
+func TestRetireTable(t *testing.T) {
+	newService := func(affected []models.Booking, free []models.Table) (restaurant.Service, *MockRepository) {
+		mockRepo := new(MockRepository)
+		service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+		mockRepo.On("RetireTable", "T1", mock.Anything).Return(affected, free, nil)
+		return service, mockRepo
+	}
+
+	later := models.NewBooking("BOOK55", "", 4, []string{"T1"}, testNow.Add(time.Hour), 2*time.Hour)
+	seated := models.NewBooking("BOOK66", "", 2, []string{"T1"}, testNow.Add(-time.Hour), 2*time.Hour)
+	seated.Status = models.BookingStatusSeated
+
+	// Bookings that can move do not stop the table being retired
+	service, _ := newService([]models.Booking{*later}, newTables(2, 2, 6)[2:])
+	change, err := service.RetireTable("T1", false)
+	require.NoError(t, err)
+	require.Len(t, change.Moved, 1)
+	assert.Equal(t, []string{"T3"}, change.Moved[0].TableIDs)
+	assert.Empty(t, change.Stranded)
+
+	// A seated party cannot be moved, so it needs force
+	service, _ = newService([]models.Booking{*later, *seated}, newTables(2, 2, 6)[2:])
+	change, err = service.RetireTable("T1", false)
+	assert.Equal(t, errors.ErrTableHasBookings, err)
+	require.Len(t, change.Stranded, 1)
+	assert.Equal(t, "BOOK66", change.Stranded[0].ID)
+
+	change, err = service.RetireTable("T1", true)
+	assert.NoError(t, err)
+	assert.Len(t, change.Moved, 1)
+	assert.Len(t, change.Stranded, 1)
+
+	// Removing goes through the same checks
+	service, mockRepo := newService([]models.Booking{*later}, nil)
+	_, err = service.RemoveTable("T1", false)
+	assert.Equal(t, errors.ErrTableHasBookings, err)
+	mockRepo.AssertCalled(t, "RetireTable", "T1", true)
+}
+
+func TestRetiredTables(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.SlotInterval = time.Hour
+	options.Schedule = newTestSchedule(t)
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+
+	tables := newTables(4, 4)
+	tables[0].Retired = true
+	mockRepo.On("UpdateOccupancy", "T1").Return(tables[0], false, nil)
+	mockRepo.On("GetReservedWindows", mock.Anything, mock.Anything).Return(tables, []models.ReservedWindow{}, nil)
+
+	_, err := service.SeatWalkIn("T1", 2)
+	assert.Equal(t, errors.ErrTableRetired, err)
+
+	slots, err := service.GetAvailability("2030-01-02", 4, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, slots)
+	assert.Equal(t, 1, slots[0].AvailableTables)
+}
@@ -1,82 +1,346 @@
 package unit
 
 import (
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"booking-dinner/internal/domain/models"
 	"booking-dinner/internal/domain/restaurant"
+	"booking-dinner/internal/errors"
+	"booking-dinner/tests/testutil"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
+var testCodes, _ = restaurant.NewCodeGenerator("ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 6, false, false)
+
+// testNow is the time every test's clock starts at
+var testNow = time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+
+// testOptions returns the service options most tests use: first-fit
+// allocation on up to 20 tables of 4 seats, booked for 2 hours
+func testOptions() restaurant.Options {
+	return restaurant.Options{
+		Strategy:            restaurant.FirstFit{},
+		Codes:               testCodes,
+		SeatsPerTable:       4,
+		MaxTables:           20,
+		ReservationDuration: 2 * time.Hour,
+	}
+}
+
 // MockRepository is a mock of the Repository interface
 type MockRepository struct {
 	mock.Mock
 }
 
-func (m *MockRepository) InitializeTables(numTables int) error {
-	args := m.Called(numTables)
+func (m *MockRepository) InitializeTables(tables []models.Table) error {
+	args := m.Called(tables)
 	return args.Error(0)
 }
 
-func (m *MockRepository) ReserveTables(booking models.Booking) error {
+// ReserveTables runs allocate against the free tables the test returns for the booking
+func (m *MockRepository) ReserveTables(booking models.Booking, allocate func(booking models.Booking, free []models.Table) (models.Booking, error)) (models.Booking, int, error) {
 	args := m.Called(booking)
-	return args.Error(0)
+	if err := args.Error(1); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	free := args.Get(0).([]models.Table)
+	booking, err := allocate(booking, free)
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	return booking, len(free) - booking.TablesBooked(), nil
 }
 
-func (m *MockRepository) CancelReservation(bookingID string) (int, error) {
+// UpdateStatus applies update to the booking the test returns
+func (m *MockRepository) UpdateStatus(bookingID string, update func(booking models.Booking) (models.Booking, error)) (models.Booking, int, error) {
 	args := m.Called(bookingID)
-	return args.Int(0), args.Error(1)
+	if err := args.Error(2); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	booking, err := update(args.Get(0).(models.Booking))
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	return booking, args.Int(1), nil
+}
+
+// ModifyReservation applies update to the booking the test returns, then runs
+// allocate against the free tables it returns
+func (m *MockRepository) ModifyReservation(bookingID string, update func(booking models.Booking) (models.Booking, error), allocate func(booking models.Booking, free []models.Table) (models.Booking, error)) (models.Booking, int, error) {
+	args := m.Called(bookingID)
+	if err := args.Error(2); err != nil {
+		return models.Booking{}, 0, err
+	}
+
+	booking, err := update(args.Get(0).(models.Booking))
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	free := args.Get(1).([]models.Table)
+	booking, err = allocate(booking, free)
+	if err != nil {
+		return models.Booking{}, 0, err
+	}
+	return booking, len(free) - booking.TablesBooked(), nil
+}
+
+func (m *MockRepository) GetBooking(bookingID string) (models.Booking, error) {
+	args := m.Called(bookingID)
+	return args.Get(0).(models.Booking), args.Error(1)
+}
+
+func (m *MockRepository) ListBookings(filter models.BookingFilter, after *models.BookingCursor, limit int) ([]models.Booking, error) {
+	args := m.Called(filter, after, limit)
+	return args.Get(0).([]models.Booking), args.Error(1)
+}
+
+func (m *MockRepository) GetAvailableTables(start, end time.Time) ([]models.Table, error) {
+	args := m.Called(start, end)
+	return args.Get(0).([]models.Table), args.Error(1)
+}
+
+func (m *MockRepository) GetReservedWindows(start, end time.Time) ([]models.Table, []models.ReservedWindow, error) {
+	args := m.Called(start, end)
+	return args.Get(0).([]models.Table), args.Get(1).([]models.ReservedWindow), args.Error(2)
 }
 
-func (m *MockRepository) GetAvailableTables() int {
+func (m *MockRepository) GetTables() ([]models.Table, error) {
 	args := m.Called()
-	return args.Int(0)
+	return args.Get(0).([]models.Table), args.Error(1)
+}
+
+// UpdateOccupancy applies update to the table the test returns, which is
+// reserved if the test says so
+func (m *MockRepository) UpdateOccupancy(tableID string, at time.Time, update func(table models.Table, reserved bool) (models.Table, error)) (models.Table, error) {
+	args := m.Called(tableID)
+	if err := args.Error(2); err != nil {
+		return models.Table{}, err
+	}
+	return update(args.Get(0).(models.Table), args.Bool(1))
 }
 
-func (m *MockRepository) IsInitialized() bool {
+func (m *MockRepository) IsInitialized() (bool, error) {
 	args := m.Called()
-	return args.Bool(0)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) AddTable(table models.Table, maxTables int) error {
+	args := m.Called(table, maxTables)
+	return args.Error(0)
+}
+
+// RetireTable runs move on each booking the test returns as holding the
+// table, against the free tables it returns, and then check. The table counts
+// as deleted whenever remove is set.
+func (m *MockRepository) RetireTable(tableID string, now time.Time, remove bool, move func(booking models.Booking, free []models.Table) (models.Booking, error), check func(moved, stranded []models.Booking) error) (models.TableChange, error) {
+	args := m.Called(tableID, remove)
+	if err := args.Error(2); err != nil {
+		return models.TableChange{}, err
+	}
+
+	change := models.TableChange{TableID: tableID, Deleted: remove}
+	free := args.Get(1).([]models.Table)
+	for _, booking := range args.Get(0).([]models.Booking) {
+		moved, err := move(booking, free)
+		if err != nil {
+			change.Stranded = append(change.Stranded, booking)
+			continue
+		}
+		change.Moved = append(change.Moved, moved)
+	}
+	if err := check(change.Moved, change.Stranded); err != nil {
+		return models.TableChange{}, err
+	}
+	return change, nil
+}
+
+func (m *MockRepository) ReleaseExpiredHolds(now time.Time) ([]models.Booking, error) {
+	args := m.Called(now)
+	return args.Get(0).([]models.Booking), args.Error(1)
+}
+
+func (m *MockRepository) AddToWaitlist(entry models.WaitlistEntry) error {
+	args := m.Called(entry)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetWaitlistEntry(entryID string) (models.WaitlistEntry, error) {
+	args := m.Called(entryID)
+	return args.Get(0).(models.WaitlistEntry), args.Error(1)
+}
+
+func (m *MockRepository) ListWaitlist(status string) ([]models.WaitlistEntry, error) {
+	args := m.Called(status)
+	return args.Get(0).([]models.WaitlistEntry), args.Error(1)
+}
+
+// UpdateWaitlistEntry applies update to the entry the test returns
+func (m *MockRepository) UpdateWaitlistEntry(entryID string, update func(entry models.WaitlistEntry) (models.WaitlistEntry, error)) (models.WaitlistEntry, error) {
+	args := m.Called(entryID)
+	if err := args.Error(1); err != nil {
+		return models.WaitlistEntry{}, err
+	}
+	return update(args.Get(0).(models.WaitlistEntry))
+}
+
+func (m *MockRepository) ClaimIdempotencyKey(record models.IdempotencyRecord, now time.Time) (models.IdempotencyRecord, bool, error) {
+	args := m.Called(record)
+	return args.Get(0).(models.IdempotencyRecord), args.Bool(1), args.Error(2)
+}
+
+func (m *MockRepository) SaveIdempotentResponse(key string, statusCode int, body []byte) error {
+	args := m.Called(key, statusCode, body)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ReleaseIdempotencyKey(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+// newTables builds tables T1..Tn with the given capacities
+func newTables(capacities ...int) []models.Table {
+	tables := make([]models.Table, len(capacities))
+	for i, capacity := range capacities {
+		tables[i] = *models.NewTable(fmt.Sprintf("T%d", i+1), capacity)
+	}
+	return tables
 }
 
 func TestInitializeTables(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := restaurant.NewService(mockRepo, 4, 20, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 6)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	mockRepo.On("IsInitialized").Return(false, nil)
+	mockRepo.On("InitializeTables", newTables(4, 4, 4, 4, 4, 4, 4, 4, 4, 4)).Return(nil)
+
+	err := service.InitializeTableCount(10)
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestInitializeMixedTables(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
 
-	mockRepo.On("IsInitialized").Return(false)
-	mockRepo.On("InitializeTables", 10).Return(nil)
+	mockRepo.On("IsInitialized").Return(false, nil)
+	mockRepo.On("InitializeTables", []models.Table{*models.NewTable("A1", 2), *models.NewTable("B1", 8), *models.NewTable("T3", 4)}).Return(nil)
 
-	err := service.InitializeTables(10)
+	err := service.InitializeTables([]models.Table{{ID: "A1", Capacity: 2}, {ID: "B1", Capacity: 8}, {}})
 	assert.NoError(t, err)
 
+	err = service.InitializeTables([]models.Table{{ID: "A1", Capacity: 2}, {ID: "A1", Capacity: 4}})
+	assert.Error(t, err)
+
+	for _, capacity := range []int{-1, restaurant.MaxTableCapacity + 1, 200_000_000} {
+		err = service.InitializeTables([]models.Table{{ID: "A1", Capacity: capacity}})
+		assert.True(t, errors.IsInitializationError(err), "capacity %d: %v", capacity, err)
+	}
+
 	mockRepo.AssertExpectations(t)
 }
 
 func TestReserveTables(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := restaurant.NewService(mockRepo, 4, 20, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 6)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
 
-	mockRepo.On("IsInitialized").Return(true)
-	mockRepo.On("GetAvailableTables").Return(10)
-	mockRepo.On("ReserveTables", mock.AnythingOfType("models.Booking")).Return(nil)
+	bookingTime := testNow.Add(24 * time.Hour)
 
-	bookingID, tablesBooked, remaining, err := service.ReserveTables(3)
+	mockRepo.On("IsInitialized").Return(true, nil)
+	mockRepo.On("ReserveTables", mock.MatchedBy(func(b models.Booking) bool {
+		return b.BookingTime.Equal(bookingTime) && b.Duration == 2*time.Hour
+	})).Return(newTables(2, 4, 8), nil)
+
+	booking, remaining, err := service.ReserveTables(5, bookingTime, models.CustomerDetails{}, models.SectionPreference{})
 	assert.NoError(t, err)
-	assert.NotEmpty(t, bookingID)
-	assert.Equal(t, 1, tablesBooked)
-	assert.Equal(t, 9, remaining)
+	assert.NotEmpty(t, booking.ID)
+	assert.Equal(t, []string{"T1", "T2"}, booking.TableIDs)
+	assert.Equal(t, 2, booking.TablesBooked())
+	assert.Equal(t, 1, remaining)
+
+	_, _, err = service.ReserveTables(15, bookingTime, models.CustomerDetails{}, models.SectionPreference{})
+	assert.ErrorIs(t, err, errors.ErrInsufficientTables)
+	_, _, err = service.ReserveTables(1_000_000_000, bookingTime, models.CustomerDetails{}, models.SectionPreference{})
+	assert.ErrorIs(t, err, errors.ErrInsufficientTables)
 
 	mockRepo.AssertExpectations(t)
 }
 
+func TestReserveTablesCustomerDetails(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	mockRepo.On("IsInitialized").Return(true, nil)
+	mockRepo.On("ReserveTables", mock.Anything).Return(newTables(4), nil)
+
+	booking, _, err := service.ReserveTables(2, time.Time{}, models.CustomerDetails{
+		CustomerName:    "  Somchai Jaidee ",
+		Phone:           "+66 (81) 234-5678",
+		Email:           "Somchai@Example.com",
+		SpecialRequests: "Window seat",
+		DietaryNotes:    "No peanuts",
+	}, models.SectionPreference{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Somchai Jaidee", booking.CustomerName)
+	assert.Equal(t, "+66812345678", booking.Phone)
+	assert.Equal(t, "somchai@example.com", booking.Email)
+	assert.Equal(t, "Window seat", booking.SpecialRequests)
+	assert.Equal(t, "No peanuts", booking.DietaryNotes)
+
+	for _, customer := range []models.CustomerDetails{
+		{Phone: "12345"},
+		{Phone: "call me"},
+		{Email: "not-an-email"},
+		{Email: "Somchai <somchai@example.com>"},
+		{CustomerName: strings.Repeat("a", 101)},
+		{DietaryNotes: strings.Repeat("a", 501)},
+	} {
+		_, _, err := service.ReserveTables(2, time.Time{}, customer, models.SectionPreference{})
+		assert.True(t, errors.IsValidationError(err), "%+v", customer)
+	}
+}
+
+func TestReserveTablesInThePast(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	mockRepo.On("IsInitialized").Return(true, nil)
+
+	_, _, err := service.ReserveTables(3, testNow.Add(-time.Hour), models.CustomerDetails{}, models.SectionPreference{})
+	assert.Error(t, err)
+
+	mockRepo.AssertNotCalled(t, "ReserveTables", mock.Anything)
+}
+
+func TestReserveTablesRepositoryError(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	mockRepo.On("IsInitialized").Return(true, nil)
+	mockRepo.On("ReserveTables", mock.Anything).Return(nil, fmt.Errorf("connection refused"))
+
+	_, _, err := service.ReserveTables(3, time.Time{}, models.CustomerDetails{}, models.SectionPreference{})
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errors.ErrInsufficientTables)
+}
+
 func TestCancelReservation(t *testing.T) {
 	mockRepo := new(MockRepository)
-	service := restaurant.NewService(mockRepo, 4, 20, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789", 6)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	booking := models.NewBooking("BOOK55", "", 3, []string{"T1"}, testNow, 2*time.Hour)
 
-	mockRepo.On("IsInitialized").Return(true)
-	mockRepo.On("CancelReservation", "BOOK55").Return(1, nil)
-	mockRepo.On("GetAvailableTables").Return(10)
+	mockRepo.On("IsInitialized").Return(true, nil)
+	mockRepo.On("UpdateStatus", "BOOK55").Return(*booking, 10, nil)
 
 	tablesFreed, remaining, err := service.CancelReservation("BOOK55")
 	assert.NoError(t, err)
@@ -86,4 +350,546 @@ func TestCancelReservation(t *testing.T) {
 	mockRepo.AssertExpectations(t)
 }
 
+func TestBookingLifecycle(t *testing.T) {
+	// transition runs change against a fresh service whose booking is in status from
+	var service restaurant.Service
+	transition := func(from string, change func(id string) error) error {
+		mockRepo := new(MockRepository)
+		service = restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+		booking := models.NewBooking("BOOK55", "", 2, []string{"T1"}, testNow.Add(-time.Hour), 2*time.Hour)
+		booking.Status = from
+		mockRepo.On("IsInitialized").Return(true, nil)
+		mockRepo.On("GetBooking", "BOOK55").Return(*booking, nil)
+		mockRepo.On("UpdateStatus", "BOOK55").Return(*booking, 5, nil)
+		mockRepo.On("UpdateOccupancy", "T1").Return(*models.NewTable("T1", 4), false, nil)
+		return change("BOOK55")
+	}
+	seat := func(id string) error { _, err := service.SeatBooking(id); return err }
+	complete := func(id string) error { _, _, err := service.CompleteBooking(id); return err }
+	noShow := func(id string) error { _, _, err := service.MarkNoShow(id); return err }
+	cancel := func(id string) error { _, _, err := service.CancelReservation(id); return err }
+
+	assert.NoError(t, transition(models.BookingStatusConfirmed, seat))
+	assert.NoError(t, transition(models.BookingStatusSeated, complete))
+	assert.NoError(t, transition(models.BookingStatusConfirmed, noShow))
+	assert.NoError(t, transition(models.BookingStatusConfirmed, cancel))
+	assert.NoError(t, transition(models.BookingStatusPending, cancel))
+
+	for _, invalid := range []struct {
+		from   string
+		change func(id string) error
+	}{
+		{models.BookingStatusConfirmed, complete},
+		{models.BookingStatusSeated, seat},
+		{models.BookingStatusSeated, cancel},
+		{models.BookingStatusSeated, noShow},
+		{models.BookingStatusCompleted, seat},
+		{models.BookingStatusNoShow, cancel},
+		{models.BookingStatusCancelled, cancel},
+		{models.BookingStatusCancelled, seat},
+		{models.BookingStatusPending, seat},
+	} {
+		err := transition(invalid.from, invalid.change)
+		assert.True(t, errors.IsTransitionError(err), "from %s: %v", invalid.from, err)
+	}
+}
+
+func TestCompleteBookingFreesTablesEarly(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	booking := models.NewBooking("BOOK55", "", 2, []string{"T1"}, testNow.Add(-30*time.Minute), 2*time.Hour)
+	booking.Status = models.BookingStatusSeated
+	mockRepo.On("UpdateStatus", "BOOK55").Return(*booking, 5, nil)
+	mockRepo.On("UpdateOccupancy", "T1").Return(models.Table{ID: "T1", Capacity: 4, IsOccupied: true, PartySize: 2}, true, nil)
+
+	completed, remaining, err := service.CompleteBooking("BOOK55")
+	assert.NoError(t, err)
+	assert.Equal(t, models.BookingStatusCompleted, completed.Status)
+	assert.Equal(t, 30*time.Minute, completed.Duration)
+	assert.Equal(t, 5, remaining)
+	mockRepo.AssertCalled(t, "UpdateOccupancy", "T1")
+}
+
+func TestSeatBookingOccupiesTables(t *testing.T) {
+	seat := func(second models.Table) (*MockRepository, error) {
+		mockRepo := new(MockRepository)
+		service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+		booking := models.NewBooking("BOOK55", "", 5, []string{"T1", "T2"}, testNow, 2*time.Hour)
+		booking.Status = models.BookingStatusConfirmed
+		mockRepo.On("GetBooking", "BOOK55").Return(*booking, nil)
+		mockRepo.On("UpdateStatus", "BOOK55").Return(*booking, 5, nil)
+		mockRepo.On("UpdateOccupancy", "T1").Return(*models.NewTable("T1", 4), true, nil)
+		mockRepo.On("UpdateOccupancy", "T2").Return(second, true, nil)
+		_, err := service.SeatBooking("BOOK55")
+		return mockRepo, err
+	}
+
+	mockRepo, err := seat(*models.NewTable("T2", 2))
+	assert.NoError(t, err)
+	mockRepo.AssertNumberOfCalls(t, "UpdateOccupancy", 2)
+	mockRepo.AssertCalled(t, "UpdateStatus", "BOOK55")
+
+	// A walk-in still at T2 keeps the party standing and the booking's
+	// tables are cleared again
+	mockRepo, err = seat(models.Table{ID: "T2", Capacity: 2, IsOccupied: true, PartySize: 2})
+	assert.Equal(t, errors.ErrTableOccupied, err)
+	mockRepo.AssertNumberOfCalls(t, "UpdateOccupancy", 4)
+	mockRepo.AssertNotCalled(t, "UpdateStatus", "BOOK55")
+}
+
+func TestMarkNoShowBeforeBookingTime(t *testing.T) {
+	mockRepo := new(MockRepository)
+	clock := testutil.NewFakeClock(testNow)
+	service := restaurant.NewService(mockRepo, testOptions(), clock)
+
+	booking := models.NewBooking("BOOK55", "", 2, []string{"T1"}, testNow.Add(time.Hour), 2*time.Hour)
+	mockRepo.On("UpdateStatus", "BOOK55").Return(*booking, 5, nil)
+
+	_, _, err := service.MarkNoShow("BOOK55")
+	assert.True(t, errors.IsTransitionError(err))
+
+	clock.Advance(time.Hour - time.Second)
+	_, _, err = service.MarkNoShow("BOOK55")
+	assert.True(t, errors.IsTransitionError(err), "the guests are not late yet")
+
+	clock.Advance(time.Second)
+	noShow, _, err := service.MarkNoShow("BOOK55")
+	assert.NoError(t, err)
+	assert.Equal(t, models.BookingStatusNoShow, noShow.Status)
+}
+
+func TestSeatWalkIn(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	occupied := *models.NewTable("T2", 4)
+	occupied.IsOccupied = true
+	mockRepo.On("UpdateOccupancy", "T1").Return(*models.NewTable("T1", 4), false, nil)
+	mockRepo.On("UpdateOccupancy", "T2").Return(occupied, false, nil)
+	mockRepo.On("UpdateOccupancy", "T3").Return(*models.NewTable("T3", 4), true, nil)
+	mockRepo.On("UpdateOccupancy", "T9").Return(models.Table{}, false, errors.ErrTableNotFound)
+
+	table, err := service.SeatWalkIn("T1", 3)
+	assert.NoError(t, err)
+	assert.True(t, table.IsOccupied)
+	assert.Equal(t, 3, table.PartySize)
+	assert.False(t, table.OccupiedSince.IsZero())
+
+	_, err = service.SeatWalkIn("T1", 5)
+	assert.True(t, errors.IsValidationError(err))
+	_, err = service.SeatWalkIn("T1", 0)
+	assert.True(t, errors.IsValidationError(err))
+	_, err = service.SeatWalkIn("T2", 2)
+	assert.Equal(t, errors.ErrTableOccupied, err)
+	_, err = service.SeatWalkIn("T3", 2)
+	assert.Equal(t, errors.ErrTableReserved, err)
+	_, err = service.SeatWalkIn("T9", 2)
+	assert.Equal(t, errors.ErrTableNotFound, err)
+
+	cleared, err := service.ClearTable("T2")
+	assert.NoError(t, err)
+	assert.False(t, cleared.IsOccupied)
+	assert.Zero(t, cleared.PartySize)
+	_, err = service.ClearTable("T1")
+	assert.Equal(t, errors.ErrTableNotOccupied, err)
+}
+
+func TestGetFloorStatus(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	now := testNow
+	current := *models.NewBooking("BOOK01", "", 4, []string{"T1"}, now.Add(-time.Hour), 2*time.Hour)
+	next := *models.NewBooking("BOOK02", "", 4, []string{"T1", "T2"}, now.Add(2*time.Hour), 2*time.Hour)
+	later := *models.NewBooking("BOOK03", "", 4, []string{"T2"}, now.Add(5*time.Hour), 2*time.Hour)
+	cancelled := *models.NewBooking("BOOK04", "", 4, []string{"T3"}, now.Add(-time.Hour), 2*time.Hour)
+	cancelled.Status = models.BookingStatusCancelled
+
+	walkIn := *models.NewTable("T3", 4)
+	walkIn.IsOccupied = true
+	mockRepo.On("GetTables").Return([]models.Table{*models.NewTable("T1", 4), *models.NewTable("T2", 4), walkIn}, nil)
+	mockRepo.On("ListBookings", mock.Anything, (*models.BookingCursor)(nil), 100).Return([]models.Booking{current, cancelled, next, later}, nil)
+
+	statuses, err := service.GetFloorStatus()
+	assert.NoError(t, err)
+	require.Len(t, statuses, 3)
+	assert.Equal(t, "BOOK01", statuses[0].CurrentBooking.ID)
+	assert.Equal(t, "BOOK02", statuses[0].NextBooking.ID)
+	assert.Nil(t, statuses[1].CurrentBooking)
+	assert.Equal(t, "BOOK02", statuses[1].NextBooking.ID)
+	assert.True(t, statuses[2].IsOccupied)
+	assert.Nil(t, statuses[2].CurrentBooking)
+}
+
+func TestCancelReservationRejectsMistypedCode(t *testing.T) {
+	mockRepo := new(MockRepository)
+	codes, _ := restaurant.NewCodeGenerator("ABCDEFGHJKLMNPQRSTUVWXYZ23456789", 6, false, true)
+	options := testOptions()
+	options.Codes = codes
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+
+	code, _ := codes.Generate()
+	last := byte('A')
+	if code[6] == last {
+		last = 'B'
+	}
+	mistyped := code[:6] + string(last)
+
+	mockRepo.On("IsInitialized").Return(true, nil)
+
+	_, _, err := service.CancelReservation(mistyped)
+	assert.Equal(t, errors.ErrInvalidCheckCharacter, err)
+
+	mockRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything)
+}
+
+func TestModifyReservation(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	at := testNow.Add(24 * time.Hour)
+	booking := models.NewBooking("BOOK55", "", 4, []string{"T1"}, at, 2*time.Hour)
+	mockRepo.On("ModifyReservation", "BOOK55").Return(*booking, newTables(4, 4, 4), nil)
+
+	// Growing from 4 to 6 keeps the code and time and takes a second table
+	modified, remaining, err := service.ModifyReservation("BOOK55", 6, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, "BOOK55", modified.ID)
+	assert.Equal(t, 6, modified.NumCustomers)
+	assert.Equal(t, []string{"T1", "T2"}, modified.TableIDs)
+	assert.Equal(t, 8, modified.SeatsAssigned)
+	assert.True(t, at.Equal(modified.BookingTime))
+	assert.Equal(t, 1, remaining)
+
+	// Moving the time keeps the party size
+	later := at.Add(time.Hour)
+	modified, _, err = service.ModifyReservation("BOOK55", 0, later)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, modified.NumCustomers)
+	assert.True(t, later.Equal(modified.BookingTime))
+
+	_, _, err = service.ModifyReservation("BOOK55", 20, time.Time{})
+	assert.Equal(t, errors.ErrInsufficientTables, err)
+
+	_, _, err = service.ModifyReservation("BOOK55", 2, testNow.Add(-time.Hour))
+	assert.True(t, errors.IsValidationError(err))
+
+	mockRepo.On("ModifyReservation", "NOPE00").Return(models.Booking{}, nil, errors.ErrInvalidBookingID)
+	_, _, err = service.ModifyReservation("NOPE00", 2, time.Time{})
+	assert.Equal(t, errors.ErrInvalidBookingID, err)
+}
+
+func TestGetBooking(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	booking := models.NewBooking("BOOK55", "", 3, []string{"T1"}, testNow, 2*time.Hour)
+
+	mockRepo.On("GetBooking", "BOOK55").Return(*booking, nil)
+	mockRepo.On("GetBooking", "NOPE00").Return(models.Booking{}, errors.ErrInvalidBookingID)
+
+	found, err := service.GetBooking("BOOK55")
+	assert.NoError(t, err)
+	assert.Equal(t, *booking, found)
+	assert.Equal(t, models.BookingStatusConfirmed, found.Status)
+
+	_, err = service.GetBooking("NOPE00")
+	assert.Equal(t, errors.ErrInvalidBookingID, err)
+
+	// Malformed codes are rejected without a lookup
+	_, err = service.GetBooking("bad")
+	assert.Equal(t, errors.ErrInvalidBookingID, err)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListBookings(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	at := time.Date(2030, 1, 1, 19, 0, 0, 0, time.UTC)
+	first := *models.NewBooking("AAAAAA", "", 2, []string{"T1"}, at, 2*time.Hour)
+	second := *models.NewBooking("BBBBBB", "", 2, []string{"T2"}, at, 2*time.Hour)
+	third := *models.NewBooking("CCCCCC", "", 2, []string{"T3"}, at.Add(time.Hour), 2*time.Hour)
+
+	filter := models.BookingFilter{MinCustomers: 2}
+	mockRepo.On("ListBookings", filter, (*models.BookingCursor)(nil), 3).Return([]models.Booking{first, second, third}, nil)
+	mockRepo.On("ListBookings", filter, mock.MatchedBy(func(after *models.BookingCursor) bool {
+		return after != nil && after.ID == "BBBBBB" && after.BookingTime.Equal(at)
+	}), 3).Return([]models.Booking{third}, nil)
+
+	// The repository is asked for one extra booking to detect a next page
+	page, cursor, err := service.ListBookings(filter, "", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Booking{first, second}, page)
+	assert.NotEmpty(t, cursor)
+
+	page, cursor, err = service.ListBookings(filter, cursor, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Booking{third}, page)
+	assert.Empty(t, cursor)
+
+	mockRepo.AssertExpectations(t)
+
+	_, _, err = service.ListBookings(filter, "not a cursor", 2)
+	assert.True(t, errors.IsValidationError(err))
+	_, _, err = service.ListBookings(filter, "", 1000)
+	assert.True(t, errors.IsValidationError(err))
+	_, _, err = service.ListBookings(models.BookingFilter{MinCustomers: 6, MaxCustomers: 2}, "", 0)
+	assert.True(t, errors.IsValidationError(err))
+	_, _, err = service.ListBookings(models.BookingFilter{From: at, To: at}, "", 0)
+	assert.True(t, errors.IsValidationError(err))
+	_, _, err = service.ListBookings(models.BookingFilter{Phone: "abc"}, "", 0)
+	assert.True(t, errors.IsValidationError(err))
+
+	// Phone and email searches are normalized the way they are stored
+	normalized := models.BookingFilter{Phone: "0812345678", Email: "somchai@example.com"}
+	mockRepo.On("ListBookings", normalized, (*models.BookingCursor)(nil), 21).Return([]models.Booking{}, nil)
+	_, _, err = service.ListBookings(models.BookingFilter{Phone: "081-234-5678", Email: " Somchai@Example.com"}, "", 0)
+	assert.NoError(t, err)
+}
+
+func TestReserveTablesRetriesDuplicateCodes(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	var tried []string
+	mockRepo.On("IsInitialized").Return(true, nil)
+	mockRepo.On("ReserveTables", mock.Anything).Return(nil, errors.ErrDuplicateBookingID).Twice().Run(func(args mock.Arguments) {
+		tried = append(tried, args.Get(0).(models.Booking).ID)
+	})
+	mockRepo.On("ReserveTables", mock.Anything).Return(newTables(4), nil).Once()
+
+	booking, _, err := service.ReserveTables(2, time.Time{}, models.CustomerDetails{}, models.SectionPreference{})
+	assert.NoError(t, err)
+	assert.Len(t, tried, 2)
+	assert.True(t, testCodes.Valid(booking.ID))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestReserveTablesGivesUpOnDuplicateCodes(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	mockRepo.On("IsInitialized").Return(true, nil)
+	mockRepo.On("ReserveTables", mock.Anything).Return(nil, errors.ErrDuplicateBookingID)
+
+	_, _, err := service.ReserveTables(2, time.Time{}, models.CustomerDetails{}, models.SectionPreference{})
+	assert.Error(t, err)
+}
+
+func TestJoinWaitlist(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	_, err := service.JoinWaitlist(2, time.Time{}, models.CustomerDetails{Phone: "0812345678"})
+	assert.Equal(t, errors.ErrWaitlistDisabled, err)
+
+	options := testOptions()
+	options.OfferHold = 15 * time.Minute
+	service = restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+	mockRepo.On("IsInitialized").Return(true, nil)
+
+	_, err = service.JoinWaitlist(2, time.Time{}, models.CustomerDetails{CustomerName: "Anna"})
+	assert.True(t, errors.IsValidationError(err), "contact details are required")
+
+	var added models.WaitlistEntry
+	mockRepo.On("AddToWaitlist", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		added = args.Get(0).(models.WaitlistEntry)
+	})
+	mockRepo.On("ListWaitlist", models.WaitlistStatusWaiting).Return([]models.WaitlistEntry{}, nil)
+	mockRepo.On("GetWaitlistEntry", mock.Anything).Return(models.WaitlistEntry{ID: "WAIT01"}, nil)
+
+	_, err = service.JoinWaitlist(2, time.Time{}, models.CustomerDetails{CustomerName: "Anna", Phone: "081-234-5678"})
+	assert.NoError(t, err)
+	assert.Equal(t, models.WaitlistStatusWaiting, added.Status)
+	assert.Equal(t, "0812345678", added.Phone)
+	assert.True(t, testCodes.Valid(added.ID))
+}
+
+func TestCancelReservationOffersTablesToWaitlist(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.OfferHold = 15 * time.Minute
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+
+	cancelled := models.NewBooking("BOOK55", "", 4, []string{"T1"}, testNow, 2*time.Hour)
+	first := models.NewWaitlistEntry("WAIT01", models.CustomerDetails{Phone: "0811111111"}, 6, time.Time{}, testNow.Add(-time.Hour))
+	second := models.NewWaitlistEntry("WAIT02", models.CustomerDetails{Phone: "0822222222"}, 2, time.Time{}, testNow)
+
+	mockRepo.On("IsInitialized").Return(true, nil)
+	mockRepo.On("UpdateStatus", "BOOK55").Return(*cancelled, 1, nil)
+	mockRepo.On("ListWaitlist", models.WaitlistStatusWaiting).Return([]models.WaitlistEntry{*first, *second}, nil)
+	// Only the second party fits the freed table
+	mockRepo.On("ReserveTables", mock.MatchedBy(func(b models.Booking) bool { return b.NumCustomers == 6 })).Return(newTables(4), nil)
+	var held models.Booking
+	mockRepo.On("ReserveTables", mock.MatchedBy(func(b models.Booking) bool { return b.NumCustomers == 2 })).Return(newTables(4), nil).Run(func(args mock.Arguments) {
+		held = args.Get(0).(models.Booking)
+	})
+	mockRepo.On("UpdateWaitlistEntry", "WAIT02").Return(*second, nil)
+
+	_, _, err := service.CancelReservation("BOOK55")
+	assert.NoError(t, err)
+	assert.Equal(t, models.BookingStatusPending, held.Status)
+	assert.Equal(t, "0822222222", held.Phone)
+	assert.WithinDuration(t, testNow.Add(15*time.Minute), held.HoldExpiresAt, time.Minute)
+
+	mockRepo.AssertNotCalled(t, "UpdateWaitlistEntry", "WAIT01")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestConfirmWaitlistOffer(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.OfferHold = 15 * time.Minute
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+
+	entry := models.NewWaitlistEntry("WAIT01", models.CustomerDetails{Phone: "0811111111"}, 2, time.Time{}, testNow)
+	entry.Status = models.WaitlistStatusOffered
+	entry.BookingID = "BOOK55"
+	entry.OfferExpiresAt = testNow.Add(10 * time.Minute)
+	held := models.NewBooking("BOOK55", "", 2, []string{"T1"}, testNow, 2*time.Hour)
+	held.Status = models.BookingStatusPending
+	held.HoldExpiresAt = entry.OfferExpiresAt
+
+	mockRepo.On("UpdateWaitlistEntry", "WAIT01").Return(*entry, nil)
+	mockRepo.On("UpdateStatus", "BOOK55").Return(*held, 3, nil)
+
+	booking, err := service.ConfirmWaitlistOffer("WAIT01")
+	assert.NoError(t, err)
+	assert.Equal(t, models.BookingStatusConfirmed, booking.Status)
+	assert.True(t, booking.HoldExpiresAt.IsZero())
+}
+
+func TestConfirmWaitlistOfferAfterHoldExpires(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.OfferHold = 15 * time.Minute
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+
+	entry := models.NewWaitlistEntry("WAIT01", models.CustomerDetails{Phone: "0811111111"}, 2, time.Time{}, testNow.Add(-time.Hour))
+	entry.Status = models.WaitlistStatusOffered
+	entry.BookingID = "BOOK55"
+	entry.OfferExpiresAt = testNow.Add(-time.Minute)
+	released := models.NewBooking("BOOK55", "", 2, []string{"T1"}, testNow, 2*time.Hour)
+	released.Status = models.BookingStatusCancelled
+
+	mockRepo.On("UpdateWaitlistEntry", "WAIT01").Return(*entry, nil)
+	mockRepo.On("ReleaseExpiredHolds", mock.Anything).Return([]models.Booking{*released}, nil)
+	mockRepo.On("ListWaitlist", models.WaitlistStatusOffered).Return([]models.WaitlistEntry{*entry}, nil)
+	mockRepo.On("ListWaitlist", models.WaitlistStatusWaiting).Return([]models.WaitlistEntry{}, nil)
+	mockRepo.On("GetBooking", "BOOK55").Return(*released, nil)
+	mockRepo.On("UpdateStatus", "BOOK55").Return(*released, 3, nil)
+
+	_, err := service.ConfirmWaitlistOffer("WAIT01")
+	assert.Equal(t, errors.ErrOfferExpired, err)
+	// The lapsed hold is released straight away and the table offered on
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetWaitlistEntryWithLapsedOffer(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.OfferHold = 15 * time.Minute
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+
+	entry := models.NewWaitlistEntry("WAIT01", models.CustomerDetails{Phone: "0811111111"}, 2, time.Time{}, testNow.Add(-time.Hour))
+	entry.Status = models.WaitlistStatusOffered
+	entry.BookingID = "BOOK55"
+	entry.OfferExpiresAt = testNow.Add(-time.Minute)
+	held := models.NewBooking("BOOK55", "", 2, []string{"T1"}, testNow, 2*time.Hour)
+	held.Status = models.BookingStatusPending
+
+	mockRepo.On("GetWaitlistEntry", "WAIT01").Return(*entry, nil)
+	mockRepo.On("GetBooking", "BOOK55").Return(*held, nil).Once()
+
+	// The lapsed offer reads as expired without the lookup releasing it
+	stored, err := service.GetWaitlistEntry("WAIT01")
+	assert.NoError(t, err)
+	assert.Equal(t, models.WaitlistStatusExpired, stored.Status)
+	mockRepo.AssertNotCalled(t, "ReleaseExpiredHolds", mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdateWaitlistEntry", mock.Anything)
+	mockRepo.AssertNotCalled(t, "UpdateStatus", mock.Anything)
+
+	// or as booked if the held booking was confirmed directly
+	held.Status = models.BookingStatusConfirmed
+	mockRepo.On("GetBooking", "BOOK55").Return(*held, nil)
+	stored, err = service.GetWaitlistEntry("WAIT01")
+	assert.NoError(t, err)
+	assert.Equal(t, models.WaitlistStatusBooked, stored.Status)
+}
+
+func TestHoldTables(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	_, _, err := service.HoldTables(2, time.Time{}, models.SectionPreference{})
+	assert.Equal(t, errors.ErrHoldsDisabled, err)
+
+	options := testOptions()
+	options.HoldTTL = 5 * time.Minute
+	service = restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+	mockRepo.On("IsInitialized").Return(true, nil)
+	mockRepo.On("ReserveTables", mock.Anything).Return(newTables(4), nil)
+
+	held, _, err := service.HoldTables(2, time.Time{}, models.SectionPreference{})
+	assert.NoError(t, err)
+	assert.Equal(t, models.BookingStatusPending, held.Status)
+	assert.WithinDuration(t, testNow.Add(5*time.Minute), held.HoldExpiresAt, time.Minute)
+}
+
+func TestConfirmHold(t *testing.T) {
+	// confirm runs ConfirmHold against a fresh service whose stored booking is booking
+	confirm := func(booking models.Booking) (models.Booking, error) {
+		mockRepo := new(MockRepository)
+		options := testOptions()
+		options.HoldTTL = 5 * time.Minute
+		service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+		mockRepo.On("ModifyReservation", "BOOK55").Return(booking, []models.Table{}, nil)
+		return service.ConfirmHold("BOOK55", models.CustomerDetails{CustomerName: " Anna ", Phone: "081-234-5678"})
+	}
+
+	held := models.NewBooking("BOOK55", "", 2, []string{"T1"}, testNow.Add(time.Hour), 2*time.Hour)
+	held.Status = models.BookingStatusPending
+	held.HoldExpiresAt = testNow.Add(time.Minute)
+
+	confirmed, err := confirm(*held)
+	assert.NoError(t, err)
+	assert.Equal(t, models.BookingStatusConfirmed, confirmed.Status)
+	assert.True(t, confirmed.HoldExpiresAt.IsZero())
+	assert.Equal(t, "Anna", confirmed.CustomerName)
+	assert.Equal(t, "0812345678", confirmed.Phone)
+	assert.Equal(t, []string{"T1"}, confirmed.TableIDs)
+
+	lapsed := *held
+	lapsed.HoldExpiresAt = testNow.Add(-time.Second)
+	_, err = confirm(lapsed)
+	assert.Equal(t, errors.ErrHoldExpired, err)
+
+	// The hold lapses exactly when the clock reaches its expiry
+	mockRepo := new(MockRepository)
+	clock := testutil.NewFakeClock(testNow)
+	options := testOptions()
+	options.HoldTTL = 5 * time.Minute
+	service := restaurant.NewService(mockRepo, options, clock)
+	mockRepo.On("ModifyReservation", "BOOK55").Return(*held, []models.Table{}, nil)
+	clock.Advance(time.Minute)
+	_, err = service.ConfirmHold("BOOK55", models.CustomerDetails{CustomerName: "Anna"})
+	assert.Equal(t, errors.ErrHoldExpired, err)
+
+	reaped := *held
+	reaped.Status = models.BookingStatusCancelled
+	_, err = confirm(reaped)
+	assert.Equal(t, errors.ErrHoldExpired, err)
+
+	// Only holds can be confirmed
+	booked := *held
+	booked.Status = models.BookingStatusConfirmed
+	booked.HoldExpiresAt = time.Time{}
+	_, err = confirm(booked)
+	assert.True(t, errors.IsTransitionError(err))
+}
+
 // Add more test cases for edge cases and error scenarios
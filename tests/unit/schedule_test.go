@@ -0,0 +1,259 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/domain/restaurant"
+	"booking-dinner/internal/errors"
+	"booking-dinner/tests/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var bangkok, _ = time.LoadLocation("Asia/Bangkok")
+
+// newTestSchedule serves lunch and dinner from Tuesday to Sunday, is closed
+// on New Year's Day 2030 and holds an event on 2030-02-14 with its own hours
+// and only T1 and T2 bookable online
+func newTestSchedule(t *testing.T) *restaurant.Schedule {
+	lunchAndDinner := []string{"lunch", "dinner"}
+	schedule, err := restaurant.NewSchedule("Asia/Bangkok",
+		[]restaurant.ServicePeriod{
+			{Name: "lunch", Open: "11:00", Close: "14:00"},
+			{Name: "dinner", Open: "17:30", Close: "21:30"},
+		},
+		map[string][]string{
+			"tuesday": lunchAndDinner, "wednesday": lunchAndDinner, "thursday": lunchAndDinner,
+			"friday": lunchAndDinner, "saturday": lunchAndDinner, "sunday": lunchAndDinner,
+		},
+		[]restaurant.Closure{{Date: "2030-01-01", Reason: "New Year's Day"}},
+		[]restaurant.SpecialEvent{{Name: "Valentine's dinner", Date: "2030-02-14", Open: "18:00", Close: "22:00", TableIDs: []string{"T1", "T2"}}},
+	)
+	require.NoError(t, err)
+	return schedule
+}
+
+func TestScheduleCheck(t *testing.T) {
+	schedule := newTestSchedule(t)
+
+	// Wednesday 2030-01-02
+	assert.NoError(t, schedule.Check(time.Date(2030, 1, 2, 11, 0, 0, 0, bangkok)))
+	assert.NoError(t, schedule.Check(time.Date(2030, 1, 2, 21, 29, 0, 0, bangkok)))
+	assert.NoError(t, schedule.Check(time.Date(2030, 1, 2, 12, 0, 0, 0, time.UTC)), "19:00 in Bangkok")
+
+	err := schedule.Check(time.Date(2030, 1, 2, 15, 0, 0, 0, bangkok))
+	scheduleErr, ok := errors.AsScheduleError(err)
+	require.True(t, ok)
+	assert.True(t, errors.IsValidationError(err))
+	assert.Equal(t, errors.ScheduleReasonOutsideHours, scheduleErr.Reason)
+	assert.Equal(t, "2030-01-02", scheduleErr.Date)
+	assert.Equal(t, []errors.ServicePeriod{
+		{Name: "lunch", Open: "11:00", Close: "14:00"},
+		{Name: "dinner", Open: "17:30", Close: "21:30"},
+	}, scheduleErr.Periods)
+
+	// The last seating is the close time
+	err = schedule.Check(time.Date(2030, 1, 2, 21, 30, 0, 0, bangkok))
+	scheduleErr, ok = errors.AsScheduleError(err)
+	require.True(t, ok)
+	assert.Equal(t, errors.ScheduleReasonOutsideHours, scheduleErr.Reason)
+
+	err = schedule.Check(time.Date(2030, 1, 7, 19, 0, 0, 0, bangkok))
+	scheduleErr, ok = errors.AsScheduleError(err)
+	require.True(t, ok)
+	assert.Equal(t, errors.ScheduleReasonClosedDay, scheduleErr.Reason, "closed on Mondays")
+	assert.Empty(t, scheduleErr.Periods)
+
+	err = schedule.Check(time.Date(2030, 1, 1, 19, 0, 0, 0, bangkok))
+	scheduleErr, ok = errors.AsScheduleError(err)
+	require.True(t, ok)
+	assert.Equal(t, errors.ScheduleReasonClosure, scheduleErr.Reason)
+	assert.Contains(t, err.Error(), "New Year's Day")
+
+	// The event's hours replace the usual lunch and dinner
+	assert.NoError(t, schedule.Check(time.Date(2030, 2, 14, 21, 45, 0, 0, bangkok)))
+	err = schedule.Check(time.Date(2030, 2, 14, 12, 0, 0, 0, bangkok))
+	scheduleErr, ok = errors.AsScheduleError(err)
+	require.True(t, ok)
+	assert.Equal(t, []errors.ServicePeriod{{Name: "Valentine's dinner", Open: "18:00", Close: "22:00"}}, scheduleErr.Periods)
+
+	assert.Equal(t, []string{"T1", "T2"}, schedule.BookableTables(time.Date(2030, 2, 14, 19, 0, 0, 0, bangkok)))
+	assert.Nil(t, schedule.BookableTables(time.Date(2030, 2, 15, 19, 0, 0, 0, bangkok)))
+
+	var always *restaurant.Schedule
+	assert.NoError(t, always.Check(time.Date(2030, 1, 1, 3, 0, 0, 0, bangkok)))
+}
+
+func TestNewScheduleRejectsInvalidConfig(t *testing.T) {
+	dinner := []restaurant.ServicePeriod{{Name: "dinner", Open: "17:30", Close: "21:30"}}
+	for name, build := range map[string]func() (*restaurant.Schedule, error){
+		"unknown time zone": func() (*restaurant.Schedule, error) {
+			return restaurant.NewSchedule("Mars/Olympus", dinner, nil, nil, nil)
+		},
+		"bad clock time": func() (*restaurant.Schedule, error) {
+			return restaurant.NewSchedule("UTC", []restaurant.ServicePeriod{{Name: "dinner", Open: "5pm", Close: "21:30"}}, nil, nil, nil)
+		},
+		"closes before opening": func() (*restaurant.Schedule, error) {
+			return restaurant.NewSchedule("UTC", []restaurant.ServicePeriod{{Name: "dinner", Open: "21:30", Close: "17:30"}}, nil, nil, nil)
+		},
+		"unknown day": func() (*restaurant.Schedule, error) {
+			return restaurant.NewSchedule("UTC", dinner, map[string][]string{"funday": {"dinner"}}, nil, nil)
+		},
+		"unknown period": func() (*restaurant.Schedule, error) {
+			return restaurant.NewSchedule("UTC", dinner, map[string][]string{"monday": {"brunch"}}, nil, nil)
+		},
+		"overlapping periods": func() (*restaurant.Schedule, error) {
+			periods := append([]restaurant.ServicePeriod{{Name: "late", Open: "21:00", Close: "23:00"}}, dinner...)
+			return restaurant.NewSchedule("UTC", periods, map[string][]string{"monday": {"dinner", "late"}}, nil, nil)
+		},
+		"bad closure date": func() (*restaurant.Schedule, error) {
+			return restaurant.NewSchedule("UTC", dinner, nil, []restaurant.Closure{{Date: "01/01/2030"}}, nil)
+		},
+		"event on a closed date": func() (*restaurant.Schedule, error) {
+			return restaurant.NewSchedule("UTC", dinner, nil, []restaurant.Closure{{Date: "2030-01-01"}}, []restaurant.SpecialEvent{{Name: "Party", Date: "2030-01-01"}})
+		},
+	} {
+		_, err := build()
+		assert.Error(t, err, name)
+	}
+}
+
+func TestReserveTablesOutsideSchedule(t *testing.T) {
+	mockRepo := new(MockRepository)
+	clock := testutil.NewFakeClock(time.Date(2030, 1, 2, 9, 0, 0, 0, bangkok))
+	options := testOptions()
+	options.Schedule = newTestSchedule(t)
+	service := restaurant.NewService(mockRepo, options, clock)
+
+	mockRepo.On("IsInitialized").Return(true, nil)
+	mockRepo.On("ReserveTables", mock.Anything).Return(newTables(4, 4, 4), nil)
+
+	_, _, err := service.ReserveTables(2, time.Time{}, models.CustomerDetails{}, models.SectionPreference{})
+	scheduleErr, ok := errors.AsScheduleError(err)
+	require.True(t, ok, "walk-in bookings are only taken while open")
+	assert.Equal(t, errors.ScheduleReasonOutsideHours, scheduleErr.Reason)
+
+	_, _, err = service.ReserveTables(2, time.Date(2030, 1, 7, 19, 0, 0, 0, bangkok), models.CustomerDetails{}, models.SectionPreference{})
+	assert.True(t, errors.IsValidationError(err), "closed on Mondays")
+	mockRepo.AssertNotCalled(t, "ReserveTables", mock.Anything)
+
+	clock.Advance(3 * time.Hour)
+	_, _, err = service.ReserveTables(2, time.Time{}, models.CustomerDetails{}, models.SectionPreference{})
+	assert.NoError(t, err)
+
+	booking, _, err := service.ReserveTables(2, time.Date(2030, 1, 2, 19, 0, 0, 0, bangkok), models.CustomerDetails{}, models.SectionPreference{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"T1"}, booking.TableIDs)
+}
+
+func TestReserveTablesOnlyBooksOnlineTables(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.Schedule = newTestSchedule(t)
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+
+	// T1 is taken, so only T2 is free online on the night of the event
+	tables := newTables(4, 4, 4, 4)[1:]
+	mockRepo.On("IsInitialized").Return(true, nil)
+	mockRepo.On("ReserveTables", mock.Anything).Return(tables, nil)
+
+	event := time.Date(2030, 2, 14, 19, 0, 0, 0, bangkok)
+	booking, _, err := service.ReserveTables(4, event, models.CustomerDetails{}, models.SectionPreference{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"T2"}, booking.TableIDs)
+
+	_, _, err = service.ReserveTables(6, event, models.CustomerDetails{}, models.SectionPreference{})
+	assert.Equal(t, errors.ErrInsufficientTables, err)
+
+	booking, _, err = service.ReserveTables(6, event.AddDate(0, 0, 1), models.CustomerDetails{}, models.SectionPreference{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"T2", "T3"}, booking.TableIDs)
+}
+
+func TestModifyReservationOutsideSchedule(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.Schedule = newTestSchedule(t)
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+
+	_, _, err := service.ModifyReservation("BOOK55", 0, time.Date(2030, 1, 8, 15, 0, 0, 0, bangkok))
+	scheduleErr, ok := errors.AsScheduleError(err)
+	require.True(t, ok)
+	assert.Equal(t, "2030-01-08", scheduleErr.Date)
+	mockRepo.AssertNotCalled(t, "ModifyReservation", mock.Anything)
+}
+
+func TestGetAvailability(t *testing.T) {
+	mockRepo := new(MockRepository)
+	clock := testutil.NewFakeClock(time.Date(2030, 1, 2, 12, 0, 0, 0, bangkok))
+	options := testOptions()
+	options.SlotInterval = 30 * time.Minute
+	options.Schedule = newTestSchedule(t)
+	service := restaurant.NewService(mockRepo, options, clock)
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2030, 1, 2, hour, minute, 0, 0, bangkok)
+	}
+	// T2 and T3 are booked from 18:00 to 20:00
+	windows := []models.ReservedWindow{
+		{TableID: "T2", Start: at(18, 0), End: at(20, 0)},
+		{TableID: "T3", Start: at(18, 0), End: at(20, 0)},
+	}
+	mockRepo.On("GetReservedWindows", at(12, 0), at(23, 0)).Return(newTables(2, 4, 4), windows, nil)
+
+	// Lunch slots before noon have passed, and dinner slots whose two hours
+	// overlap the booking leave only T1 for a party of six
+	slots, err := service.GetAvailability("2030-01-02", 6, "")
+	assert.NoError(t, err)
+	var times []time.Time
+	for _, slot := range slots {
+		times = append(times, slot.Time)
+	}
+	assert.Equal(t, []time.Time{at(12, 0), at(12, 30), at(13, 0), at(13, 30), at(20, 0), at(20, 30), at(21, 0)}, times)
+	assert.Equal(t, 3, slots[0].AvailableTables)
+	assert.Equal(t, 10, slots[0].AvailableSeats)
+
+	// A party of two fits at T1 all evening
+	slots, err = service.GetAvailability("2030-01-02", 2, "")
+	assert.NoError(t, err)
+	assert.Len(t, slots, 12)
+	assert.Equal(t, models.Slot{Time: at(18, 0), AvailableTables: 1, AvailableSeats: 2}, slots[5])
+
+	// Closed days have no slots and need no read
+	slots, err = service.GetAvailability("2030-01-07", 2, "")
+	assert.NoError(t, err)
+	assert.Empty(t, slots)
+
+	_, err = service.GetAvailability("02/01/2030", 2, "")
+	assert.True(t, errors.IsValidationError(err))
+	_, err = service.GetAvailability("2030-01-02", 0, "")
+	assert.True(t, errors.IsValidationError(err))
+
+	mockRepo.AssertNumberOfCalls(t, "GetReservedWindows", 2)
+}
+
+func TestGetAvailabilityOnlyCountsOnlineTables(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.SlotInterval = time.Hour
+	options.Schedule = newTestSchedule(t)
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+
+	mockRepo.On("GetReservedWindows", mock.Anything, mock.Anything).Return(newTables(2, 4, 4), []models.ReservedWindow{}, nil)
+
+	// Only T1 and T2 are bookable online on the night of the event
+	slots, err := service.GetAvailability("2030-02-14", 6, "")
+	assert.NoError(t, err)
+	require.Len(t, slots, 4)
+	assert.Equal(t, time.Date(2030, 2, 14, 18, 0, 0, 0, bangkok), slots[0].Time)
+	assert.Equal(t, 2, slots[0].AvailableTables)
+	assert.Equal(t, 6, slots[0].AvailableSeats)
+
+	slots, err = service.GetAvailability("2030-02-14", 8, "")
+	assert.NoError(t, err)
+	assert.Empty(t, slots)
+}
@@ -0,0 +1,95 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/domain/restaurant"
+	"booking-dinner/internal/errors"
+	"booking-dinner/tests/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newSectionedTables returns T1 and T2 of 4 seats indoors and T3 of 2 seats
+// on the terrace
+func newSectionedTables() []models.Table {
+	tables := newTables(4, 4, 2)
+	tables[0].Section = "indoor"
+	tables[1].Section = "indoor"
+	tables[2].Section = "terrace"
+	return tables
+}
+
+func TestReserveTablesWithSectionPreference(t *testing.T) {
+	reserve := func(numCustomers int, section models.SectionPreference) (models.Booking, error) {
+		mockRepo := new(MockRepository)
+		service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+		mockRepo.On("IsInitialized").Return(true, nil)
+		mockRepo.On("ReserveTables", mock.Anything).Return(newSectionedTables(), nil)
+		booking, _, err := service.ReserveTables(numCustomers, time.Time{}, models.CustomerDetails{}, section)
+		return booking, err
+	}
+
+	booking, err := reserve(2, models.SectionPreference{Section: "terrace", Strict: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"T3"}, booking.TableIDs)
+	assert.Equal(t, "terrace", booking.Section)
+	assert.Equal(t, models.SectionPreference{Section: "terrace", Strict: true}, booking.SectionPreference)
+
+	// The terrace is too small, so a best-effort request goes indoors
+	booking, err = reserve(4, models.SectionPreference{Section: "terrace"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"T1"}, booking.TableIDs)
+	assert.Equal(t, "indoor", booking.Section)
+
+	_, err = reserve(4, models.SectionPreference{Section: "terrace", Strict: true})
+	assert.Equal(t, errors.ErrSectionUnavailable, err)
+	assert.True(t, errors.IsNoTablesError(err))
+
+	// A party spread over sections has no single section
+	booking, err = reserve(10, models.SectionPreference{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"T1", "T2", "T3"}, booking.TableIDs)
+	assert.Empty(t, booking.Section)
+}
+
+func TestModifyReservationKeepsSectionPreference(t *testing.T) {
+	mockRepo := new(MockRepository)
+	service := restaurant.NewService(mockRepo, testOptions(), testutil.NewFakeClock(testNow))
+
+	booking := models.NewBooking("BOOK55", "", 2, []string{"T3"}, testNow.Add(time.Hour), 2*time.Hour)
+	booking.SectionPreference = models.SectionPreference{Section: "terrace", Strict: true}
+	booking.Section = "terrace"
+	mockRepo.On("ModifyReservation", "BOOK55").Return(*booking, newSectionedTables(), nil)
+
+	_, _, err := service.ModifyReservation("BOOK55", 4, time.Time{})
+	assert.Equal(t, errors.ErrSectionUnavailable, err)
+}
+
+func TestGetAvailabilityBySection(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.SlotInterval = time.Hour
+	options.Schedule = newTestSchedule(t)
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+	mockRepo.On("GetReservedWindows", mock.Anything, mock.Anything).Return(newSectionedTables(), []models.ReservedWindow{}, nil)
+
+	all, err := service.GetAvailability("2030-01-02", 4, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, all)
+	assert.Equal(t, 3, all[0].AvailableTables)
+
+	terrace, err := service.GetAvailability("2030-01-02", 2, "terrace")
+	require.NoError(t, err)
+	require.Len(t, terrace, len(all))
+	assert.Equal(t, 1, terrace[0].AvailableTables)
+	assert.Equal(t, 2, terrace[0].AvailableSeats)
+
+	terrace, err = service.GetAvailability("2030-01-02", 4, "terrace")
+	require.NoError(t, err)
+	assert.Empty(t, terrace)
+}
@@ -0,0 +1,161 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"booking-dinner/internal/domain/models"
+	"booking-dinner/internal/domain/restaurant"
+	"booking-dinner/internal/errors"
+	"booking-dinner/tests/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestTurnTimes gives couples 90 minutes and parties of six or more 150
+// minutes at dinner and 120 minutes otherwise
+func newTestTurnTimes(t *testing.T) restaurant.TurnTimes {
+	turnTimes, err := restaurant.NewTurnTimes([]restaurant.TurnTimeRule{
+		{MaxParty: 2, Duration: 90 * time.Minute},
+		{MinParty: 6, Period: "dinner", Duration: 150 * time.Minute},
+		{MinParty: 6, Duration: 120 * time.Minute},
+	})
+	require.NoError(t, err)
+	return turnTimes
+}
+
+func TestTurnTimes(t *testing.T) {
+	turnTimes := newTestTurnTimes(t)
+
+	for _, test := range []struct {
+		partySize int
+		period    string
+		expected  time.Duration
+		matched   bool
+	}{
+		{1, "lunch", 90 * time.Minute, true},
+		{2, "dinner", 90 * time.Minute, true},
+		{4, "dinner", 0, false},
+		{6, "dinner", 150 * time.Minute, true},
+		{10, "lunch", 120 * time.Minute, true},
+		{6, "", 120 * time.Minute, true},
+	} {
+		turnTime, matched := turnTimes.For(test.partySize, test.period)
+		assert.Equal(t, test.expected, turnTime, "%d at %s", test.partySize, test.period)
+		assert.Equal(t, test.matched, matched, "%d at %s", test.partySize, test.period)
+	}
+
+	for _, rules := range [][]restaurant.TurnTimeRule{
+		{{Duration: 0}},
+		{{Duration: 9 * time.Hour}},
+		{{MinParty: -1, Duration: time.Hour}},
+		{{MinParty: 6, MaxParty: 4, Duration: time.Hour}},
+	} {
+		_, err := restaurant.NewTurnTimes(rules)
+		assert.Error(t, err, "%+v", rules)
+	}
+}
+
+func TestReserveTablesUsesTurnTimes(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.TurnTimes = newTestTurnTimes(t)
+	options.Schedule = newTestSchedule(t)
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+
+	mockRepo.On("IsInitialized").Return(true, nil)
+	mockRepo.On("ReserveTables", mock.Anything).Return(newTables(4, 4, 4), nil)
+
+	dinner := time.Date(2030, 1, 2, 19, 0, 0, 0, bangkok)
+	lunch := time.Date(2030, 1, 2, 12, 0, 0, 0, bangkok)
+	for _, test := range []struct {
+		partySize   int
+		bookingTime time.Time
+		expected    time.Duration
+	}{
+		{2, dinner, 90 * time.Minute},
+		{6, dinner, 150 * time.Minute},
+		{6, lunch, 120 * time.Minute},
+		{4, dinner, 2 * time.Hour},
+	} {
+		booking, _, err := service.ReserveTables(test.partySize, test.bookingTime, models.CustomerDetails{}, models.SectionPreference{})
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, booking.Duration, "party of %d", test.partySize)
+		assert.Equal(t, test.bookingTime.Add(test.expected), booking.EndTime())
+	}
+}
+
+func TestModifyReservationKeepsTurnTimeOverride(t *testing.T) {
+	mockRepo := new(MockRepository)
+	options := testOptions()
+	options.TurnTimes = newTestTurnTimes(t)
+	service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+
+	booking := models.NewBooking("BOOK55", "", 2, []string{"T1"}, testNow.Add(time.Hour), 90*time.Minute)
+	mockRepo.On("ModifyReservation", "BOOK55").Return(*booking, newTables(4, 4), nil).Once()
+	modified, _, err := service.ModifyReservation("BOOK55", 8, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 120*time.Minute, modified.Duration, "the larger party gets its own turn time")
+
+	booking.TurnTimeOverride = 3 * time.Hour
+	booking.Duration = 3 * time.Hour
+	mockRepo.On("ModifyReservation", "BOOK55").Return(*booking, newTables(4, 4), nil).Once()
+	modified, _, err = service.ModifyReservation("BOOK55", 8, time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3*time.Hour, modified.Duration, "a manager's turn time is kept")
+}
+
+func TestSetTurnTime(t *testing.T) {
+	// setTurnTime runs SetTurnTime against a fresh service whose stored
+	// booking is booking and whose free tables are free
+	setTurnTime := func(booking models.Booking, free []models.Table, turnTime time.Duration) (models.Booking, error) {
+		mockRepo := new(MockRepository)
+		options := testOptions()
+		options.TurnTimes = newTestTurnTimes(t)
+		service := restaurant.NewService(mockRepo, options, testutil.NewFakeClock(testNow))
+		mockRepo.On("ModifyReservation", "BOOK55").Return(booking, free, nil)
+		modified, _, err := service.SetTurnTime("BOOK55", turnTime)
+		return modified, err
+	}
+
+	booking := *models.NewBooking("BOOK55", "", 2, []string{"T1"}, testNow.Add(time.Hour), 90*time.Minute)
+	booking.SeatsAssigned = 4
+
+	modified, err := setTurnTime(booking, newTables(4, 4), 3*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, 3*time.Hour, modified.Duration)
+	assert.Equal(t, 3*time.Hour, modified.TurnTimeOverride)
+	assert.Equal(t, []string{"T1"}, modified.TableIDs)
+
+	// The booking moves to a table that is free for its longer window
+	modified, err = setTurnTime(booking, newTables(4, 4)[1:], 3*time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"T2"}, modified.TableIDs)
+
+	// Zero goes back to the rules
+	overridden := booking
+	overridden.TurnTimeOverride = 3 * time.Hour
+	overridden.Duration = 3 * time.Hour
+	modified, err = setTurnTime(overridden, newTables(4, 4), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Minute, modified.Duration)
+	assert.Zero(t, modified.TurnTimeOverride)
+
+	// A seated party cannot be moved to other tables
+	seated := booking
+	seated.Status = models.BookingStatusSeated
+	_, err = setTurnTime(seated, newTables(4, 4)[1:], 3*time.Hour)
+	assert.Equal(t, errors.ErrInsufficientTables, err)
+
+	completed := booking
+	completed.Status = models.BookingStatusCompleted
+	_, err = setTurnTime(completed, newTables(4, 4), 3*time.Hour)
+	assert.True(t, errors.IsTransitionError(err))
+
+	_, err = setTurnTime(booking, newTables(4, 4), 9*time.Hour)
+	assert.True(t, errors.IsValidationError(err))
+	_, err = setTurnTime(booking, newTables(4, 4), -time.Minute)
+	assert.True(t, errors.IsValidationError(err))
+}